@@ -13,16 +13,27 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
 	"github.com/ribtoks/listing/pkg/api"
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/config"
 	"github.com/ribtoks/listing/pkg/db"
 )
 
 var (
 	handlerLambda *httpadapter.HandlerAdapter
+	publisher     api.EventPublisher
 )
 
 // Handler is the main entry point to this lambda
 func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return handlerLambda.ProxyWithContext(ctx, req)
+	resp, err := handlerLambda.ProxyWithContext(ctx, req)
+
+	// Lambda freezes the process after the response is returned, so
+	// webhooks queued by the request are delivered before that
+	if publisher != nil {
+		publisher.Flush()
+	}
+
+	return resp, err
 }
 
 func main() {
@@ -30,6 +41,13 @@ func main() {
 	subscribersTableName := os.Getenv("SUBSCRIBERS_TABLE")
 	notificationsTableName := os.Getenv("NOTIFICATIONS_TABLE")
 	supportedNewsletters := os.Getenv("SUPPORTED_NEWSLETTERS")
+	eventsTableName := os.Getenv("EVENTS_TABLE")
+	erasuresTableName := os.Getenv("ERASURES_TABLE")
+	deadLettersTableName := os.Getenv("DEAD_LETTERS_TABLE")
+	apiKeysTableName := os.Getenv("API_KEYS_TABLE")
+
+	logger := config.NewLogger()
+	common.DefaultLogger = logger
 
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(os.Getenv("AWS_REGION")),
@@ -40,18 +58,45 @@ func main() {
 	}
 
 	subscribers := db.NewSubscribersStore(subscribersTableName, sess)
+	subscribers.Logger = logger
 	notifications := db.NewNotificationsStore(notificationsTableName, sess)
+	notifications.Logger = logger
 
 	router := http.NewServeMux()
 	newsletter := &api.AdminResource{
-		APIToken:      apiToken,
-		Subscribers:   subscribers,
-		Notifications: notifications,
-		Newsletters:   make(map[string]bool),
+		APIToken:        apiToken,
+		Subscribers:     subscribers,
+		Notifications:   notifications,
+		Newsletters:     config.NewsletterRegistry(sess, logger),
+		ErasureSalt:     config.ErasureSalt(),
+		Metrics:         api.NewMetrics(),
+		Logger:          logger,
+		EmailNormalizer: config.EmailNormalizer(),
+	}
+
+	if eventsTableName != "" {
+		newsletter.Events = db.NewEventsStore(eventsTableName, sess)
+	}
+
+	// erased addresses must be remembered, otherwise an import adds them back
+	if erasuresTableName == "" {
+		log.Fatal("ERASURES_TABLE is required")
+	}
+	newsletter.Erasures = db.NewErasuresStore(erasuresTableName, sess)
+
+	if deadLettersTableName != "" {
+		newsletter.DeadLetters = db.NewDeadLettersStore(deadLettersTableName, sess)
+	}
+
+	if apiKeysTableName != "" {
+		apiKeys := db.NewAPIKeysStore(apiKeysTableName, sess)
+		apiKeys.Logger = logger
+		newsletter.APIKeys = apiKeys
 	}
+	publisher = config.WebhookPublisher(newsletter.DeadLetters, logger)
+	newsletter.Publisher = publisher
 
-	sn := strings.Split(supportedNewsletters, ";")
-	newsletter.AddNewsletters(sn)
+	config.SeedNewsletters(newsletter.Newsletters, strings.Split(supportedNewsletters, ";"))
 
 	newsletter.Setup(router)
 	handlerLambda = httpadapter.New(router)
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+var errInvalidKeyID = errors.New("Invalid key-id parameter")
+
+func (c *listingClient) apiKeysURL(id string) (string, error) {
+	path := common.APIKeysEndpoint
+	if id != "" {
+		path += "/" + url.PathEscape(id)
+	}
+
+	u, err := url.Parse(c.endpoint(path))
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// apiKeyRequest builds the request from the flags, newsletters are
+// semicolon-separated and the key expires at the start of the date (UTC)
+func apiKeyRequest(name, role, newsletters, expires string) (*common.APIKeyRequest, error) {
+	kr := &common.APIKeyRequest{
+		Name: name,
+		Role: role,
+	}
+
+	if newsletters != "" {
+		kr.Newsletters = strings.Split(newsletters, ";")
+	}
+
+	if expires != "" {
+		t, err := time.Parse(common.StatsDateLayout, expires)
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := common.JSONTime(t)
+		kr.ExpiresAt = &expiresAt
+	}
+
+	return kr, kr.Validate()
+}
+
+func (c *listingClient) sendAPIKeysRequest(method, endpoint string, payload []byte, expectedStatus int) ([]byte, error) {
+	req, err := http.NewRequest(method, endpoint, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth("any", c.authToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	log.Printf("Received API keys response. status=%v", resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != expectedStatus {
+		return nil, fmt.Errorf("Unexpected status code: %d, body: %v", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+// createAPIKey prints the token of the new key, it cannot be retrieved later
+func (c *listingClient) createAPIKey(kr *common.APIKeyRequest) error {
+	endpoint, err := c.apiKeysURL("")
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(kr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("About to create API key. name=%v role=%v", kr.Name, kr.Role)
+	if c.dryRun {
+		log.Println("Dry run mode. Exiting...")
+		return nil
+	}
+
+	body, err := c.sendAPIKeysRequest("POST", endpoint, payload, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+
+	created := &common.NewAPIKey{}
+	if err := json.Unmarshal(body, created); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created API key %v (%v). Save the token, it is shown only once:\n%v\n", created.Key.Name, created.Key.ID, created.Token)
+	return nil
+}
+
+func (c *listingClient) fetchAPIKeys() ([]*common.APIKey, error) {
+	endpoint, err := c.apiKeysURL("")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("About to fetch API keys. url=%v", endpoint)
+	if c.dryRun {
+		log.Println("Dry run mode. Exiting...")
+		return make([]*common.APIKey, 0), nil
+	}
+
+	body, err := c.sendAPIKeysRequest("GET", endpoint, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*common.APIKey
+	err = json.Unmarshal(body, &keys)
+	return keys, err
+}
+
+func (c *listingClient) listAPIKeys(format string) error {
+	keys, err := c.fetchAPIKeys()
+	if err != nil {
+		return err
+	}
+
+	return renderAPIKeys(os.Stdout, keys, format)
+}
+
+// revokeAPIKey deletes the key so that it cannot be used anymore
+func (c *listingClient) revokeAPIKey(id string) error {
+	if id == "" {
+		return errInvalidKeyID
+	}
+
+	endpoint, err := c.apiKeysURL(id)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("About to revoke API key. id=%v", id)
+	if c.dryRun {
+		log.Println("Dry run mode. Exiting...")
+		return nil
+	}
+
+	_, err = c.sendAPIKeysRequest("DELETE", endpoint, nil, http.StatusOK)
+	return err
+}
+
+// renderAPIKeys prints keys as JSON or as a table
+func renderAPIKeys(w io.Writer, keys []*common.APIKey, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(keys, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"ID", "Name", "Role", "Newsletters", "Created", "Expires"})
+	for _, k := range keys {
+		expires := ""
+		if k.ExpiresAt != nil {
+			expires = k.ExpiresAt.Time().Format(time.RFC3339)
+		}
+
+		table.Append([]string{
+			k.ID,
+			k.Name,
+			k.Role,
+			strings.Join(k.Newsletters, ";"),
+			k.CreatedAt.Time().Format(time.RFC3339),
+			expires,
+		})
+	}
+	table.Render()
+
+	return nil
+}
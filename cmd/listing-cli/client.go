@@ -20,6 +20,8 @@ type listingClient struct {
 	noConfirmed      bool
 	noUnsubscribed   bool
 	ignoreComplaints bool
+	locale           string
+	normalizer       *common.EmailNormalizer
 }
 
 func (c *listingClient) endpoint(e string) string {
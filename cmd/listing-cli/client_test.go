@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,7 +38,7 @@ func (s *FailingSubscriberStore) GetSubscriber(newsletter, email string) (*commo
 	return nil, errFromFailingStore
 }
 
-func (s *FailingSubscriberStore) AddSubscriber(newsletter, email, name string) error {
+func (s *FailingSubscriberStore) AddSubscriber(newsletter, email, name, locale string, attributes map[string]string) error {
 	return errFromFailingStore
 }
 
@@ -47,6 +50,14 @@ func (s *FailingSubscriberStore) Subscribers(newsletter string) (subscribers []*
 	return nil, errFromFailingStore
 }
 
+func (s *FailingSubscriberStore) QuerySubscribers(query *common.SubscribersQuery) (*common.SubscribersPage, error) {
+	return nil, errFromFailingStore
+}
+
+func (s *FailingSubscriberStore) UpdateSubscriber(newsletter, email string, update *common.SubscriberUpdate) (*common.Subscriber, error) {
+	return nil, errFromFailingStore
+}
+
 func (s *FailingSubscriberStore) AddSubscribers(subscribers []*common.Subscriber) error {
 	return errFromFailingStore
 }
@@ -59,13 +70,17 @@ func (s *FailingSubscriberStore) ConfirmSubscriber(newsletter, email string) err
 	return errFromFailingStore
 }
 
+func (s *FailingSubscriberStore) MarkReminded(newsletter, email string, remindedAt common.JSONTime) error {
+	return errFromFailingStore
+}
+
 func NewFailingStore() *FailingSubscriberStore {
 	return &FailingSubscriberStore{}
 }
 
 type DevNullMailer struct{}
 
-func (m *DevNullMailer) SendConfirmation(newsletter, email, name, confirmUrl string) error {
+func (m *DevNullMailer) SendConfirmation(newsletter *common.NewsletterConfig, email, name, locale string) error {
 	return nil
 }
 
@@ -90,7 +105,7 @@ func NewTestNewsResource(subscribers common.SubscribersStore, notifications comm
 		Subscribers:   subscribers,
 		Notifications: notifications,
 		Secret:        secret,
-		Newsletters:   make(map[string]bool),
+		Newsletters:   api.NewNewsletterRegistry(db.NewNewslettersMapStore()),
 		Mailer:        &DevNullMailer{},
 	}
 	return newsletters
@@ -101,7 +116,7 @@ func NewTestAdminResource(subscribers common.SubscribersStore, notifications com
 		Subscribers:   subscribers,
 		Notifications: notifications,
 		APIToken:      apiToken,
-		Newsletters:   make(map[string]bool),
+		Newsletters:   api.NewNewsletterRegistry(db.NewNewslettersMapStore()),
 	}
 	return admins
 }
@@ -167,8 +182,8 @@ func NewTestClient(resource api.ListingResource, p Printer) (*httptest.Server, *
 
 func TestExportSubscribedSubscribers(t *testing.T) {
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, "email1@domain.com", testName)
-	store.AddSubscriber(testNewsletter, "email2@domain.com", testName)
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "", nil)
 	ss, _ := store.Subscribers(testNewsletter)
 	alternateUnsubscribe(ss)
 
@@ -192,8 +207,8 @@ func TestExportSubscribedSubscribers(t *testing.T) {
 
 func TestExportConfirmedSubscribers(t *testing.T) {
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, "email1@domain.com", testName)
-	store.AddSubscriber(testNewsletter, "email2@domain.com", testName)
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "", nil)
 	ss, _ := store.Subscribers(testNewsletter)
 	alternateConfirm(ss)
 
@@ -215,10 +230,41 @@ func TestExportConfirmedSubscribers(t *testing.T) {
 	}
 }
 
+func TestExportSubscribersByLocale(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "uk", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "uk-ua", nil)
+	store.AddSubscriber(testNewsletter, "email3@domain.com", testName, "de", nil)
+	store.AddSubscriber(testNewsletter, "email4@domain.com", testName, "", nil)
+
+	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+
+	p := NewRawTestPrinter()
+	srv, cli := NewTestClient(nr, p)
+	defer srv.Close()
+
+	cli.locale = "uk"
+	err := cli.export(testNewsletter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.subscribers) != 2 {
+		t.Errorf("Unexpected number of subscribers: %v", len(p.subscribers))
+	}
+
+	for _, s := range p.subscribers {
+		if !strings.HasPrefix(s.Locale, "uk") {
+			t.Errorf("Unexpected locale exported: %v", s.Locale)
+		}
+	}
+}
+
 func TestExportAllSubscribers(t *testing.T) {
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, "email1@domain.com", testName)
-	store.AddSubscriber(testNewsletter, "email2@domain.com", testName)
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "", nil)
 
 	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -237,6 +283,36 @@ func TestExportAllSubscribers(t *testing.T) {
 	}
 }
 
+func TestExportFollowsCursors(t *testing.T) {
+	// more than fits into one page of the admin API
+	const count = 2500
+	store := db.NewSubscribersMapStore()
+	for i := 0; i < count; i++ {
+		store.AddSubscriber(testNewsletter, fmt.Sprintf("email%v@domain.com", i), testName, "", nil)
+	}
+
+	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+
+	p := NewRawTestPrinter()
+	srv, cli := NewTestClient(nr, p)
+	defer srv.Close()
+
+	err := cli.export(testNewsletter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emails := make(map[string]bool)
+	for _, s := range p.subscribers {
+		emails[s.Email] = true
+	}
+
+	if len(p.subscribers) != count || len(emails) != count {
+		t.Errorf("Unexpected number of subscribers. count=%v unique=%v", len(p.subscribers), len(emails))
+	}
+}
+
 func SubscribeSuite(t *testing.T, store common.SubscribersStore, dryRun bool) {
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -278,7 +354,7 @@ func TestSubscribe(t *testing.T) {
 
 func UnsubscribeSuite(t *testing.T, dryRun bool) {
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -319,7 +395,7 @@ func TestUnsubscribe(t *testing.T) {
 
 func TestExportEmptyNewsletter(t *testing.T) {
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -336,7 +412,7 @@ func TestExportEmptyNewsletter(t *testing.T) {
 
 func TestExportDryRun(t *testing.T) {
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -358,9 +434,9 @@ func TestExportDryRun(t *testing.T) {
 
 func ExportSubscribersComplaintsSuite(t *testing.T, p Printer, ignoreComplaints bool) {
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, "email1@domain.com", testName)
-	store.AddSubscriber(testNewsletter, "email2@domain.com", testName)
-	store.AddSubscriber(testNewsletter, "email3@domain.com", testName)
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email3@domain.com", testName, "", nil)
 
 	complaints := db.NewNotificationsMapStore()
 	complaints.AddBounce("email1@domain.com", "no-reply@newsletter.com", false /*is transient*/)
@@ -481,7 +557,8 @@ func ImportSubscribersSuite(t *testing.T, dryRun bool) {
     "email": "email7@domain.com",
     "created_at": "2019-12-28T02:42:23Z",
     "unsubscribed_at": "1970-01-01T00:00:01Z",
-    "confirmed_at": "2019-12-26T18:50:12Z"
+    "confirmed_at": "2019-12-26T18:50:12Z",
+    "attributes": {"country": "UA", "source": "blog"}
   },
   {
     "name": "",
@@ -514,6 +591,17 @@ func ImportSubscribersSuite(t *testing.T, dryRun bool) {
 	if store.Count() != expectedCount {
 		t.Errorf("Wrong number of items in store. actual=%v expected=%v dry_run=%v", store.Count(), expectedCount, dryRun)
 	}
+
+	if !dryRun {
+		s, err := store.GetSubscriber(testNewsletter, "email7@domain.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.Attributes["country"] != "UA" || s.Attributes["source"] != "blog" {
+			t.Errorf("Attributes were not imported: %v", s.Attributes)
+		}
+	}
 }
 
 func TestImportSubscribers(t *testing.T) {
@@ -556,9 +644,9 @@ func TestImportSubscribersMalformedJson(t *testing.T) {
 
 func DeleteSubscribersSuite(t *testing.T, dryRun bool) {
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, "email7@domain.com", testName)
-	store.AddSubscriber(testNewsletter, "email8@domain.com", testName)
-	store.AddSubscriber(testNewsletter, "foo@bar.com", testName)
+	store.AddSubscriber(testNewsletter, "email7@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email8@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "foo@bar.com", testName, "", nil)
 	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
 
@@ -613,3 +701,350 @@ func TestDeleteSubscribers(t *testing.T) {
 func TestDeleteSubscribersDryRun(t *testing.T) {
 	DeleteSubscribersSuite(t, true /*dry run*/)
 }
+
+func TestStructToMapAttributes(t *testing.T) {
+	se := &common.SubscriberEx{
+		Email:      testEmail,
+		Attributes: map[string]string{"source": "blog", "country": "UA"},
+	}
+
+	m := structToMap(se)
+	if m["Attributes"] != "country=UA;source=blog" {
+		t.Errorf("Unexpected attributes value: %v", m["Attributes"])
+	}
+}
+
+// LegacySubscribersStore keeps records with not normalized emails that
+// were added before normalization
+type LegacySubscribersStore struct {
+	*db.SubscribersMapStore
+	legacy []*common.Subscriber
+}
+
+func (s *LegacySubscribersStore) Subscribers(newsletter string) ([]*common.Subscriber, error) {
+	subscribers, err := s.SubscribersMapStore.Subscribers(newsletter)
+	return append(subscribers, s.legacy...), err
+}
+
+func (s *LegacySubscribersStore) QuerySubscribers(query *common.SubscribersQuery) (*common.SubscribersPage, error) {
+	subscribers, err := s.Subscribers(query.Newsletter)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Page(subscribers)
+}
+
+func (s *LegacySubscribersStore) DeleteSubscribers(keys []*common.SubscriberKey) error {
+	legacy := make([]*common.Subscriber, 0, len(s.legacy))
+	for _, l := range s.legacy {
+		deleted := false
+		for _, k := range keys {
+			if k.Newsletter == l.Newsletter && k.Email == l.Email {
+				deleted = true
+			}
+		}
+
+		if !deleted {
+			legacy = append(legacy, l)
+		}
+	}
+	s.legacy = legacy
+
+	return s.SubscribersMapStore.DeleteSubscribers(keys)
+}
+
+func DedupeSuite(t *testing.T, dryRun bool) {
+	store := &LegacySubscribersStore{SubscribersMapStore: db.NewSubscribersMapStore()}
+	store.AddSubscriber(testNewsletter, testEmail, "", "", nil)
+	store.AddSubscriber(testNewsletter, "other@bar.com", testName, "", nil)
+	created := time.Now().Add(-24 * time.Hour)
+	store.legacy = []*common.Subscriber{
+		{
+			Newsletter:     testNewsletter,
+			Email:          "Foo@Bar.com",
+			Name:           testName,
+			CreatedAt:      common.JSONTime(created),
+			ConfirmedAt:    common.JSONTime(created.Add(time.Hour)),
+			UnsubscribedAt: incorrectTime,
+		},
+		{
+			Newsletter:     testNewsletter,
+			Email:          "Single@Bar.com",
+			CreatedAt:      common.JSONTime(created),
+			ConfirmedAt:    incorrectTime,
+			UnsubscribedAt: incorrectTime,
+		},
+	}
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+
+	p := NewRawTestPrinter()
+	srv, cli := NewTestClient(ar, p)
+	defer srv.Close()
+
+	cli.dryRun = dryRun
+	cli.normalizer = &common.EmailNormalizer{LowercaseLocal: true}
+	ar.EmailNormalizer = cli.normalizer
+	if err := cli.dedupe(testNewsletter); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.subscribers) != 2 {
+		t.Fatalf("Unexpected number of merged subscribers %v", len(p.subscribers))
+	}
+
+	expectedLegacy := 0
+	if dryRun {
+		expectedLegacy = 2
+	}
+
+	if len(store.legacy) != expectedLegacy {
+		t.Errorf("Unexpected number of legacy records. actual=%v expected=%v", len(store.legacy), expectedLegacy)
+	}
+
+	if dryRun {
+		return
+	}
+
+	if store.Count() != 3 {
+		t.Errorf("Unexpected number of subscribers %v", store.Count())
+	}
+
+	s, err := store.GetSubscriber(testNewsletter, testEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Confirmed() || s.Name != testName {
+		t.Errorf("Duplicates are not merged. subscriber=%+v", s)
+	}
+
+	if _, err := store.GetSubscriber(testNewsletter, "single@bar.com"); err != nil {
+		t.Errorf("Legacy email is not normalized")
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	DedupeSuite(t, false /*dry run*/)
+}
+
+func TestDedupeDryRun(t *testing.T) {
+	DedupeSuite(t, true /*dry run*/)
+}
+
+func TestStats(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "", nil)
+	ss, _ := store.Subscribers(testNewsletter)
+	alternateConfirm(ss)
+
+	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+
+	srv, cli := NewTestClient(nr, NewRawTestPrinter())
+	defer srv.Close()
+
+	stats, err := cli.fetchStats(testNewsletter, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats.Newsletters) != 1 || stats.Newsletters[0].Total != 2 || stats.Newsletters[0].Confirmed != 1 {
+		t.Fatalf("Unexpected stats %+v", stats.Newsletters)
+	}
+
+	for _, format := range []string{"table", "json"} {
+		var b bytes.Buffer
+		if err := renderStats(&b, stats, format); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(b.String(), testNewsletter) || !strings.Contains(b.String(), stats.To) {
+			t.Errorf("Stats are not rendered. format=%v output=%v", format, b.String())
+		}
+	}
+}
+
+func TestStatsDryRun(t *testing.T) {
+	nr := NewTestAdminResource(NewFailingStore(), db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+
+	srv, cli := NewTestClient(nr, NewRawTestPrinter())
+	defer srv.Close()
+
+	cli.dryRun = true
+	stats, err := cli.fetchStats(testNewsletter, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats.Newsletters) != 0 {
+		t.Errorf("Stats are fetched in dry run mode")
+	}
+}
+
+func TestGetSubscriber(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+
+	p := NewRawTestPrinter()
+	srv, cli := NewTestClient(nr, p)
+	defer srv.Close()
+
+	if err := cli.getSubscriber(testNewsletter, testEmail); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.subscribers) != 1 || p.subscribers[0].Name != testName {
+		t.Errorf("Unexpected subscribers %v", p.subscribers)
+	}
+
+	if err := cli.getSubscriber(testNewsletter, "other@bar.com"); err == nil {
+		t.Errorf("Missing subscriber is fetched")
+	}
+}
+
+func UpdateSubscriberSuite(t *testing.T, dryRun bool) {
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", map[string]string{"country": "UA"})
+	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+
+	p := NewRawTestPrinter()
+	srv, cli := NewTestClient(nr, p)
+	defer srv.Close()
+
+	name, confirmed := "Bar", true
+	attributes, _ := parseAttributes("source=blog")
+	patch := &common.SubscriberPatch{Name: &name, Confirmed: &confirmed, Attributes: attributes}
+
+	time.Sleep(10 * time.Nanosecond)
+	cli.dryRun = dryRun
+	if err := cli.updateSubscriber(testNewsletter, testEmail, patch); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := store.GetSubscriber(testNewsletter, testEmail)
+	updated := s.Name == name && s.Confirmed() && s.Attributes["source"] == "blog" && s.Attributes["country"] == ""
+	if updated == dryRun {
+		t.Errorf("Unexpected subscriber. dry_run=%v subscriber=%+v", dryRun, s)
+	}
+
+	if !dryRun && len(p.subscribers) != 1 {
+		t.Errorf("Updated subscriber is not printed")
+	}
+}
+
+func TestUpdateSubscriber(t *testing.T) {
+	UpdateSubscriberSuite(t, false /*dry run*/)
+}
+
+func TestUpdateSubscriberDryRun(t *testing.T) {
+	UpdateSubscriberSuite(t, true /*dry run*/)
+}
+
+func TestParseAttributes(t *testing.T) {
+	attributes, err := parseAttributes("country=UA;source=blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if formatAttributes(attributes) != "country=UA;source=blog" {
+		t.Errorf("Unexpected attributes %v", attributes)
+	}
+
+	if _, err := parseAttributes("country"); err == nil {
+		t.Errorf("Invalid attributes are parsed")
+	}
+}
+
+func APIKeysSuite(t *testing.T, dryRun bool) {
+	store := db.NewAPIKeysMapStore()
+	nr := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.APIKeys = store
+	nr.AddNewsletters([]string{testNewsletter})
+
+	srv, cli := NewTestClient(nr, NewRawTestPrinter())
+	defer srv.Close()
+
+	kr, err := apiKeyRequest("importer", common.RoleImport, testNewsletter, "2100-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cli.dryRun = dryRun
+	if err := cli.createAPIKey(kr); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := cli.fetchAPIKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dryRun {
+		if len(keys) != 0 {
+			t.Errorf("API key is created in dry run mode")
+		}
+		return
+	}
+
+	if len(keys) != 1 || keys[0].Name != "importer" || keys[0].ExpiresAt == nil || keys[0].Newsletters[0] != testNewsletter {
+		t.Fatalf("Unexpected keys %v", keys)
+	}
+
+	for _, format := range []string{"table", "json"} {
+		var b bytes.Buffer
+		if err := renderAPIKeys(&b, keys, format); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(b.String(), keys[0].ID) {
+			t.Errorf("API keys are not rendered. format=%v output=%v", format, b.String())
+		}
+	}
+
+	if err := cli.revokeAPIKey(keys[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if ks, _ := store.APIKeys(); len(ks) != 0 {
+		t.Errorf("API key is not revoked")
+	}
+
+	if err := cli.revokeAPIKey(keys[0].ID); err == nil {
+		t.Errorf("Missing API key is revoked")
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	APIKeysSuite(t, false /*dry run*/)
+}
+
+func TestAPIKeysDryRun(t *testing.T) {
+	APIKeysSuite(t, true /*dry run*/)
+}
+
+func TestAPIKeyRequest(t *testing.T) {
+	kr, err := apiKeyRequest("reader", common.RoleReadOnly, "a;b", "2020-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(kr.Newsletters) != 2 || kr.ExpiresAt.Time() != time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("Unexpected request %+v", kr)
+	}
+
+	if _, err := apiKeyRequest("reader", "owner", "", ""); err == nil {
+		t.Errorf("Unknown role is accepted")
+	}
+
+	if _, err := apiKeyRequest("reader", common.RoleReadOnly, "", "31.12.2020"); err == nil {
+		t.Errorf("Invalid date is accepted")
+	}
+}
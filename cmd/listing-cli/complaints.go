@@ -49,12 +49,8 @@ func (c *listingClient) updateComplaints() error {
 		return err
 	}
 
-	for _, ct := range complaints {
-		if ct.Notification == common.SoftBounceType {
-			continue
-		}
-
-		c.complaints[ct.Email] = true
+	for email := range common.SuppressedEmails(complaints) {
+		c.complaints[email] = true
 	}
 
 	return nil
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"sort"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// duplicates groups subscribers by canonical email. Only groups that
+// have to be changed are returned: with several records or with one
+// record that is stored under not canonical email.
+func (c *listingClient) duplicates(subscribers []*common.Subscriber) map[string][]*common.Subscriber {
+	groups := make(map[string][]*common.Subscriber)
+
+	for _, s := range subscribers {
+		email, err := c.normalizer.Normalize(s.Email)
+		if err != nil {
+			log.Printf("Skipping invalid email. email=%v err=%v", s.Email, err)
+			continue
+		}
+
+		groups[email] = append(groups[email], s)
+	}
+
+	for email, group := range groups {
+		if len(group) == 1 && group[0].Email == email {
+			delete(groups, email)
+		}
+	}
+
+	return groups
+}
+
+func (c *listingClient) deleteExactURL() (string, error) {
+	u, err := url.Parse(c.endpoint(common.SubscribersEndpoint))
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(common.ParamExact, "true")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// dedupe merges subscribers of the newsletter whose emails have the same
+// canonical form. Merged records are imported with canonical emails and
+// the records with other emails are deleted.
+func (c *listingClient) dedupe(newsletter string) error {
+	if newsletter == "" {
+		return errInvalidNewsletter
+	}
+
+	endpoint, err := c.subscribersURL(newsletter)
+	if err != nil {
+		return err
+	}
+
+	// subscribers are fetched even in dry run to report duplicates
+	ss, err := c.getSubscribers(endpoint)
+	if err != nil {
+		return err
+	}
+
+	groups := c.duplicates(ss)
+
+	emails := make([]string, 0, len(groups))
+	for email := range groups {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	merged := make([]*common.Subscriber, 0, len(groups))
+	keys := make([]*common.SubscriberKey, 0)
+
+	for _, email := range emails {
+		group := groups[email]
+		m := common.MergeSubscribers(group)
+		m.Email = email
+		merged = append(merged, m)
+		c.printer.Append(m)
+
+		for _, s := range group {
+			log.Printf("Found duplicate. email=%v canonical=%v", s.Email, email)
+			if s.Email != email {
+				keys = append(keys, &common.SubscriberKey{Newsletter: s.Newsletter, Email: s.Email})
+			}
+		}
+	}
+	c.printer.Render()
+
+	log.Printf("Found duplicates. subscribers=%v merged=%v deleted=%v", len(ss), len(merged), len(keys))
+	if len(merged) == 0 || c.dryRun {
+		return nil
+	}
+
+	importEndpoint, err := c.importURL()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	// merged records are saved before the duplicates are deleted
+	if err := c.sendImportRequest(importEndpoint, payload); err != nil {
+		return err
+	}
+
+	deleteEndpoint, err := c.deleteExactURL()
+	if err != nil {
+		return err
+	}
+
+	payload, err = json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("About to delete duplicates. count=%v", len(keys))
+	return c.sendDeleteRequest(deleteEndpoint, payload)
+}
@@ -3,13 +3,20 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
 
 	"github.com/ribtoks/listing/pkg/common"
 )
 
+// exportPageSize is the maximum page size of the API
+const exportPageSize = 1000
+
 var (
 	errInvalidNewsletter = errors.New("Invalid newsletter parameter")
 	emptySubscribers     []*common.Subscriber
@@ -22,23 +29,71 @@ func (c *listingClient) fetchSubscribers(url string) ([]*common.Subscriber, erro
 		return emptySubscribers, nil
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	return c.getSubscribers(url)
+}
+
+// getSubscribers fetches all pages of subscribers following the cursors
+func (c *listingClient) getSubscribers(endpoint string) ([]*common.Subscriber, error) {
+	ss := make([]*common.Subscriber, 0)
+	cursor := ""
+
+	for {
+		page, next, err := c.getSubscribersPage(endpoint, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		ss = append(ss, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	return ss, nil
+}
+
+// getSubscribersPage returns subscribers of one page and the cursor
+// of the next page
+func (c *listingClient) getSubscribersPage(endpoint, cursor string) ([]*common.Subscriber, string, error) {
+	u, err := url.Parse(endpoint)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	q := u.Query()
+	q.Set(common.ParamLimit, strconv.Itoa(exportPageSize))
+	if cursor != "" {
+		q.Set(common.ParamCursor, cursor)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, "", err
 	}
 
 	req.SetBasicAuth("any", c.authToken)
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
+	defer resp.Body.Close()
 	log.Printf("Received subscribers response. status=%v", resp.StatusCode)
 
-	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("Unexpected status code: %d, body: %v", resp.StatusCode, string(body))
+	}
+
 	ss := make([]*common.Subscriber, 0)
 	err = json.NewDecoder(resp.Body).Decode(&ss)
-	return ss, nil
+	if err != nil {
+		return nil, "", err
+	}
+
+	return ss, resp.Header.Get(common.HeaderNextCursor), nil
 }
 
 func (c *listingClient) isSubscriberOK(s *common.Subscriber) bool {
@@ -57,6 +112,11 @@ func (c *listingClient) isSubscriberOK(s *common.Subscriber) bool {
 		return false
 	}
 
+	if c.locale != "" && !common.MatchLocale(s.Locale, c.locale) {
+		log.Printf("Skipping subscriber with other locale. locale=%v", s.Locale)
+		return false
+	}
+
 	if _, ok := c.complaints[s.Email]; ok {
 		log.Printf("Skipping bounced or complained subscriber. email=%v", s.Email)
 		return false
@@ -10,18 +10,22 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
 )
 
 var (
-	modeFlag             = flag.String("mode", "", "Execution mode: subscribe|unsubscribe|export|import|delete")
+	modeFlag             = flag.String("mode", "", "Execution mode: subscribe|unsubscribe|export|import|delete|dedupe|stats|get|update|create-key|list-keys|revoke-key")
 	urlFlag              = flag.String("url", "", "Base URL to the listing API")
-	emailFlag            = flag.String("email", "", "Email for subscribe|unsubscribe")
+	emailFlag            = flag.String("email", "", "Email for subscribe|unsubscribe|get|update")
 	authTokenFlag        = flag.String("auth-token", "", "Auth token for admin access")
 	secretFlag           = flag.String("secret", "", "Secret for email salt")
-	newsletterFlag       = flag.String("newsletter", "", "Newsletter for subscribe|unsubscribe")
-	formatFlag           = flag.String("format", "table", "Ouput format of subscribers: csv|tsv|table|raw|yaml")
-	nameFlag             = flag.String("name", "", "(optional) Name for subscribe")
+	newsletterFlag       = flag.String("newsletter", "", "Newsletter for subscribe|unsubscribe|get|update|stats (semicolon-separated list for create-key)")
+	formatFlag           = flag.String("format", "table", "Ouput format of subscribers: csv|tsv|table|raw|yaml (table|json for stats|list-keys)")
+	nameFlag             = flag.String("name", "", "(optional) Name for subscribe|update (required for create-key)")
+	localeFlag           = flag.String("locale", "", "(optional) Export only subscribers with this locale (e.g. uk)")
 	logPathFlag          = flag.String("l", "listing-cli.log", "Absolute path to log file")
 	stdoutFlag           = flag.Bool("stdout", false, "Log to stdout and to logfile")
 	helpFlag             = flag.Bool("help", false, "Print help")
@@ -30,6 +34,17 @@ var (
 	noConfirmedFlag      = flag.Bool("no-confirmed", false, "Do not export confirmed emails")
 	noUnsubscribedFlag   = flag.Bool("no-unsubscribed", false, "Do not export unsubscribed emails")
 	ignoreComplaintsFlag = flag.Bool("ignore-complaints", false, "Ignore bounces and complaints for export")
+	lowercaseFlag        = flag.Bool("lowercase-local", false, "Ignore case of the local part for dedupe (same as NORMALIZE_LOWERCASE_LOCAL)")
+	gmailDotsFlag        = flag.Bool("gmail-dots", false, "Ignore dots in Gmail addresses for dedupe (same as NORMALIZE_GMAIL_DOTS)")
+	plusTagsFlag         = flag.Bool("plus-tags", false, "Ignore +tag in addresses for dedupe (same as NORMALIZE_PLUS_TAGS)")
+	fromFlag             = flag.String("from", "", "(optional) First date of daily stats (e.g. 2020-01-31)")
+	toFlag               = flag.String("to", "", "(optional) Last date of daily stats, today by default")
+	confirmedFlag        = flag.String("confirmed", "", "(optional) Confirmed state for update: true|false")
+	unsubscribedFlag     = flag.String("unsubscribed", "", "(optional) Unsubscribed state for update: true|false")
+	attributesFlag       = flag.String("attributes", "", "(optional) Replace attributes for update (e.g. country=UA;source=blog)")
+	roleFlag             = flag.String("role", "", "Role of the key for create-key: read-only|import|delete|admin")
+	expiresFlag          = flag.String("expires", "", "(optional) Expiration date of the key for create-key (e.g. 2020-12-31)")
+	keyIDFlag            = flag.String("key-id", "", "ID of the key for revoke-key")
 )
 
 const (
@@ -40,6 +55,13 @@ const (
 	modeImport      = "import"
 	modeDelete      = "delete"
 	modeFilter      = "filter"
+	modeDedupe      = "dedupe"
+	modeStats       = "stats"
+	modeGet         = "get"
+	modeUpdate      = "update"
+	modeCreateKey   = "create-key"
+	modeListKeys    = "list-keys"
+	modeRevokeKey   = "revoke-key"
 )
 
 func main() {
@@ -71,6 +93,12 @@ func main() {
 		noConfirmed:      *noConfirmedFlag,
 		noUnsubscribed:   *noUnsubscribedFlag,
 		ignoreComplaints: *ignoreComplaintsFlag,
+		locale:           *localeFlag,
+		normalizer: &common.EmailNormalizer{
+			LowercaseLocal: *lowercaseFlag,
+			GmailDots:      *gmailDotsFlag,
+			PlusTags:       *plusTagsFlag,
+		},
 	}
 
 	switch *modeFlag {
@@ -101,6 +129,42 @@ func main() {
 			bytes, _ := ioutil.ReadAll(os.Stdin)
 			err = client.deleteSubscribers(bytes)
 		}
+	case modeDedupe:
+		{
+			err = client.dedupe(*newsletterFlag)
+		}
+	case modeStats:
+		{
+			err = client.stats(*newsletterFlag, *fromFlag, *toFlag, *formatFlag)
+		}
+	case modeGet:
+		{
+			err = client.getSubscriber(*newsletterFlag, *emailFlag)
+		}
+	case modeUpdate:
+		{
+			var patch *common.SubscriberPatch
+			patch, err = subscriberPatch()
+			if err == nil {
+				err = client.updateSubscriber(*newsletterFlag, *emailFlag, patch)
+			}
+		}
+	case modeCreateKey:
+		{
+			var kr *common.APIKeyRequest
+			kr, err = apiKeyRequest(*nameFlag, *roleFlag, *newsletterFlag, *expiresFlag)
+			if err == nil {
+				err = client.createAPIKey(kr)
+			}
+		}
+	case modeListKeys:
+		{
+			err = client.listAPIKeys(*formatFlag)
+		}
+	case modeRevokeKey:
+		{
+			err = client.revokeAPIKey(*keyIDFlag)
+		}
 	default:
 		fmt.Printf("Mode %v is not supported yet", *modeFlag)
 	}
@@ -136,7 +200,7 @@ func parseFlags() (err error) {
 	switch *modeFlag {
 	case "":
 		err = errors.New("Mode is required")
-	case modeDelete, modeExport, modeImport, modeSubscribe, modeUnsubscribe, modeFilter:
+	case modeDelete, modeExport, modeImport, modeSubscribe, modeUnsubscribe, modeFilter, modeDedupe, modeStats, modeGet, modeUpdate, modeCreateKey, modeListKeys, modeRevokeKey:
 		err = nil
 	default:
 		err = fmt.Errorf("Mode %v is not supported", *modeFlag)
@@ -160,7 +224,7 @@ func parseFlags() (err error) {
 	}
 
 	switch *modeFlag {
-	case modeExport, modeUnsubscribe, modeFilter:
+	case modeExport, modeUnsubscribe, modeFilter, modeGet, modeUpdate:
 		if *secretFlag == "" {
 			err = errors.New("Secret flag is required")
 		}
@@ -170,11 +234,47 @@ func parseFlags() (err error) {
 	}
 
 	switch *modeFlag {
-	case modeExport, modeImport, modeDelete:
+	case modeExport, modeImport, modeDelete, modeDedupe, modeStats, modeGet, modeUpdate, modeCreateKey, modeListKeys, modeRevokeKey:
 		if *authTokenFlag == "" {
 			err = errors.New("Auth token is required")
 		}
 	}
+	if err != nil {
+		return
+	}
+
+	if *modeFlag == modeStats && *newsletterFlag == "" {
+		err = errors.New("Newsletter flag is required")
+	}
+	return
+}
+
+// subscriberPatch returns the patch with fields from the flags set in
+// the command line
+func subscriberPatch() (patch *common.SubscriberPatch, err error) {
+	patch = &common.SubscriberPatch{}
+
+	flag.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+
+		switch f.Name {
+		case "name":
+			patch.Name = nameFlag
+		case "attributes":
+			patch.Attributes, err = parseAttributes(*attributesFlag)
+		case "confirmed":
+			var confirmed bool
+			confirmed, err = strconv.ParseBool(*confirmedFlag)
+			patch.Confirmed = &confirmed
+		case "unsubscribed":
+			var unsubscribed bool
+			unsubscribed, err = strconv.ParseBool(*unsubscribedFlag)
+			patch.Unsubscribed = &unsubscribed
+		}
+	})
+
 	return
 }
 
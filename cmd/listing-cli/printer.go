@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -45,12 +46,30 @@ func structToMap(cs *common.SubscriberEx) map[string]string {
 		case common.JSONTime:
 			v = f.Interface().(common.JSONTime).String()
 			v = strings.Trim(v, `"`)
+		case map[string]string:
+			v = formatAttributes(f.Interface().(map[string]string))
 		}
 		values[typeOfT.Field(i).Name] = v
 	}
 	return values
 }
 
+// formatAttributes prints attributes as "key=value" pairs sorted by key
+func formatAttributes(attributes map[string]string) string {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+attributes[k])
+	}
+
+	return strings.Join(pairs, ";")
+}
+
 func mapValues(m map[string]string, fields []string) []string {
 	row := make([]string, 0, len(fields))
 	for _, k := range fields {
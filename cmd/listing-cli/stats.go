@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+func (c *listingClient) statsURL(newsletter, from, to string) (string, error) {
+	u, err := url.Parse(c.endpoint(common.StatsEndpoint))
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(common.ParamNewsletter, newsletter)
+	if from != "" {
+		q.Set(common.ParamFrom, from)
+	}
+	if to != "" {
+		q.Set(common.ParamTo, to)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// fetchStats returns stats of the newsletter
+func (c *listingClient) fetchStats(newsletter, from, to string) (*common.Stats, error) {
+	endpoint, err := c.statsURL(newsletter, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("About to fetch stats. url=%v", endpoint)
+	if c.dryRun {
+		log.Println("Dry run mode. Exiting...")
+		return &common.Stats{Newsletters: make([]*common.NewsletterStats, 0)}, nil
+	}
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth("any", c.authToken)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	log.Printf("Received stats response. status=%v", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Unexpected status code: %d, body: %v", resp.StatusCode, string(body))
+	}
+
+	stats := &common.Stats{}
+	err = json.NewDecoder(resp.Body).Decode(stats)
+	return stats, err
+}
+
+func (c *listingClient) stats(newsletter, from, to, format string) error {
+	stats, err := c.fetchStats(newsletter, from, to)
+	if err != nil {
+		return err
+	}
+
+	return renderStats(os.Stdout, stats, format)
+}
+
+// renderStats prints stats as JSON or as tables of counts and daily changes
+func renderStats(w io.Writer, stats *common.Stats, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	totals := tablewriter.NewWriter(w)
+	totals.SetHeader([]string{"Newsletter", "Total", "Confirmed", "Pending", "Unsubscribed", "Suppressed", "Conversion"})
+	for _, ns := range stats.Newsletters {
+		totals.Append([]string{
+			ns.Newsletter,
+			strconv.Itoa(ns.Total),
+			strconv.Itoa(ns.Confirmed),
+			strconv.Itoa(ns.Pending),
+			strconv.Itoa(ns.Unsubscribed),
+			strconv.Itoa(ns.Suppressed),
+			fmt.Sprintf("%.1f%%", ns.ConversionRate*100),
+		})
+	}
+	totals.Render()
+
+	fmt.Fprintf(w, "\nDaily changes from %v to %v\n", stats.From, stats.To)
+
+	daily := tablewriter.NewWriter(w)
+	daily.SetHeader([]string{"Newsletter", "Date", "Signups", "Confirmations", "Unsubscribes"})
+	for _, ns := range stats.Newsletters {
+		for _, ds := range ns.Daily {
+			daily.Append([]string{
+				ns.Newsletter,
+				ds.Date,
+				strconv.Itoa(ds.Signups),
+				strconv.Itoa(ds.Confirmations),
+				strconv.Itoa(ds.Unsubscribes),
+			})
+		}
+	}
+	daily.Render()
+
+	return nil
+}
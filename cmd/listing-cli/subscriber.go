@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+var (
+	errInvalidEmail      = errors.New("Invalid email parameter")
+	errInvalidAttributes = errors.New("Attributes must be key=value pairs separated by semicolon")
+)
+
+func (c *listingClient) subscriberURL(newsletter, email string) (string, error) {
+	u, err := url.Parse(c.endpoint(common.SubscribersEndpoint + "/" + url.PathEscape(newsletter) + "/" + url.PathEscape(email)))
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// parseAttributes is the reverse of formatAttributes, empty string
+// means no attributes
+func parseAttributes(s string) (map[string]string, error) {
+	attributes := make(map[string]string)
+	if s == "" {
+		return attributes, nil
+	}
+
+	for _, pair := range strings.Split(s, ";") {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return nil, errInvalidAttributes
+		}
+		attributes[pair[:i]] = pair[i+1:]
+	}
+
+	return attributes, nil
+}
+
+func (c *listingClient) sendSubscriberRequest(method, newsletter, email string, patch *common.SubscriberPatch) (*common.Subscriber, error) {
+	if newsletter == "" {
+		return nil, errInvalidNewsletter
+	}
+
+	if email == "" {
+		return nil, errInvalidEmail
+	}
+
+	endpoint, err := c.subscriberURL(newsletter, email)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	if patch != nil {
+		if payload, err = json.Marshal(patch); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("About to send subscriber request. method=%v url=%v bytes=%v", method, endpoint, len(payload))
+	if c.dryRun {
+		log.Println("Dry run mode. Exiting...")
+		return nil, nil
+	}
+
+	req, err := http.NewRequest(method, endpoint, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	if patch != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth("any", c.authToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	log.Printf("Received subscriber response. status=%v", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Unexpected status code: %d, body: %v", resp.StatusCode, string(body))
+	}
+
+	s := &common.Subscriber{}
+	err = json.NewDecoder(resp.Body).Decode(s)
+	return s, err
+}
+
+func (c *listingClient) printSubscriber(s *common.Subscriber) error {
+	if s == nil {
+		return nil
+	}
+
+	c.printer.Append(s)
+	return c.printer.Render()
+}
+
+// getSubscriber prints one subscriber of the newsletter
+func (c *listingClient) getSubscriber(newsletter, email string) error {
+	s, err := c.sendSubscriberRequest("GET", newsletter, email, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.printSubscriber(s)
+}
+
+// updateSubscriber changes only the fields set in the patch and prints
+// the updated subscriber
+func (c *listingClient) updateSubscriber(newsletter, email string, patch *common.SubscriberPatch) error {
+	s, err := c.sendSubscriberRequest("PATCH", newsletter, email, patch)
+	if err != nil {
+		return err
+	}
+
+	return c.printSubscriber(s)
+}
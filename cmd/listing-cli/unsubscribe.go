@@ -19,7 +19,7 @@ func (c *listingClient) sendUnsubscribeRequest(email, newsletter, endpoint strin
 	req, err := http.NewRequest("GET", endpoint, nil)
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, newsletter)
-	q.Add(common.ParamToken, common.Sign(c.secret, email))
+	q.Add(common.ParamToken, common.SignToken(c.secret, common.NewToken(common.PurposeUnsubscribe, newsletter, email)))
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := c.client.Do(req)
@@ -3,8 +3,10 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"html/template"
 	"log"
+	"net/url"
 	"sync"
 	"time"
 
@@ -20,6 +22,10 @@ type campaign struct {
 	subject      string
 	fromEmail    string
 	fromName     string
+	// unsubBaseURL is a base url of unsubscribe endpoint
+	unsubBaseURL string
+	// prefsBaseURL is a base url of preferences endpoint
+	prefsBaseURL string
 	rate         int
 	workersCount int
 	dryRun       bool
@@ -73,6 +79,13 @@ func (c *campaign) renderMessage(m *gomail.Message, s *common.SubscriberEx) erro
 	ctx := make(map[string]interface{})
 	ctx["Params"] = c.params
 	ctx["Recepient"] = recepient
+	if c.prefsBaseURL != "" {
+		u, err := c.preferencesURL(s)
+		if err != nil {
+			return err
+		}
+		ctx["PreferencesURL"] = u
+	}
 
 	var htmlBodyTpl bytes.Buffer
 	if err := c.htmlTemplate.Execute(&htmlBodyTpl, ctx); err != nil {
@@ -89,12 +102,44 @@ func (c *campaign) renderMessage(m *gomail.Message, s *common.SubscriberEx) erro
 	m.SetAddressHeader("From", c.fromEmail, c.fromName)
 	m.SetHeader("Subject", c.subject)
 	m.SetHeader("X-Mailer", xMailer)
+	if c.unsubBaseURL != "" {
+		u, err := c.listUnsubscribeURL(s)
+		if err != nil {
+			return err
+		}
+		// RFC 8058 one-click unsubscribe
+		m.SetHeader("List-Unsubscribe", fmt.Sprintf("<%s>", u))
+		m.SetHeader("List-Unsubscribe-Post", common.ParamListUnsubscribe+"="+common.ListUnsubscribeOneClick)
+	}
 	m.SetBody("text/plain", textBodyTpl.String())
 	m.AddAlternative("text/html", htmlBodyTpl.String())
 	log.Printf("Rendered email message. recepient=%v", s.Email)
 	return nil
 }
 
+func (c *campaign) listUnsubscribeURL(s *common.SubscriberEx) (string, error) {
+	u, err := url.Parse(c.unsubBaseURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(common.ParamNewsletter, s.Newsletter)
+	q.Set(common.ParamToken, s.Token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (c *campaign) preferencesURL(s *common.SubscriberEx) (string, error) {
+	u, err := url.Parse(c.prefsBaseURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(common.ParamToken, s.PreferencesToken)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
 func (c *campaign) sendMessages(id int) {
 	log.Printf("Started sending messages worker. id=%v", id)
 	sender, err := createSender()
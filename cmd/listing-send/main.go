@@ -27,6 +27,8 @@ var (
 	htmlTemplateFlag = flag.String("html-template", "", "Path to html email template")
 	txtTemplateFlag  = flag.String("txt-template", "", "Path to text email template")
 	paramsFlag       = flag.String("params", "params.json", "Path to file with common params")
+	unsubscribeFlag  = flag.String("unsubscribe-url", "", "(optional) Unsubscribe endpoint url for List-Unsubscribe header")
+	preferencesFlag  = flag.String("preferences-url", "", "(optional) Preferences endpoint url for .PreferencesURL in templates")
 	workersFlag      = flag.Int("workers", 2, "Number of workers to send emails")
 	listFlag         = flag.String("list", "list.json", "Path to file with email list")
 	rateFlag         = flag.Int("rate", 25, "Emails per second sending rate")
@@ -83,6 +85,8 @@ func main() {
 		subject:      *subjectFlag,
 		fromEmail:    *fromEmailFlag,
 		fromName:     *fromNameFlag,
+		unsubBaseURL: *unsubscribeFlag,
+		prefsBaseURL: *preferencesFlag,
 		rate:         *rateFlag,
 		dryRun:       *dryRunFlag,
 		messages:     make(chan *gomail.Message, 10),
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/ribtoks/listing/pkg/api"
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/config"
+	"github.com/ribtoks/listing/pkg/email"
+)
+
+var (
+	addrFlag            = flag.String("addr", config.EnvOr("LISTEN_ADDR", ":8080"), "Address of the public API")
+	adminAddrFlag       = flag.String("admin-addr", os.Getenv("ADMIN_LISTEN_ADDR"), "(optional) Separate address of the admin API")
+	adminPrefixFlag     = flag.String("admin-prefix", config.EnvOr("ADMIN_PREFIX", "/admin"), "Path prefix of the admin API on the public address")
+	tlsCertFlag         = flag.String("tls-cert", os.Getenv("TLS_CERT_FILE"), "(optional) Path to TLS certificate")
+	tlsKeyFlag          = flag.String("tls-key", os.Getenv("TLS_KEY_FILE"), "(optional) Path to TLS private key")
+	storeFlag           = flag.String("store", config.EnvOr("STORE", storeLocal), "Storage to use: local or dynamodb")
+	dataFlag            = flag.String("data", os.Getenv("DATA_FILE"), "(optional) File to keep subscribers of local store between restarts")
+	shutdownTimeoutFlag = flag.Duration("shutdown-timeout", 10*time.Second, "Time to finish active requests on shutdown")
+	helpFlag            = flag.Bool("help", false, "Print help")
+)
+
+// server runs http.Server with or without TLS
+type server struct {
+	srv      *http.Server
+	certFile string
+	keyFile  string
+}
+
+func (s *server) listen() error {
+	common.DefaultLogger.Info("Listening", "addr", s.srv.Addr, "tls", s.certFile != "")
+
+	if s.certFile != "" {
+		return s.srv.ListenAndServeTLS(s.certFile, s.keyFile)
+	}
+
+	return s.srv.ListenAndServe()
+}
+
+func newServer(addr string, handler http.Handler) *server {
+	return &server{
+		srv: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+		},
+		certFile: *tlsCertFlag,
+		keyFile:  *tlsKeyFlag,
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	if *helpFlag {
+		flag.PrintDefaults()
+		return
+	}
+
+	if (*tlsCertFlag == "") != (*tlsKeyFlag == "") {
+		log.Fatal("Both TLS certificate and key are required")
+	}
+
+	logger := config.NewLogger()
+	common.DefaultLogger = logger
+
+	// empty secret makes every token forgeable and empty API token would
+	// leave admin API without any usable key
+	secret := os.Getenv("TOKEN_SECRET")
+	if secret == "" {
+		log.Fatal("TOKEN_SECRET is required")
+	}
+
+	apiToken := os.Getenv("API_TOKEN")
+	if apiToken == "" {
+		log.Fatal("API_TOKEN is required")
+	}
+
+	erasureSalt := config.ErasureSalt()
+
+	emailFrom := os.Getenv("EMAIL_FROM")
+	templatesDir := os.Getenv("TEMPLATES_DIR")
+	supportedNewsletters := os.Getenv("SUPPORTED_NEWSLETTERS")
+	subscriberAttributes := os.Getenv("SUBSCRIBER_ATTRIBUTES")
+	rateLimitWindow, ok := config.DurationEnv("RATE_LIMIT_WINDOW")
+	if !ok {
+		rateLimitWindow = 1 * time.Hour
+	}
+	resendCooldown, ok := config.DurationEnv("RESEND_COOLDOWN")
+	if !ok {
+		resendCooldown = 15 * time.Minute
+	}
+
+	var sess *session.Session
+	if *storeFlag == storeDynamoDB || emailFrom != "" {
+		var err error
+		sess, err = session.NewSession(&aws.Config{
+			Region: aws.String(os.Getenv("AWS_REGION")),
+		})
+		if err != nil {
+			log.Fatalf("Failed to create AWS session. err=%v", err)
+		}
+	}
+
+	var st *stores
+	switch *storeFlag {
+	case storeDynamoDB:
+		st = dynamoDBStores(sess)
+	case storeLocal:
+		st = localStores()
+	default:
+		log.Fatalf("Unknown store. value=%v", *storeFlag)
+	}
+
+	// confirmation links are only logged without sender address
+	var mailer common.Mailer = &email.LogMailer{Secret: secret, Logger: logger}
+	if emailFrom != "" {
+		sm := &email.SESMailer{
+			Svc:           ses.New(sess),
+			Sender:        emailFrom,
+			Secret:        secret,
+			DefaultLocale: os.Getenv("DEFAULT_LOCALE"),
+			Logger:        logger,
+		}
+
+		if templatesDir != "" {
+			var err error
+			if sm.Templates, err = email.LoadTemplates(templatesDir); err != nil {
+				log.Fatalf("Failed to load email templates. err=%v", err)
+			}
+		}
+		mailer = sm
+	}
+
+	publisher := config.WebhookPublisher(st.DeadLetters, logger)
+	registry := api.NewNewsletterRegistry(st.Newsletters)
+	registry.Logger = logger
+	metrics := api.NewMetrics()
+	normalizer := config.EmailNormalizer()
+
+	newsletter := &api.NewsletterResource{
+		Secret:                 secret,
+		SubscribeRedirectURL:   os.Getenv("SUBSCRIBE_REDIRECT_URL"),
+		UnsubscribeRedirectURL: os.Getenv("UNSUBSCRIBE_REDIRECT_URL"),
+		ConfirmRedirectURL:     os.Getenv("CONFIRM_REDIRECT_URL"),
+		ConfirmURL:             os.Getenv("CONFIRM_URL"),
+		PreferencesURL:         os.Getenv("PREFERENCES_URL"),
+		TokenPolicy:            config.TokenPolicy(),
+		Interstitial:           os.Getenv("INTERSTITIAL") == "true",
+		IPLimiter:              config.RateLimiter(config.IntEnv("SUBSCRIBE_IP_LIMIT"), rateLimitWindow, st.RateLimits),
+		EmailLimiter:           config.RateLimiter(config.IntEnv("SUBSCRIBE_EMAIL_LIMIT"), rateLimitWindow, st.RateLimits),
+		ResendLimiter:          config.RateLimiter(1, resendCooldown, st.RateLimits),
+		Verifiers:              config.Verifiers(secret),
+		Subscribers:            st.Subscribers,
+		Notifications:          st.Notifications,
+		Mailer:                 mailer,
+		Newsletters:            registry,
+		Events:                 st.Events,
+		ConsentVersion:         os.Getenv("CONSENT_VERSION"),
+		Erasures:               st.Erasures,
+		ErasureSalt:            erasureSalt,
+		Publisher:              publisher,
+		Metrics:                metrics,
+		MetricsToken:           os.Getenv("METRICS_TOKEN"),
+		Logger:                 logger,
+		EmailNormalizer:        normalizer,
+	}
+
+	admin := &api.AdminResource{
+		APIToken:        apiToken,
+		APIKeys:         st.APIKeys,
+		Newsletters:     registry,
+		Subscribers:     st.Subscribers,
+		Notifications:   st.Notifications,
+		Events:          st.Events,
+		Erasures:        st.Erasures,
+		ErasureSalt:     erasureSalt,
+		Publisher:       publisher,
+		DeadLetters:     st.DeadLetters,
+		Metrics:         metrics,
+		Logger:          logger,
+		EmailNormalizer: normalizer,
+	}
+
+	// both APIs share the registry
+	config.SeedNewsletters(registry, strings.Split(supportedNewsletters, ";"))
+
+	if subscriberAttributes != "" {
+		newsletter.Attributes = strings.Split(subscriberAttributes, ";")
+	}
+
+	if *storeFlag == storeLocal && *dataFlag != "" {
+		if err := loadSubscribers(st.Subscribers, *dataFlag); err != nil {
+			log.Fatalf("Failed to load subscribers. path=%v err=%v", *dataFlag, err)
+		}
+	}
+
+	router := http.NewServeMux()
+	newsletter.Setup(router)
+
+	adminRouter := http.NewServeMux()
+	admin.Setup(adminRouter)
+
+	var adminHandler http.Handler = adminRouter
+	if *adminAddrFlag == "" {
+		prefix := strings.TrimSuffix(*adminPrefixFlag, "/")
+		router.Handle(prefix+"/", http.StripPrefix(prefix, adminRouter))
+	}
+
+	var handler http.Handler = router
+	if *storeFlag == storeLocal {
+		mu := &sync.Mutex{}
+		handler = serialized(mu, handler)
+		adminHandler = serialized(mu, adminHandler)
+	}
+
+	servers := []*server{newServer(*addrFlag, handler)}
+	if *adminAddrFlag != "" {
+		servers = append(servers, newServer(*adminAddrFlag, adminHandler))
+	}
+
+	errs := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s *server) {
+			if err := s.listen(); err != http.ErrServerClosed {
+				errs <- err
+			}
+		}(s)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case sig := <-stop:
+		logger.Info("Shutting down", "signal", sig)
+	case err := <-errs:
+		logger.Error("Server failed", "err", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeoutFlag)
+	defer cancel()
+
+	for _, s := range servers {
+		if err := s.srv.Shutdown(ctx); err != nil {
+			logger.Error("Failed to shutdown server", "addr", s.srv.Addr, "err", err)
+		}
+	}
+
+	if publisher != nil {
+		publisher.Flush()
+	}
+
+	if *storeFlag == storeLocal && *dataFlag != "" {
+		if err := saveSubscribers(st.Subscribers, registry.Names(), *dataFlag); err != nil {
+			logger.Error("Failed to save subscribers", "path", *dataFlag, "err", err)
+		}
+	}
+}
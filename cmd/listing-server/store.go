@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+const (
+	storeDynamoDB = "dynamodb"
+	storeLocal    = "local"
+)
+
+// stores are shared by public and admin resources
+type stores struct {
+	Subscribers   common.SubscribersStore
+	Notifications common.NotificationsStore
+	Newsletters   common.NewslettersStore
+	Events        common.EventsStore
+	Erasures      common.ErasuresStore
+	DeadLetters   common.DeadLettersStore
+	APIKeys       common.APIKeysStore
+	RateLimits    common.RateLimitStore
+}
+
+// dynamoDBStores creates stores for the tables from the same environment
+// variables as lambdas use. Optional tables are skipped if not set.
+func dynamoDBStores(sess *session.Session) *stores {
+	s := &stores{
+		Subscribers:   db.NewSubscribersStore(os.Getenv("SUBSCRIBERS_TABLE"), sess),
+		Notifications: db.NewNotificationsStore(os.Getenv("NOTIFICATIONS_TABLE"), sess),
+		Newsletters:   db.NewNewslettersMapStore(),
+	}
+
+	if table := os.Getenv("NEWSLETTERS_TABLE"); table != "" {
+		s.Newsletters = db.NewNewslettersStore(table, sess)
+	}
+
+	if table := os.Getenv("EVENTS_TABLE"); table != "" {
+		s.Events = db.NewEventsStore(table, sess)
+	}
+
+	// erased addresses must be remembered, otherwise an import adds them back
+	erasures := os.Getenv("ERASURES_TABLE")
+	if erasures == "" {
+		log.Fatal("ERASURES_TABLE is required")
+	}
+	s.Erasures = db.NewErasuresStore(erasures, sess)
+
+	if table := os.Getenv("DEAD_LETTERS_TABLE"); table != "" {
+		s.DeadLetters = db.NewDeadLettersStore(table, sess)
+	}
+
+	if table := os.Getenv("API_KEYS_TABLE"); table != "" {
+		s.APIKeys = db.NewAPIKeysStore(table, sess)
+	}
+
+	if table := os.Getenv("RATE_LIMITS_TABLE"); table != "" {
+		s.RateLimits = db.NewRateLimitsStore(table, sess)
+	}
+
+	return s
+}
+
+// localStores creates in-memory stores. Rate limits use in-memory
+// limiters instead of the store.
+func localStores() *stores {
+	return &stores{
+		Subscribers:   db.NewSubscribersMapStore(),
+		Notifications: db.NewNotificationsMapStore(),
+		Newsletters:   db.NewNewslettersMapStore(),
+		Events:        db.NewEventsMapStore(),
+		Erasures:      db.NewErasuresMapStore(),
+		DeadLetters:   db.NewDeadLettersMapStore(),
+		APIKeys:       db.NewAPIKeysMapStore(),
+	}
+}
+
+// loadSubscribers fills the store from the file saved by saveSubscribers.
+// Missing file is not an error.
+func loadSubscribers(store common.SubscribersStore, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var subscribers []*common.Subscriber
+	if err := json.Unmarshal(data, &subscribers); err != nil {
+		return err
+	}
+
+	common.DefaultLogger.Info("Loaded subscribers", "count", len(subscribers), "path", path)
+
+	return store.AddSubscribers(subscribers)
+}
+
+// saveSubscribers writes subscribers of all newsletters to the file
+func saveSubscribers(store common.SubscribersStore, newsletters []string, path string) error {
+	subscribers := make([]*common.Subscriber, 0)
+
+	for _, newsletter := range newsletters {
+		items, err := store.Subscribers(newsletter)
+		if err != nil {
+			return err
+		}
+		subscribers = append(subscribers, items...)
+	}
+
+	data, err := json.MarshalIndent(subscribers, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to the temporary file first to not lose the data on failure
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	common.DefaultLogger.Info("Saved subscribers", "count", len(subscribers), "path", path)
+
+	return os.Rename(tmp, path)
+}
+
+// serialized handles one request at a time because in-memory stores
+// are not safe for concurrent use
+func serialized(mu *sync.Mutex, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
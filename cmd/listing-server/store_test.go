@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func TestSaveLoadSubscribers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listing-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "subscribers.json")
+
+	store := db.NewSubscribersMapStore()
+	if err := loadSubscribers(store, path); err != nil {
+		t.Fatalf("Missing file is not ignored. err=%v", err)
+	}
+
+	store.AddSubscriber("Listing1", "foo@bar.com", "Foo", "", nil)
+	s, _ := store.GetSubscriber("Listing1", "foo@bar.com")
+	// times are saved with seconds precision
+	s.ConfirmedAt = common.JSONTime(s.CreatedAt.Time().Add(1 * time.Minute))
+	store.AddSubscriber("Listing2", "bar@foo.com", "Bar", "uk", nil)
+
+	newsletters := []string{"Listing1", "Listing2"}
+
+	if err := saveSubscribers(store, newsletters, path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := db.NewSubscribersMapStore()
+	if err = loadSubscribers(loaded, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Count() != 2 {
+		t.Fatalf("Unexpected subscribers count %v", loaded.Count())
+	}
+
+	s, err = loaded.GetSubscriber("Listing1", "foo@bar.com")
+	if err != nil || !s.Confirmed() {
+		t.Errorf("Confirmation was not kept. err=%v", err)
+	}
+
+	s, err = loaded.GetSubscriber("Listing2", "bar@foo.com")
+	if err != nil || s.Locale != "uk" || s.Confirmed() {
+		t.Errorf("Pending subscriber was not kept. err=%v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -14,17 +15,28 @@ import (
 	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
 	"github.com/ribtoks/listing/pkg/api"
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/config"
 	"github.com/ribtoks/listing/pkg/db"
 	"github.com/ribtoks/listing/pkg/email"
 )
 
 var (
 	handlerLambda *httpadapter.HandlerAdapter
+	publisher     api.EventPublisher
 )
 
 // Handler is the main entry point to this lambda
 func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return handlerLambda.ProxyWithContext(ctx, req)
+	resp, err := handlerLambda.ProxyWithContext(ctx, req)
+
+	// Lambda freezes the process after the response is returned, so
+	// webhooks queued by the request are delivered before that
+	if publisher != nil {
+		publisher.Flush()
+	}
+
+	return resp, err
 }
 
 func main() {
@@ -37,6 +49,27 @@ func main() {
 	notificationsTableName := os.Getenv("NOTIFICATIONS_TABLE")
 	supportedNewsletters := os.Getenv("SUPPORTED_NEWSLETTERS")
 	emailFrom := os.Getenv("EMAIL_FROM")
+	subscriberAttributes := os.Getenv("SUBSCRIBER_ATTRIBUTES")
+	templatesDir := os.Getenv("TEMPLATES_DIR")
+	interstitial := os.Getenv("INTERSTITIAL") == "true"
+	rateLimitsTableName := os.Getenv("RATE_LIMITS_TABLE")
+	eventsTableName := os.Getenv("EVENTS_TABLE")
+	consentVersion := os.Getenv("CONSENT_VERSION")
+	erasuresTableName := os.Getenv("ERASURES_TABLE")
+	deadLettersTableName := os.Getenv("DEAD_LETTERS_TABLE")
+	ipLimit := config.IntEnv("SUBSCRIBE_IP_LIMIT")
+	emailLimit := config.IntEnv("SUBSCRIBE_EMAIL_LIMIT")
+	rateLimitWindow, ok := config.DurationEnv("RATE_LIMIT_WINDOW")
+	if !ok {
+		rateLimitWindow = 1 * time.Hour
+	}
+	resendCooldown, ok := config.DurationEnv("RESEND_COOLDOWN")
+	if !ok {
+		resendCooldown = 15 * time.Minute
+	}
+
+	logger := config.NewLogger()
+	common.DefaultLogger = logger
 
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(os.Getenv("AWS_REGION")),
@@ -47,11 +80,27 @@ func main() {
 	}
 
 	subscribers := db.NewSubscribersStore(subscribersTableName, sess)
+	subscribers.Logger = logger
 	notifications := db.NewNotificationsStore(notificationsTableName, sess)
+	notifications.Logger = logger
+
+	var rateLimits common.RateLimitStore
+	if rateLimitsTableName != "" {
+		rateLimits = db.NewRateLimitsStore(rateLimitsTableName, sess)
+	}
 	mailer := &email.SESMailer{
-		Svc:    ses.New(sess),
-		Sender: emailFrom,
-		Secret: secret,
+		Svc:           ses.New(sess),
+		Sender:        emailFrom,
+		Secret:        secret,
+		DefaultLocale: os.Getenv("DEFAULT_LOCALE"),
+		Logger:        logger,
+	}
+
+	if templatesDir != "" {
+		mailer.Templates, err = email.LoadTemplates(templatesDir)
+		if err != nil {
+			log.Fatalf("Failed to load email templates. err=%v", err)
+		}
 	}
 
 	router := http.NewServeMux()
@@ -61,14 +110,47 @@ func main() {
 		UnsubscribeRedirectURL: unsubscribeRedirectURL,
 		ConfirmRedirectURL:     confirmRedirectURL,
 		ConfirmURL:             confirmURL,
+		PreferencesURL:         os.Getenv("PREFERENCES_URL"),
+		TokenPolicy:            config.TokenPolicy(),
+		Interstitial:           interstitial,
+		IPLimiter:              config.RateLimiter(ipLimit, rateLimitWindow, rateLimits),
+		EmailLimiter:           config.RateLimiter(emailLimit, rateLimitWindow, rateLimits),
+		ResendLimiter:          config.RateLimiter(1, resendCooldown, rateLimits),
+		Verifiers:              config.Verifiers(secret),
 		Subscribers:            subscribers,
 		Notifications:          notifications,
 		Mailer:                 mailer,
-		Newsletters:            make(map[string]bool),
+		Newsletters:            config.NewsletterRegistry(sess, logger),
+		ConsentVersion:         consentVersion,
+		ErasureSalt:            config.ErasureSalt(),
+		Metrics:                api.NewMetrics(),
+		MetricsToken:           os.Getenv("METRICS_TOKEN"),
+		Logger:                 logger,
+		EmailNormalizer:        config.EmailNormalizer(),
+	}
+
+	if eventsTableName != "" {
+		newsletter.Events = db.NewEventsStore(eventsTableName, sess)
+	}
+
+	// erased addresses must be remembered, otherwise an import adds them back
+	if erasuresTableName == "" {
+		log.Fatal("ERASURES_TABLE is required")
 	}
+	newsletter.Erasures = db.NewErasuresStore(erasuresTableName, sess)
 
-	sn := strings.Split(supportedNewsletters, ";")
-	newsletter.AddNewsletters(sn)
+	var deadLetters common.DeadLettersStore
+	if deadLettersTableName != "" {
+		deadLetters = db.NewDeadLettersStore(deadLettersTableName, sess)
+	}
+	publisher = config.WebhookPublisher(deadLetters, logger)
+	newsletter.Publisher = publisher
+
+	config.SeedNewsletters(newsletter.Newsletters, strings.Split(supportedNewsletters, ";"))
+
+	if subscriberAttributes != "" {
+		newsletter.Attributes = strings.Split(subscriberAttributes, ";")
+	}
 
 	newsletter.Setup(router)
 	handlerLambda = httpadapter.New(router)
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/ribtoks/listing/pkg/api"
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/config"
+	"github.com/ribtoks/listing/pkg/db"
+	"github.com/ribtoks/listing/pkg/email"
+)
+
+const day = 24 * time.Hour
+
+var (
+	dryRunFlag = flag.Bool("dry-run", false, "Report reminders and deletions without doing them")
+)
+
+var (
+	resource    *api.NewsletterResource
+	remindAfter time.Duration
+	purgeAfter  time.Duration
+	dryRun      bool
+)
+
+// daysEnv parses number of days from the variable. Zero disables the step.
+func daysEnv(name string) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+
+	days, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Failed to parse number of days. name=%v value=%v err=%v", name, v, err)
+	}
+
+	return time.Duration(days) * day
+}
+
+// Handler is the entry point of the scheduled lambda
+func Handler(ctx context.Context, event events.CloudWatchEvent) (*api.PendingReport, error) {
+	return resource.ProcessPending(remindAfter, purgeAfter, dryRun)
+}
+
+func main() {
+	flag.Parse()
+
+	secret := os.Getenv("TOKEN_SECRET")
+	subscribersTableName := os.Getenv("SUBSCRIBERS_TABLE")
+	notificationsTableName := os.Getenv("NOTIFICATIONS_TABLE")
+	supportedNewsletters := os.Getenv("SUPPORTED_NEWSLETTERS")
+	templatesDir := os.Getenv("TEMPLATES_DIR")
+	eventsTableName := os.Getenv("EVENTS_TABLE")
+	deadLettersTableName := os.Getenv("DEAD_LETTERS_TABLE")
+	remindAfter = daysEnv("REMIND_AFTER_DAYS")
+	purgeAfter = daysEnv("PURGE_AFTER_DAYS")
+	dryRun = *dryRunFlag || os.Getenv("DRY_RUN") == "true"
+
+	logger := config.NewLogger().With("job", "pending")
+	common.DefaultLogger = logger
+
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	})
+
+	if err != nil {
+		log.Fatalf("Failed to create AWS session. err=%v", err)
+	}
+
+	subscribers := db.NewSubscribersStore(subscribersTableName, sess)
+	subscribers.Logger = logger
+	notifications := db.NewNotificationsStore(notificationsTableName, sess)
+	notifications.Logger = logger
+
+	mailer := &email.SESMailer{
+		Svc:           ses.New(sess),
+		Sender:        os.Getenv("EMAIL_FROM"),
+		Secret:        secret,
+		DefaultLocale: os.Getenv("DEFAULT_LOCALE"),
+		Logger:        logger,
+	}
+
+	if templatesDir != "" {
+		mailer.Templates, err = email.LoadTemplates(templatesDir)
+		if err != nil {
+			log.Fatalf("Failed to load email templates. err=%v", err)
+		}
+	}
+
+	resource = &api.NewsletterResource{
+		Secret:         secret,
+		ConfirmURL:     os.Getenv("CONFIRM_URL"),
+		PreferencesURL: os.Getenv("PREFERENCES_URL"),
+		Subscribers:    subscribers,
+		Notifications:  notifications,
+		Mailer:         mailer,
+		Newsletters:    config.NewsletterRegistry(sess, logger),
+		ConsentVersion: os.Getenv("CONSENT_VERSION"),
+		Logger:         logger,
+	}
+
+	if eventsTableName != "" {
+		resource.Events = db.NewEventsStore(eventsTableName, sess)
+	}
+
+	var deadLetters common.DeadLettersStore
+	if deadLettersTableName != "" {
+		deadLetters = db.NewDeadLettersStore(deadLettersTableName, sess)
+	}
+	resource.Publisher = config.WebhookPublisher(deadLetters, logger)
+
+	config.SeedNewsletters(resource.Newsletters, strings.Split(supportedNewsletters, ";"))
+
+	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		lambda.Start(Handler)
+		return
+	}
+
+	// run once from the command line and print the report
+	report, err := resource.ProcessPending(remindAfter, purgeAfter, dryRun)
+	if err != nil {
+		log.Fatalf("Failed to process pending subscribers. err=%v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to print report. err=%v", err)
+	}
+}
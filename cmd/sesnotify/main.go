@@ -11,22 +11,36 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/config"
 	"github.com/ribtoks/listing/pkg/db"
 )
 
 var (
-	store common.NotificationsStore
+	store      common.NotificationsStore
+	logger     *common.Logger
+	normalizer *common.EmailNormalizer
 )
 
+// normalize returns the address in the form subscribers are stored with,
+// so that suppression checks find its notifications
+func normalize(email string) string {
+	if normalized, err := normalizer.Normalize(email); err == nil {
+		return normalized
+	}
+
+	return email
+}
+
 func handler(ctx context.Context, snsEvent events.SNSEvent) {
-	log.Printf("Processing %v records", len(snsEvent.Records))
+	logger.Info("Processing records", "count", len(snsEvent.Records))
 
 	for _, record := range snsEvent.Records {
 		snsRecord := record.SNS
+		logger := logger.With("message_id", snsRecord.MessageID)
 		var sesMessage common.SesMessage
 		err := json.Unmarshal([]byte(snsRecord.Message), &sesMessage)
 		if err != nil {
-			log.Printf("Error parsing message: %v", err)
+			logger.Warn("Failed to parse message", "err", err)
 			continue
 		}
 
@@ -35,30 +49,34 @@ func handler(ctx context.Context, snsEvent events.SNSEvent) {
 			{
 				isTransient := sesMessage.Bounce.BounceType == "Transient"
 				for _, r := range sesMessage.Bounce.BouncedRecipients {
-					err = store.AddBounce(r.EmailAddress, sesMessage.Mail.Source, isTransient)
+					err = store.AddBounce(normalize(r.EmailAddress), sesMessage.Mail.Source, isTransient)
 					if err != nil {
-						log.Printf("Failed to add bounce: %v", err)
+						logger.Error("Failed to add bounce", "email", r.EmailAddress, "err", err)
 					}
 				}
 			}
 		case "Complaint":
 			{
-				for _, r := range sesMessage.Bounce.BouncedRecipients {
-					err = store.AddComplaint(r.EmailAddress, sesMessage.Mail.Source)
+				for _, r := range sesMessage.Complaint.ComplainedRecipients {
+					err = store.AddComplaint(normalize(r.EmailAddress), sesMessage.Mail.Source)
 					if err != nil {
-						log.Printf("Failed to add complaint: %v", err)
+						logger.Error("Failed to add complaint", "email", r.EmailAddress, "err", err)
 					}
 				}
 			}
 		default:
 			{
-				log.Printf("Unexpected message type: %v", sesMessage.NotificationType)
+				logger.Warn("Unexpected message type", "type", sesMessage.NotificationType)
 			}
 		}
 	}
 }
 
 func main() {
+	logger = config.NewLogger()
+	common.DefaultLogger = logger
+	normalizer = config.EmailNormalizer()
+
 	tableName := os.Getenv("NOTIFICATIONS_TABLE")
 
 	sess, err := session.NewSession(&aws.Config{
@@ -69,7 +87,9 @@ func main() {
 		log.Fatalf("Failed to create AWS session. err=%v", err)
 	}
 
-	store = db.NewNotificationsStore(tableName, sess)
+	notifications := db.NewNotificationsStore(tableName, sess)
+	notifications.Logger = logger
+	store = notifications
 
 	lambda.Start(handler)
 }
@@ -2,7 +2,7 @@ package api
 
 import (
 	"encoding/json"
-	"log"
+	"mime"
 	"net/http"
 	"strings"
 
@@ -13,59 +13,177 @@ import (
 type ListingResource interface {
 	Setup(router *http.ServeMux)
 	AddNewsletters(n []string)
+	AddNewsletterConfigs(configs []*common.NewsletterConfig)
 }
 
 // NewsletterResource manages http requests and data storage
-// for newsletter subscriptions
+// for newsletter subscriptions. Redirect and confirm URLs are defaults
+// for newsletters that do not override them in their configs.
 type NewsletterResource struct {
 	Secret                 string
 	SubscribeRedirectURL   string
 	UnsubscribeRedirectURL string
 	ConfirmRedirectURL     string
 	ConfirmURL             string
-	Newsletters            map[string]bool
+	PreferencesURL         string // preference center linked from emails
+	TokenPolicy            common.TokenPolicy
+	Interstitial           bool // GET confirm/unsubscribe render a page with a button to POST
+	Newsletters            *NewsletterRegistry
 	Subscribers            common.SubscribersStore
 	Notifications          common.NotificationsStore
 	Mailer                 common.Mailer
+	IPLimiter              RateLimiter
+	EmailLimiter           RateLimiter
+	ResendLimiter          RateLimiter // cooldown of confirmation resends per email
+	Verifiers              []SubmissionVerifier
+	Attributes             []string // form fields stored as subscriber attributes
+	Events                 common.EventsStore
+	ConsentVersion         string // default wording version of subscribe forms
+	Erasures               common.ErasuresStore
+	ErasureSalt            string
+	Publisher              EventPublisher
+	Metrics                *Metrics
+	MetricsToken           string // password of /metrics, endpoint is disabled if empty
+	EmailNormalizer        *common.EmailNormalizer
+	Logger                 *common.Logger
 }
 
 var _ ListingResource = (*NewsletterResource)(nil)
 
+// AdminResource manages protected http requests. Requests are authorized
+// with APIToken (admin of all newsletters) or with keys from APIKeys.
 type AdminResource struct {
-	APIToken      string
-	Newsletters   map[string]bool
-	Subscribers   common.SubscribersStore
-	Notifications common.NotificationsStore
+	APIToken        string
+	APIKeys         common.APIKeysStore
+	Newsletters     *NewsletterRegistry
+	Subscribers     common.SubscribersStore
+	Notifications   common.NotificationsStore
+	Events          common.EventsStore
+	Erasures        common.ErasuresStore
+	ErasureSalt     string
+	Publisher       EventPublisher
+	DeadLetters     common.DeadLettersStore
+	Metrics         *Metrics
+	Logger          *common.Logger
+	EmailNormalizer *common.EmailNormalizer // must use the same rules as NewsletterResource
+
+	key        *common.APIKey   // key of the current request, set by scoped
+	suppressed *suppressedCache // set by Setup and shared by scoped copies
 }
 
 var _ ListingResource = (*AdminResource)(nil)
 
 const (
 	// assume there cannot be such a huge http requests for subscription
+	// (captcha responses can take couple of kilobytes)
 	kilobyte             = 1024
 	megabyte             = 1024 * kilobyte
-	maxSubscribeBodySize = kilobyte / 2
+	maxSubscribeBodySize = 4 * kilobyte
 	maxImportBodySize    = 25 * megabyte
 	maxDeleteBodySize    = 5 * megabyte
+	maxPatchBodySize     = 64 * kilobyte
 )
 
 func (ar *AdminResource) Setup(router *http.ServeMux) {
-	router.HandleFunc(common.SubscribersEndpoint, ar.auth(ar.serveSubscribers))
-	router.HandleFunc(common.ComplaintsEndpoint, ar.auth(ar.complaints))
+	ar.suppressed = &suppressedCache{}
+
+	if ar.Metrics != nil {
+		ar.Subscribers = meterSubscribers(ar.Subscribers, ar.Metrics)
+		ar.Notifications = meterNotifications(ar.Notifications, ar.Metrics)
+		router.HandleFunc(common.MetricsEndpoint, ar.auth(globalReadAccess, ar.Metrics.serve))
+	}
+
+	router.HandleFunc(common.SubscribersEndpoint, ar.auth(subscribersAccess, ar.scoped((*AdminResource).serveSubscribers)))
+	router.HandleFunc(common.SubscribersEndpoint+"/", ar.auth(subscribersAccess, ar.scoped((*AdminResource).serveSubscriber)))
+	router.HandleFunc(common.ComplaintsEndpoint, ar.auth(complaintsAccess, ar.scoped((*AdminResource).serveComplaints)))
+	router.HandleFunc(common.EventsEndpoint, ar.auth(globalReadAccess, ar.scoped((*AdminResource).serveEvents)))
+	router.HandleFunc(common.DeadLettersEndpoint, ar.auth(globalReadAccess, ar.scoped((*AdminResource).serveDeadLetters)))
+	router.HandleFunc(common.StatsEndpoint, ar.auth(readAccess, ar.scoped((*AdminResource).serveStats)))
+	router.HandleFunc(common.NewslettersEndpoint, ar.auth(readAccess, ar.scoped((*AdminResource).serveNewsletters)))
+	router.HandleFunc(common.NewslettersEndpoint+"/", ar.auth(readAccess, ar.scoped((*AdminResource).serveNewsletter)))
+	router.HandleFunc(common.APIKeysEndpoint, ar.auth(adminAccess, ar.scoped((*AdminResource).serveAPIKeys)))
+	router.HandleFunc(common.APIKeysEndpoint+"/", ar.auth(adminAccess, ar.scoped((*AdminResource).serveAPIKey)))
+	router.HandleFunc(common.HealthEndpoint, serveHealth)
+	router.HandleFunc(common.ReadyEndpoint, serveReady(ar.Logger, ar.dependencies()))
 }
 
 func (nr *NewsletterResource) Setup(router *http.ServeMux) {
-	router.HandleFunc(common.SubscribeEndpoint, nr.method("POST", nr.subscribe))
-	router.HandleFunc(common.UnsubscribeEndpoint, nr.method("GET", nr.unsubscribe))
-	router.HandleFunc(common.ConfirmEndpoint, nr.method("GET", nr.confirm))
+	if nr.Metrics != nil {
+		nr.Subscribers = meterSubscribers(nr.Subscribers, nr.Metrics)
+		nr.Notifications = meterNotifications(nr.Notifications, nr.Metrics)
+		nr.Mailer = meterMailer(nr.Mailer, nr.Metrics)
+
+		if nr.MetricsToken != "" {
+			router.HandleFunc(common.MetricsEndpoint, nr.metricsAuth(nr.Metrics.serve))
+		}
+	}
+
+	router.HandleFunc(common.SubscribeEndpoint, nr.metered(common.SubscribeEndpoint, nr.method("POST", nr.scoped((*NewsletterResource).subscribe))))
+	router.HandleFunc(common.UnsubscribeEndpoint, nr.metered(common.UnsubscribeEndpoint, nr.scoped((*NewsletterResource).serveUnsubscribe)))
+	router.HandleFunc(common.ConfirmEndpoint, nr.metered(common.ConfirmEndpoint, nr.scoped((*NewsletterResource).serveConfirm)))
+	router.HandleFunc(common.ResendEndpoint, nr.metered(common.ResendEndpoint, nr.method("POST", nr.scoped((*NewsletterResource).resend))))
+	router.HandleFunc(common.PreferencesEndpoint, nr.scoped((*NewsletterResource).servePreferences))
+	router.HandleFunc(common.DataEndpoint, nr.method("GET", nr.scoped((*NewsletterResource).serveData)))
+	router.HandleFunc(common.EraseEndpoint, nr.method("POST", nr.scoped((*NewsletterResource).serveErase)))
+	router.HandleFunc(common.HealthEndpoint, serveHealth)
+	router.HandleFunc(common.ReadyEndpoint, serveReady(nr.Logger, nr.dependencies()))
+
+	for _, v := range nr.Verifiers {
+		if s, ok := v.(stamper); ok {
+			router.HandleFunc(common.StampEndpoint, nr.method("GET", serveStamp(s)))
+			break
+		}
+	}
 }
 
+// AddNewsletters adds newsletters missing in the store
 func (nr *NewsletterResource) AddNewsletters(n []string) {
-	for _, i := range n {
-		nr.Newsletters[i] = true
+	if err := nr.Newsletters.AddNames(n); err != nil {
+		nr.Logger.Error("Failed to add newsletters", "err", err)
+	}
+}
+
+// AddNewsletterConfigs adds newsletters missing in the store
+func (nr *NewsletterResource) AddNewsletterConfigs(configs []*common.NewsletterConfig) {
+	if err := nr.Newsletters.Add(configs); err != nil {
+		nr.Logger.Error("Failed to add newsletter configs", "err", err)
 	}
 }
 
+// config returns settings of the newsletter with defaults of the resource
+func (nr *NewsletterResource) config(newsletter string) *common.NewsletterConfig {
+	c := &common.NewsletterConfig{Name: newsletter}
+	if nc, ok := nr.Newsletters.Get(newsletter); ok && nc != nil {
+		*c = *nc
+	}
+
+	if c.SubscribeRedirectURL == "" {
+		c.SubscribeRedirectURL = nr.SubscribeRedirectURL
+	}
+
+	if c.UnsubscribeRedirectURL == "" {
+		c.UnsubscribeRedirectURL = nr.UnsubscribeRedirectURL
+	}
+
+	if c.ConfirmRedirectURL == "" {
+		c.ConfirmRedirectURL = nr.ConfirmRedirectURL
+	}
+
+	if c.ConfirmURL == "" {
+		c.ConfirmURL = nr.ConfirmURL
+	}
+
+	if c.PreferencesURL == "" {
+		c.PreferencesURL = nr.PreferencesURL
+	}
+
+	if c.ConsentVersion == "" {
+		c.ConsentVersion = nr.ConsentVersion
+	}
+
+	return c
+}
+
 func (nr *NewsletterResource) method(m string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != m {
@@ -82,50 +200,146 @@ func (nr *NewsletterResource) isValidNewsletter(n string) bool {
 		return false
 	}
 
-	_, ok := nr.Newsletters[n]
+	_, ok := nr.Newsletters.Get(n)
 
 	return ok
 }
 
-func (nr *NewsletterResource) validate(newsletter, email string) bool {
-	err := checkmail.ValidateFormat(email)
-	if err != nil {
-		log.Printf("Failed to validate email. value=%q err=%q", email, err)
+// isActiveNewsletter checks if the newsletter accepts new subscriptions
+func (nr *NewsletterResource) isActiveNewsletter(n string) bool {
+	if n == "" {
 		return false
 	}
 
-	if !nr.isValidNewsletter(newsletter) {
-		log.Printf("Invalid newsletter. value=%v", newsletter)
-		return false
+	nc, ok := nr.Newsletters.Get(n)
+
+	return ok && !nc.Archived
+}
+
+// allowSubscribe checks rate limits for the client address and
+// for the target email. Limiter failures do not block subscriptions.
+// Requests without known address are not limited by it, otherwise
+// all of them would share one limit.
+func (nr *NewsletterResource) allowSubscribe(r *http.Request, email string) bool {
+	if ip := clientIP(r); nr.IPLimiter != nil && ip != "" {
+		ok, err := nr.IPLimiter.Allow("subscribe-ip:" + ip)
+		if err != nil {
+			nr.Logger.Error("Failed to check rate limit", "ip", ip, "err", err)
+		} else if !ok {
+			nr.Logger.Warn("Rate limit exceeded", "ip", ip)
+			return false
+		}
+	}
+
+	if nr.EmailLimiter != nil {
+		ok, err := nr.EmailLimiter.Allow("subscribe-email:" + strings.ToLower(email))
+		if err != nil {
+			nr.Logger.Error("Failed to check rate limit", "email", email, "err", err)
+		} else if !ok {
+			nr.Logger.Warn("Rate limit exceeded", "email", email)
+			return false
+		}
 	}
 
 	return true
 }
 
+// isSuppressed checks if the address hard-bounced or complained before
+func (nr *NewsletterResource) isSuppressed(email string) (bool, error) {
+	if nr.Notifications == nil {
+		return false, nil
+	}
+
+	notifications, err := nr.Notifications.EmailNotifications(email)
+	if err != nil {
+		return false, err
+	}
+
+	return common.SuppressedEmails(notifications)[email], nil
+}
+
+// attributes returns non-empty values of allow-listed form fields
+func (nr *NewsletterResource) attributes(r *http.Request) map[string]string {
+	var attributes map[string]string
+
+	for _, key := range nr.Attributes {
+		value := strings.TrimSpace(r.FormValue(key))
+		if value == "" {
+			continue
+		}
+
+		if attributes == nil {
+			attributes = make(map[string]string)
+		}
+
+		attributes[key] = value
+	}
+
+	return attributes
+}
+
 func (nr *NewsletterResource) subscribe(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxSubscribeBodySize)
 	err := r.ParseForm()
 
 	if err != nil {
-		log.Printf("Failed to parse form. err=%v", err)
+		nr.Logger.Warn("Failed to parse form", "err", err)
 	}
 
 	newsletter := r.FormValue(common.ParamNewsletter)
-	email := r.FormValue(common.ParamEmail)
+	email, err := validEmail(nr.EmailNormalizer, r.FormValue(common.ParamEmail))
+	if err != nil {
+		nr.Logger.Warn("Failed to validate email", "email", r.FormValue(common.ParamEmail), "err", err)
+		fail(w, r, http.StatusBadRequest, OutcomeInvalidEmail, http.StatusText(http.StatusBadRequest))
+
+		return
+	}
+
+	if !nr.isActiveNewsletter(newsletter) {
+		nr.Logger.Warn("Invalid newsletter", "newsletter", newsletter)
+		fail(w, r, http.StatusBadRequest, OutcomeUnknownNewsletter, http.StatusText(http.StatusBadRequest))
+
+		return
+	}
+
+	if !nr.allowSubscribe(r, email) {
+		fail(w, r, http.StatusTooManyRequests, OutcomeRateLimited, http.StatusText(http.StatusTooManyRequests))
+		return
+	}
+
+	for _, v := range nr.Verifiers {
+		if err := v.Verify(r); err != nil {
+			nr.Logger.Warn("Failed to verify submission", "email", email, "newsletter", newsletter, "err", err)
+			fail(w, r, http.StatusBadRequest, OutcomeVerificationFailed, http.StatusText(http.StatusBadRequest))
+
+			return
+		}
+	}
+
+	nc := nr.config(newsletter)
+
+	suppressed, err := nr.isSuppressed(email)
+	if err != nil {
+		nr.Logger.Error("Failed to check suppression", "email", email, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if suppressed {
+		// respond as usual to not disclose bounces and complaints of the address
+		nr.Logger.Warn("Refused subscription of suppressed email", "email", email, "newsletter", newsletter)
+		nr.subscribed(w, r, nc)
 
-	if ok := nr.validate(newsletter, email); !ok {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
 	if s, err := nr.Subscribers.GetSubscriber(newsletter, email); err == nil {
-		log.Printf("Subscriber already exists. email=%v newsletter=%v", email, newsletter)
+		nr.Logger.Warn("Subscriber already exists", "email", email, "newsletter", newsletter)
 
 		if s.Confirmed() && !s.Unsubscribed() {
-			log.Printf("Email is already confirmed. email=%v newsletter=%v confirmed_at=%v",
-				email, newsletter, s.ConfirmedAt.Time())
-			w.Header().Set("Location", nr.ConfirmRedirectURL)
-			http.Redirect(w, r, nr.ConfirmRedirectURL, http.StatusFound)
+			nr.Logger.Warn("Email is already confirmed", "email", email, "newsletter", newsletter, "confirmed_at", s.ConfirmedAt.Time())
+			succeed(w, r, OutcomeAlreadyConfirmed, nc.ConfirmRedirectURL)
 
 			return
 		}
@@ -134,20 +348,91 @@ func (nr *NewsletterResource) subscribe(w http.ResponseWriter, r *http.Request)
 	// name is optional
 	name := strings.TrimSpace(r.FormValue(common.ParamName))
 
-	err = nr.Subscribers.AddSubscriber(newsletter, email, name)
+	locale := requestLocale(r)
+	attributes := nr.attributes(r)
+
+	err = nr.Subscribers.AddSubscriber(newsletter, email, name, locale, attributes)
 	if err != nil {
-		log.Printf("Failed to add subscription. email=%q newsletter=%q name=%v err=%v", email, newsletter, name, err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		nr.Logger.Error("Failed to add subscription", "email", email, "newsletter", newsletter, "name", name, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, http.StatusText(http.StatusInternalServerError))
 
 		return
 	}
 
-	log.Printf("Added subscription email=%q newsletter=%q name=%v", email, newsletter, name)
+	nr.Logger.Info("Added subscription", "email", email, "newsletter", newsletter, "name", name, "locale", locale)
+	nr.addEvent(r, common.EventSubscribe, newsletter, email, common.SourceForm)
 
-	_ = nr.Mailer.SendConfirmation(newsletter, email, name, nr.ConfirmURL)
+	if nc.DoubleOptIn() {
+		if err := nr.Mailer.SendConfirmation(nc, email, name, locale); err == nil {
+			nr.addEvent(r, common.EventConfirmationSent, newsletter, email, common.SourceForm)
+		}
+	} else if err := nr.Subscribers.ConfirmSubscriber(newsletter, email); err != nil {
+		nr.Logger.Error("Failed to confirm subscription", "email", email, "newsletter", newsletter, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, http.StatusText(http.StatusInternalServerError))
+
+		return
+	} else {
+		nr.addEvent(r, common.EventConfirm, newsletter, email, common.SourceForm)
+	}
 
-	w.Header().Set("Location", nr.SubscribeRedirectURL)
-	http.Redirect(w, r, nr.SubscribeRedirectURL, http.StatusFound)
+	nr.subscribed(w, r, nc)
+}
+
+// subscribed writes successful outcome of subscribe request that
+// depends on the opt-in policy of the newsletter
+func (nr *NewsletterResource) subscribed(w http.ResponseWriter, r *http.Request, nc *common.NewsletterConfig) {
+	if nc.DoubleOptIn() {
+		succeed(w, r, OutcomePendingConfirmation, nc.SubscribeRedirectURL)
+	} else {
+		succeed(w, r, OutcomeConfirmed, nc.ConfirmRedirectURL)
+	}
+}
+
+func (nr *NewsletterResource) serveUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		{
+			if nr.Interstitial {
+				nr.unsubscribePage(w, r)
+			} else {
+				nr.unsubscribe(w, r)
+			}
+		}
+	case "POST":
+		{
+			nr.unsubscribePost(w, r)
+		}
+	default:
+		{
+			nr.Logger.Warn("Unsupported method for unsubscribe", "method", r.Method)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+func (nr *NewsletterResource) serveConfirm(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET" && nr.Interstitial:
+		{
+			nr.confirmPage(w, r)
+		}
+	case r.Method == "GET":
+		{
+			nr.confirm(w, r)
+		}
+	case r.Method == "POST" && nr.Interstitial:
+		{
+			r.Body = http.MaxBytesReader(w, r.Body, maxSubscribeBodySize)
+			nr.confirm(w, r)
+		}
+	default:
+		{
+			nr.Logger.Warn("Unsupported method for confirm", "method", r.Method)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
 }
 
 // unsubscribe route.
@@ -155,86 +440,249 @@ func (nr *NewsletterResource) unsubscribe(w http.ResponseWriter, r *http.Request
 	newsletter := r.URL.Query().Get(common.ParamNewsletter)
 	unsubscribeToken := r.URL.Query().Get(common.ParamToken)
 
-	if newsletter == "" {
-		http.Error(w, "The newsletter query-string parameter is required", http.StatusBadRequest)
+	if ok := nr.removeSubscriber(w, r, newsletter, unsubscribeToken, common.SourceEmail); !ok {
 		return
 	}
 
-	if !nr.isValidNewsletter(newsletter) {
-		http.Error(w, "Invalid newsletter param", http.StatusBadRequest)
+	succeed(w, r, OutcomeUnsubscribed, nr.config(newsletter).UnsubscribeRedirectURL)
+}
+
+// unsubscribePage renders interstitial page instead of unsubscribing
+func (nr *NewsletterResource) unsubscribePage(w http.ResponseWriter, r *http.Request) {
+	newsletter := r.URL.Query().Get(common.ParamNewsletter)
+	unsubscribeToken := r.URL.Query().Get(common.ParamToken)
+
+	if _, ok := nr.checkToken(w, r, common.PurposeUnsubscribe, newsletter, unsubscribeToken); !ok {
 		return
 	}
 
-	email, ok := common.Unsign(nr.Secret, unsubscribeToken)
-	if !ok {
-		log.Printf("Failed to unsign token. value=%q", unsubscribeToken)
-		http.Error(w, "Invalid unsubscribe token", http.StatusBadRequest)
+	renderLanding(w, newLandingPage(r, unsubscribeTexts, nr.config(newsletter).Title(), newsletter, unsubscribeToken))
+}
+
+// parseForm parses both urlencoded and multipart bodies, RFC 8058 allows
+// mail clients to send the one-click unsubscribe body in either encoding
+func parseForm(r *http.Request) error {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		return r.ParseMultipartForm(maxSubscribeBodySize)
+	}
+
+	return r.ParseForm()
+}
+
+// unsubscribePost route implements RFC 8058 one-click unsubscribe used
+// by mail clients with List-Unsubscribe-Post header and handles
+// submissions of the interstitial page
+func (nr *NewsletterResource) unsubscribePost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSubscribeBodySize)
+	err := parseForm(r)
+
+	if err != nil {
+		nr.Logger.Warn("Failed to parse form", "err", err)
+	}
+
+	oneClick := r.PostFormValue(common.ParamListUnsubscribe) == common.ListUnsubscribeOneClick
+	if !oneClick && !nr.Interstitial {
+		nr.Logger.Info("Missing one-click unsubscribe body", "value", r.PostFormValue(common.ParamListUnsubscribe))
+		fail(w, r, http.StatusBadRequest, OutcomeBadRequest, http.StatusText(http.StatusBadRequest))
 
 		return
 	}
 
+	// mail clients keep newsletter and token in the query of List-Unsubscribe url
+	newsletter := r.FormValue(common.ParamNewsletter)
+	unsubscribeToken := r.FormValue(common.ParamToken)
+
+	source := common.SourceEmail
+	if oneClick {
+		source = common.SourceOneClick
+	}
+
+	if ok := nr.removeSubscriber(w, r, newsletter, unsubscribeToken, source); !ok {
+		return
+	}
+
+	if !oneClick {
+		succeed(w, r, OutcomeUnsubscribed, nr.config(newsletter).UnsubscribeRedirectURL)
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, &Response{
+			Status:  http.StatusOK,
+			Outcome: OutcomeUnsubscribed,
+		})
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// checkToken validates newsletter and token parameters and returns
+// the email from the token as it was signed. Error response is written
+// if it fails.
+func (nr *NewsletterResource) checkToken(w http.ResponseWriter, r *http.Request, purpose, newsletter, token string) (string, bool) {
+	if newsletter == "" {
+		fail(w, r, http.StatusBadRequest, OutcomeBadRequest, "The newsletter query-string parameter is required")
+		return "", false
+	}
+
+	if !nr.isValidNewsletter(newsletter) {
+		fail(w, r, http.StatusBadRequest, OutcomeUnknownNewsletter, "Invalid newsletter param")
+		return "", false
+	}
+
+	email, err := nr.TokenPolicy.Unsign(nr.Secret, token, purpose, newsletter)
+	if err != nil {
+		nr.Logger.Warn("Failed to unsign token", "token", token, "purpose", purpose, "err", err)
+		fail(w, r, http.StatusBadRequest, OutcomeInvalidToken, "Invalid "+purpose+" token")
+
+		return "", false
+	}
+
+	return email, true
+}
+
+// removeSubscriber validates unsubscribe request and marks subscriber
+// as unsubscribed. Error response is written if it fails.
+func (nr *NewsletterResource) removeSubscriber(w http.ResponseWriter, r *http.Request, newsletter, unsubscribeToken, source string) bool {
+	signed, ok := nr.checkToken(w, r, common.PurposeUnsubscribe, newsletter, unsubscribeToken)
+	if !ok {
+		return false
+	}
+
+	email := nr.tokenEmail(signed)
+
 	err := nr.Subscribers.RemoveSubscriber(newsletter, email)
+	if err == common.ErrSubscriberNotFound && signed != email {
+		// subscribers stored before the normalization keep the signed email
+		err = nr.Subscribers.RemoveSubscriber(newsletter, signed)
+	}
+
+	// links stay valid after the subscriber is purged or erased and
+	// one-click clients retry on errors, so there is nothing to change
+	if err == common.ErrSubscriberNotFound {
+		nr.Logger.Info("Unsubscribed email does not exist", "email", email, "newsletter", newsletter)
+		return true
+	}
+
 	if err != nil {
-		log.Printf("Failed to unsubscribe. email=%q err=%v", email, err)
-		http.Error(w, "Error unsubscribing from newsletter", http.StatusInternalServerError)
+		nr.Logger.Error("Failed to unsubscribe", "email", email, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, "Error unsubscribing from newsletter")
 
-		return
+		return false
 	}
 
-	log.Printf("Unsubscribed. email=%q newsletter=%q", email, newsletter)
-	w.Header().Set("Location", nr.UnsubscribeRedirectURL)
-	http.Redirect(w, r, nr.UnsubscribeRedirectURL, http.StatusFound)
+	nr.Logger.Info("Unsubscribed", "email", email, "newsletter", newsletter)
+	nr.addEvent(r, common.EventUnsubscribe, newsletter, email, source)
+
+	return true
 }
 
-func (nr *NewsletterResource) confirm(w http.ResponseWriter, r *http.Request) {
+// confirmPage renders interstitial page instead of confirming
+func (nr *NewsletterResource) confirmPage(w http.ResponseWriter, r *http.Request) {
 	newsletter := r.URL.Query().Get(common.ParamNewsletter)
 	subscribeToken := r.URL.Query().Get(common.ParamToken)
 
-	if !nr.isValidNewsletter(newsletter) {
-		http.Error(w, "Invalid newsletter param", http.StatusBadRequest)
+	if _, ok := nr.checkToken(w, r, common.PurposeConfirm, newsletter, subscribeToken); !ok {
 		return
 	}
 
-	email, ok := common.Unsign(nr.Secret, subscribeToken)
-	if !ok {
-		log.Printf("Failed to unsign token. value=%q", subscribeToken)
-		http.Error(w, "Invalid subscribe token", http.StatusBadRequest)
+	renderLanding(w, newLandingPage(r, confirmTexts, nr.config(newsletter).Title(), newsletter, subscribeToken))
+}
+
+func (nr *NewsletterResource) confirm(w http.ResponseWriter, r *http.Request) {
+	// values come from query for GET and from the form for POST
+	newsletter := r.FormValue(common.ParamNewsletter)
+	subscribeToken := r.FormValue(common.ParamToken)
 
+	signed, ok := nr.checkToken(w, r, common.PurposeConfirm, newsletter, subscribeToken)
+	if !ok {
 		return
 	}
 
+	email := nr.tokenEmail(signed)
+	nc := nr.config(newsletter)
+
 	if s, err := nr.Subscribers.GetSubscriber(newsletter, email); err == nil {
 		if s.Unsubscribed() {
-			log.Printf("Subscriber has already unsubscribed. newsletter=%v email=%v", newsletter, email)
-			w.Header().Set("Location", nr.UnsubscribeRedirectURL)
-			http.Redirect(w, r, nr.UnsubscribeRedirectURL, http.StatusFound)
+			nr.Logger.Info("Subscriber has already unsubscribed", "newsletter", newsletter, "email", email)
+			succeed(w, r, OutcomeAlreadyUnsubscribed, nc.UnsubscribeRedirectURL)
 
 			return
 		}
 	} else {
-		log.Printf("Subscriber cannot be found. newsletter=%v email=%v err=%v", newsletter, email, err)
-		http.Error(w, "Error confirming subscription", http.StatusInternalServerError)
+		nr.Logger.Info("Subscriber cannot be found", "newsletter", newsletter, "email", email, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, "Error confirming subscription")
 
 		return
 	}
 
 	err := nr.Subscribers.ConfirmSubscriber(newsletter, email)
 	if err != nil {
-		log.Printf("Failed to confirm subscription. email=%q err=%v", email, err)
-		http.Error(w, "Error confirming subscription", http.StatusInternalServerError)
+		nr.Logger.Error("Failed to confirm subscription", "email", email, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, "Error confirming subscription")
 
 		return
 	}
 
-	log.Printf("Confirmed subscription. email=%q newsletter=%q", email, newsletter)
-	w.Header().Set("Location", nr.ConfirmRedirectURL)
-	http.Redirect(w, r, nr.ConfirmRedirectURL, http.StatusFound)
+	nr.Logger.Info("Confirmed subscription", "email", email, "newsletter", newsletter)
+	nr.addEvent(r, common.EventConfirm, newsletter, email, common.SourceEmail)
+	succeed(w, r, OutcomeConfirmed, nc.ConfirmRedirectURL)
+}
+
+func (ar *AdminResource) serveComplaints(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		{
+			ar.complaints(w, r)
+		}
+	case "DELETE":
+		{
+			ar.liftSuppression(w, r)
+		}
+	default:
+		{
+			ar.Logger.Warn("Unsupported method for complaints", "method", r.Method)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+// liftSuppression allows subscriptions and emails to the address
+// that hard-bounced or complained earlier
+func (ar *AdminResource) liftSuppression(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get(common.ParamEmail)
+
+	if err := checkmail.ValidateFormat(email); err != nil {
+		http.Error(w, "The email parameter is invalid", http.StatusBadRequest)
+		return
+	}
+
+	// notifications are stored with normalized addresses
+	email, err := ar.EmailNormalizer.Normalize(email)
+	if err != nil {
+		http.Error(w, "The email parameter is invalid", http.StatusBadRequest)
+		return
+	}
+
+	err = ar.Notifications.LiftSuppression(email)
+	if err != nil {
+		ar.Logger.Error("Failed to lift suppression", "email", email, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	ar.Logger.Info("Lifted suppression", "email", email)
+	w.WriteHeader(http.StatusOK)
 }
 
 func (ar *AdminResource) complaints(w http.ResponseWriter, r *http.Request) {
 	notifications, err := ar.Notifications.Notifications()
 	if err != nil {
-		log.Printf("Failed to fetch notifications. err=%v", err)
+		ar.Logger.Error("Failed to fetch notifications", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 
 		return
@@ -249,24 +697,7 @@ func (ar *AdminResource) complaints(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// auth middleware.
-func (ar *AdminResource) auth(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		_, pass, ok := r.BasicAuth()
-		if !ok {
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-			return
-		}
-
-		if pass != ar.APIToken {
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	}
-}
-
+// serveSubscribers lists, imports and deletes subscribers of the newsletter
 func (ar *AdminResource) serveSubscribers(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
@@ -283,7 +714,7 @@ func (ar *AdminResource) serveSubscribers(w http.ResponseWriter, r *http.Request
 		}
 	default:
 		{
-			log.Printf("Unsupported method for subscribers. method=%v", r.Method)
+			ar.Logger.Warn("Unsupported method for subscribers", "method", r.Method)
 			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			return
 		}
@@ -291,25 +722,38 @@ func (ar *AdminResource) serveSubscribers(w http.ResponseWriter, r *http.Request
 }
 
 func (ar *AdminResource) getSubscribers(w http.ResponseWriter, r *http.Request) {
-	newsletter := r.URL.Query().Get(common.ParamNewsletter)
+	query, err := subscribersQuery(r.URL.Query())
+	if err != nil {
+		ar.Logger.Warn("Invalid subscribers query", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if !ar.isValidNewsletter(newsletter) {
+	if !ar.isValidNewsletter(query.Newsletter) {
 		http.Error(w, "The newsletter parameter is invalid", http.StatusBadRequest)
 		return
 	}
 
-	emails, err := ar.Subscribers.Subscribers(newsletter)
+	if !ar.allowsNewsletter(query.Newsletter) {
+		http.Error(w, errNewsletterForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	page, err := ar.Subscribers.QuerySubscribers(query)
 	if err != nil {
-		log.Printf("Failed to fetch subscribers. err=%v", err)
+		ar.Logger.Error("Failed to fetch subscribers", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 
 		return
 	}
 
+	if page.Cursor != "" {
+		w.Header().Set(common.HeaderNextCursor, page.Cursor)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	err = json.NewEncoder(w).Encode(emails)
+	err = json.NewEncoder(w).Encode(page.Subscribers)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -333,16 +777,40 @@ func (ar *AdminResource) putSubscribers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	for _, s := range subscribers {
+		if !ar.allowsNewsletter(s.Newsletter) {
+			http.Error(w, errNewsletterForbidden.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	erased, err := ar.erasedHashes()
+	if err != nil {
+		ar.Logger.Error("Failed to fetch erasures", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
 	ss := make([]*common.Subscriber, 0, len(subscribers))
+	// index of the subscriber in ss to merge duplicates of the same address
+	index := make(map[common.SubscriberKey]int)
 
 	for _, s := range subscribers {
 		if !ar.isValidNewsletter(s.Newsletter) {
-			log.Printf("Skipping unsupported newsletter. value=%v", s.Newsletter)
+			ar.Logger.Warn("Skipping unsupported newsletter", "newsletter", s.Newsletter)
 			continue
 		}
 
-		if err = checkmail.ValidateFormat(s.Email); err != nil {
-			log.Printf("Skipping invalid email. value=%v", s.Email)
+		email, err := validEmail(ar.EmailNormalizer, s.Email)
+		if err != nil {
+			ar.Logger.Warn("Skipping invalid email", "email", s.Email)
+			continue
+		}
+		s.Email = email
+
+		if erased[common.EmailHash(ar.ErasureSalt, s.Email)] {
+			ar.Logger.Warn("Skipping erased email", "newsletter", s.Newsletter)
 			continue
 		}
 
@@ -350,6 +818,15 @@ func (ar *AdminResource) putSubscribers(w http.ResponseWriter, r *http.Request)
 			s.CreatedAt = common.JsonTimeNow()
 		}
 
+		key := common.SubscriberKey{Newsletter: s.Newsletter, Email: s.Email}
+		if i, ok := index[key]; ok {
+			ar.Logger.Warn("Merging duplicate subscriber", "email", s.Email, "newsletter", s.Newsletter)
+			ss[i] = common.MergeSubscribers([]*common.Subscriber{ss[i], s})
+
+			continue
+		}
+
+		index[key] = len(ss)
 		ss = append(ss, s)
 	}
 
@@ -360,12 +837,20 @@ func (ar *AdminResource) putSubscribers(w http.ResponseWriter, r *http.Request)
 
 	err = ar.Subscribers.AddSubscribers(ss)
 	if err != nil {
-		log.Printf("Failed to import subscribers. err=%v", err)
+		ar.Logger.Error("Failed to import subscribers", "err", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 
 		return
 	}
 
+	events := make([]*common.SubscriberEvent, 0, len(ss))
+	for _, s := range ss {
+		events = append(events, newEvent(r, common.EventImport, s.Newsletter, s.Email, common.SourceAdmin))
+	}
+	addEvents(ar.Logger, ar.Events, ar.Publisher, events)
+
+	ar.Metrics.Observe(MetricImportSize, sizeBuckets, float64(len(ss)))
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -383,35 +868,84 @@ func (ar *AdminResource) deleteSubscribers(w http.ResponseWriter, r *http.Reques
 
 	err := dec.Decode(&keys)
 	if err != nil {
-		log.Printf("Failed to decode keys. err=%v", err)
+		ar.Logger.Warn("Failed to decode keys", "err", err)
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 
 		return
 	}
 
+	for _, k := range keys {
+		if !ar.allowsNewsletter(k.Newsletter) {
+			http.Error(w, errNewsletterForbidden.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	// exact keys are used to remove duplicates with legacy emails
+	if r.URL.Query().Get(common.ParamExact) != "true" {
+		keys = ar.normalizeKeys(keys)
+	}
+
 	err = ar.Subscribers.DeleteSubscribers(keys)
 	if err != nil {
-		log.Printf("Failed to delete subscribers. err=%v", err)
+		ar.Logger.Error("Failed to delete subscribers", "err", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 
 		return
 	}
 
+	events := make([]*common.SubscriberEvent, 0, len(keys))
+	for _, k := range keys {
+		events = append(events, newEvent(r, common.EventDelete, k.Newsletter, k.Email, common.SourceAdmin))
+	}
+	addEvents(ar.Logger, ar.Events, ar.Publisher, events)
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// normalizeKeys replaces emails of the keys with canonical ones and
+// removes resulting duplicates
+func (ar *AdminResource) normalizeKeys(keys []*common.SubscriberKey) []*common.SubscriberKey {
+	normalized := make([]*common.SubscriberKey, 0, len(keys))
+	seen := make(map[common.SubscriberKey]bool)
+
+	for _, k := range keys {
+		key := common.SubscriberKey{Newsletter: k.Newsletter, Email: k.Email}
+		if email, err := ar.EmailNormalizer.Normalize(k.Email); err == nil {
+			key.Email = email
+		}
+
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		normalized = append(normalized, &key)
+	}
+
+	return normalized
+}
+
 func (ar *AdminResource) isValidNewsletter(n string) bool {
 	if n == "" {
 		return false
 	}
 
-	_, ok := ar.Newsletters[n]
+	_, ok := ar.Newsletters.Get(n)
 
 	return ok
 }
 
+// AddNewsletters adds newsletters missing in the store
 func (ar *AdminResource) AddNewsletters(n []string) {
-	for _, i := range n {
-		ar.Newsletters[i] = true
+	if err := ar.Newsletters.AddNames(n); err != nil {
+		ar.Logger.Error("Failed to add newsletters", "err", err)
+	}
+}
+
+// AddNewsletterConfigs adds newsletters missing in the store
+func (ar *AdminResource) AddNewsletterConfigs(configs []*common.NewsletterConfig) {
+	if err := ar.Newsletters.Add(configs); err != nil {
+		ar.Logger.Error("Failed to add newsletter configs", "err", err)
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -32,7 +33,7 @@ var errFromFailingStore = errors.New("Error!")
 
 type DevNullMailer struct{}
 
-func (m *DevNullMailer) SendConfirmation(newsletter, email, name, confirmUrl string) error {
+func (m *DevNullMailer) SendConfirmation(newsletter *common.NewsletterConfig, email, name, locale string) error {
 	return nil
 }
 
@@ -49,7 +50,7 @@ func (s *FailingSubscriberStore) GetSubscriber(newsletter, email string) (*commo
 	return &common.Subscriber{}, nil
 }
 
-func (s *FailingSubscriberStore) AddSubscriber(newsletter, email, name string) error {
+func (s *FailingSubscriberStore) AddSubscriber(newsletter, email, name, locale string, attributes map[string]string) error {
 	return errFromFailingStore
 }
 
@@ -61,6 +62,14 @@ func (s *FailingSubscriberStore) Subscribers(newsletter string) (subscribers []*
 	return nil, errFromFailingStore
 }
 
+func (s *FailingSubscriberStore) QuerySubscribers(query *common.SubscribersQuery) (*common.SubscribersPage, error) {
+	return nil, errFromFailingStore
+}
+
+func (s *FailingSubscriberStore) UpdateSubscriber(newsletter, email string, update *common.SubscriberUpdate) (*common.Subscriber, error) {
+	return nil, errFromFailingStore
+}
+
 func (s *FailingSubscriberStore) AddSubscribers(subscribers []*common.Subscriber) error {
 	return errFromFailingStore
 }
@@ -73,6 +82,10 @@ func (s *FailingSubscriberStore) ConfirmSubscriber(newsletter, email string) err
 	return errFromFailingStore
 }
 
+func (s *FailingSubscriberStore) MarkReminded(newsletter, email string, remindedAt common.JSONTime) error {
+	return errFromFailingStore
+}
+
 func NewFailingStore() *FailingSubscriberStore {
 	return &FailingSubscriberStore{
 		failGetSubscriber: true,
@@ -93,12 +106,32 @@ func (s *FailingNotificationsStore) Notifications() (notifications []*common.Ses
 	return nil, errFromFailingStore
 }
 
+func (s *FailingNotificationsStore) EmailNotifications(email string) (notifications []*common.SesNotification, err error) {
+	return nil, errFromFailingStore
+}
+
+func (s *FailingNotificationsStore) LiftSuppression(email string) error {
+	return errFromFailingStore
+}
+
+func (s *FailingNotificationsStore) DeleteNotifications(email string) error {
+	return errFromFailingStore
+}
+
+func confirmToken(email string) string {
+	return common.SignToken(secret, common.NewToken(common.PurposeConfirm, testNewsletter, email))
+}
+
+func unsubscribeToken(email string) string {
+	return common.SignToken(secret, common.NewToken(common.PurposeUnsubscribe, testNewsletter, email))
+}
+
 func NewTestNewsResource(subscribers common.SubscribersStore, notifications common.NotificationsStore) *NewsletterResource {
 	newsletters := &NewsletterResource{
 		Subscribers:   subscribers,
 		Notifications: notifications,
 		Secret:        secret,
-		Newsletters:   make(map[string]bool),
+		Newsletters:   NewNewsletterRegistry(db.NewNewslettersMapStore()),
 		Mailer:        &DevNullMailer{},
 	}
 	return newsletters
@@ -109,11 +142,33 @@ func NewTestAdminResource(subscribers common.SubscribersStore, notifications com
 		Subscribers:   subscribers,
 		Notifications: notifications,
 		APIToken:      apiToken,
-		Newsletters:   make(map[string]bool),
+		Newsletters:   NewNewsletterRegistry(db.NewNewslettersMapStore()),
 	}
 	return admins
 }
 
+// adminRequest returns request to the admin API authorized with the API
+// token, params are added to the query string
+func adminRequest(method, path, body string, params map[string]string) (*http.Request, error) {
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params) > 0 {
+		q := req.URL.Query()
+		for k, v := range params {
+			q.Add(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("any", apiToken)
+
+	return req, nil
+}
+
 func TestGetSubscribeMethodIsNotSupported(t *testing.T) {
 	srv := http.NewServeMux()
 	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
@@ -289,7 +344,7 @@ func TestConfirmSubscribeFailingStore(t *testing.T) {
 
 	data := url.Values{}
 	data.Set(common.ParamNewsletter, testNewsletter)
-	data.Set(common.ParamToken, common.Sign(secret, testEmail))
+	data.Set(common.ParamToken, confirmToken(testEmail))
 
 	req, err := http.NewRequest("GET", common.ConfirmEndpoint, nil)
 	if err != nil {
@@ -298,7 +353,7 @@ func TestConfirmSubscribeFailingStore(t *testing.T) {
 
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, confirmToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -323,7 +378,7 @@ func TestConfirmSubscribeFailingStore2(t *testing.T) {
 
 	data := url.Values{}
 	data.Set(common.ParamNewsletter, testNewsletter)
-	data.Set(common.ParamToken, common.Sign(secret, testEmail))
+	data.Set(common.ParamToken, confirmToken(testEmail))
 
 	req, err := http.NewRequest("GET", common.ConfirmEndpoint, nil)
 	if err != nil {
@@ -332,7 +387,7 @@ func TestConfirmSubscribeFailingStore2(t *testing.T) {
 
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, confirmToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -350,7 +405,7 @@ func TestConfirmSubscribeWithoutToken(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -383,7 +438,7 @@ func TestConfirmSubscribeIncorrectNewsletter(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -396,7 +451,7 @@ func TestConfirmSubscribeIncorrectNewsletter(t *testing.T) {
 
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, "foo")
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, confirmToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -414,7 +469,7 @@ func TestConfirmSubscribe(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -428,7 +483,7 @@ func TestConfirmSubscribe(t *testing.T) {
 
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, confirmToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -575,7 +630,7 @@ func TestGetSubscribersOK(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
 	nr.Setup(srv)
@@ -664,7 +719,7 @@ func TestUnsubscribeWithoutToken(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -697,7 +752,7 @@ func TestUnsubscribeWithBadToken(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.Setup(srv)
@@ -739,7 +794,7 @@ func TestUnsubscribeFailingStore(t *testing.T) {
 	}
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, unsubscribeToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -756,7 +811,7 @@ func TestUnsubscribe(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
@@ -769,7 +824,7 @@ func TestUnsubscribe(t *testing.T) {
 	}
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, unsubscribeToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -1203,7 +1258,7 @@ func TestDeleteSubscribers(t *testing.T) {
 	srv := http.NewServeMux()
 	store := db.NewSubscribersMapStore()
 	for i := 0; i < 10; i++ {
-		store.AddSubscriber(testNewsletter, fmt.Sprintf("email%v@email.com", i), testName)
+		store.AddSubscriber(testNewsletter, fmt.Sprintf("email%v@email.com", i), testName, "", nil)
 	}
 
 	nr := NewTestAdminResource(store, db.NewNotificationsMapStore())
@@ -1292,7 +1347,7 @@ func TestDeleteSubscribersFailingStore(t *testing.T) {
 func TestSubscribeAlreadyConfirmed(t *testing.T) {
 	srv := http.NewServeMux()
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
 	nr.Setup(srv)
@@ -1336,7 +1391,7 @@ func TestSubscribeAlreadyConfirmed(t *testing.T) {
 func TestSubscribeAlreadyUnsubscribed(t *testing.T) {
 	srv := http.NewServeMux()
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
 	nr.Setup(srv)
@@ -1380,7 +1435,7 @@ func TestSubscribeAlreadyUnsubscribed(t *testing.T) {
 func TestSubscribeAlreadyUnsubscribedAndConfirmed(t *testing.T) {
 	srv := http.NewServeMux()
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
 	nr.AddNewsletters([]string{testNewsletter})
 	nr.Setup(srv)
@@ -1426,8 +1481,10 @@ func TestUnsubscribeNotSubscribedYet(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
+	events := db.NewEventsMapStore()
 
 	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.Events = events
 	nr.AddNewsletters([]string{testNewsletter})
 	nr.Setup(srv)
 	nr.UnsubscribeRedirectURL = testUrl
@@ -1438,7 +1495,7 @@ func TestUnsubscribeNotSubscribedYet(t *testing.T) {
 	}
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, unsubscribeToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -1447,16 +1504,24 @@ func TestUnsubscribeNotSubscribedYet(t *testing.T) {
 
 	resp := w.Result()
 
-	if resp.StatusCode != http.StatusInternalServerError {
+	if resp.StatusCode != http.StatusFound {
 		t.Errorf("Unexpected status code %d", resp.StatusCode)
 	}
+
+	if store.Count() != 0 {
+		t.Errorf("Subscriber is added by unsubscribe")
+	}
+
+	if es, _ := events.Events(testEmail); len(es) != 0 {
+		t.Errorf("Unexpected events %v", es)
+	}
 }
 
 func TestUnsubscribeUnsubscribed(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 
 	s, _ := store.GetSubscriber(testNewsletter, testEmail)
 	s.UnsubscribedAt = common.JSONTime(s.CreatedAt.Time().Add(1 * time.Second))
@@ -1475,7 +1540,7 @@ func TestUnsubscribeUnsubscribed(t *testing.T) {
 	}
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, unsubscribeToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -1501,7 +1566,7 @@ func TestConfirmUnsubscribed(t *testing.T) {
 	srv := http.NewServeMux()
 
 	store := db.NewSubscribersMapStore()
-	store.AddSubscriber(testNewsletter, testEmail, testName)
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
 	s, _ := store.GetSubscriber(testNewsletter, testEmail)
 	s.UnsubscribedAt = common.JSONTime(s.CreatedAt.Time().Add(1 * time.Second))
 	if !s.Unsubscribed() {
@@ -1520,7 +1585,7 @@ func TestConfirmUnsubscribed(t *testing.T) {
 
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, confirmToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -1564,7 +1629,7 @@ func TestConfirmMissing(t *testing.T) {
 
 	q := req.URL.Query()
 	q.Add(common.ParamNewsletter, testNewsletter)
-	q.Add(common.ParamToken, common.Sign(secret, testEmail))
+	q.Add(common.ParamToken, confirmToken(testEmail))
 	req.URL.RawQuery = q.Encode()
 
 	w := httptest.NewRecorder()
@@ -1582,3 +1647,933 @@ func TestConfirmMissing(t *testing.T) {
 		t.Errorf("Wrong count in store: %v", store.Count())
 	}
 }
+
+func decodeResponse(t *testing.T, resp *http.Response) *Response {
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Unexpected content type %v", ct)
+	}
+
+	r := &Response{}
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestSubscribeJSON(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+	nr.SubscribeRedirectURL = testUrl
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, testEmail)
+
+	req, err := http.NewRequest("POST", common.SubscribeEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	r := decodeResponse(t, resp)
+	if r.Outcome != OutcomePendingConfirmation || r.Status != http.StatusOK {
+		t.Errorf("Unexpected response. status=%v outcome=%v", r.Status, r.Outcome)
+	}
+
+	if store.Count() != 1 {
+		t.Errorf("Wrong number of items in the store: %v", store.Count())
+	}
+}
+
+func TestSubscribeJSONErrors(t *testing.T) {
+	tests := []struct {
+		newsletter string
+		email      string
+		outcome    string
+	}{
+		{testNewsletter, "bar", OutcomeInvalidEmail},
+		{"foo", testEmail, OutcomeUnknownNewsletter},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+		nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+		nr.AddNewsletters([]string{testNewsletter})
+		nr.Setup(srv)
+
+		data := url.Values{}
+		data.Set(common.ParamNewsletter, tt.newsletter)
+		data.Set(common.ParamEmail, tt.email)
+		data.Set(common.ParamFormat, common.FormatJSON)
+
+		req, err := http.NewRequest("POST", common.SubscribeEndpoint, strings.NewReader(data.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Unexpected status code %d", resp.StatusCode)
+		}
+
+		r := decodeResponse(t, resp)
+		if r.Outcome != tt.outcome || r.Status != http.StatusBadRequest {
+			t.Errorf("Unexpected response. status=%v outcome=%v", r.Status, r.Outcome)
+		}
+	}
+}
+
+func TestSubscribeAlreadyConfirmedJSON(t *testing.T) {
+	srv := http.NewServeMux()
+
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+	store.ConfirmSubscriber(testNewsletter, testEmail)
+	s, _ := store.GetSubscriber(testNewsletter, testEmail)
+	s.ConfirmedAt = common.JSONTime(s.CreatedAt.Time().Add(1 * time.Second))
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, testEmail)
+
+	req, err := http.NewRequest("POST", common.SubscribeEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json, text/plain;q=0.9")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	r := decodeResponse(t, w.Result())
+	if r.Outcome != OutcomeAlreadyConfirmed {
+		t.Errorf("Unexpected outcome %v", r.Outcome)
+	}
+}
+
+func TestConfirmSubscribeJSON(t *testing.T) {
+	srv := http.NewServeMux()
+
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.ConfirmEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := req.URL.Query()
+	q.Add(common.ParamNewsletter, testNewsletter)
+	q.Add(common.ParamToken, confirmToken(testEmail))
+	q.Add(common.ParamFormat, common.FormatJSON)
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	time.Sleep(10 * time.Nanosecond)
+	srv.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	r := decodeResponse(t, resp)
+	if r.Outcome != OutcomeConfirmed {
+		t.Errorf("Unexpected outcome %v", r.Outcome)
+	}
+}
+
+func TestUnsubscribeJSON(t *testing.T) {
+	srv := http.NewServeMux()
+
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.UnsubscribeEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := req.URL.Query()
+	q.Add(common.ParamNewsletter, testNewsletter)
+	q.Add(common.ParamToken, "abcde")
+	req.URL.RawQuery = q.Encode()
+	req.Header.Add("Accept", "application/json")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	r := decodeResponse(t, resp)
+	if r.Outcome != OutcomeInvalidToken {
+		t.Errorf("Unexpected outcome %v", r.Outcome)
+	}
+}
+
+func TestConfirmWithUnsubscribeToken(t *testing.T) {
+	srv := http.NewServeMux()
+
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.ConfirmEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := req.URL.Query()
+	q.Add(common.ParamNewsletter, testNewsletter)
+	q.Add(common.ParamToken, unsubscribeToken(testEmail))
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	time.Sleep(10 * time.Nanosecond)
+	srv.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	i, _ := store.GetSubscriber(testNewsletter, testEmail)
+	if i.Confirmed() {
+		t.Errorf("Subscriber was confirmed with unsubscribe token")
+	}
+}
+
+func TestUnsubscribeLegacyToken(t *testing.T) {
+	tests := []struct {
+		legacyUntil time.Time
+		status      int
+	}{
+		{time.Now().Add(1 * time.Hour), http.StatusFound},
+		{time.Now().Add(-1 * time.Hour), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+
+		store := db.NewSubscribersMapStore()
+		store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+		nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+		nr.AddNewsletters([]string{testNewsletter})
+		nr.TokenPolicy.LegacyUntil = tt.legacyUntil
+		nr.Setup(srv)
+
+		req, err := http.NewRequest("GET", common.UnsubscribeEndpoint, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q := req.URL.Query()
+		q.Add(common.ParamNewsletter, testNewsletter)
+		q.Add(common.ParamToken, common.Sign(secret, testEmail))
+		req.URL.RawQuery = q.Encode()
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("Unexpected status code %d", resp.StatusCode)
+		}
+	}
+}
+
+func oneClickUnsubscribeRequest(newsletter, token, body string) (*http.Request, error) {
+	req, err := http.NewRequest("POST", common.UnsubscribeEndpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	q := req.URL.Query()
+	q.Add(common.ParamNewsletter, newsletter)
+	q.Add(common.ParamToken, token)
+	req.URL.RawQuery = q.Encode()
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	return req, nil
+}
+
+func TestUnsubscribeOneClick(t *testing.T) {
+	srv := http.NewServeMux()
+
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+	nr.UnsubscribeRedirectURL = testUrl
+
+	req, err := oneClickUnsubscribeRequest(testNewsletter, unsubscribeToken(testEmail), "List-Unsubscribe=One-Click")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	time.Sleep(10 * time.Nanosecond)
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		t.Errorf("Unexpected status code: %d, body: %v", resp.StatusCode, string(body))
+	}
+
+	if _, err := resp.Location(); err == nil {
+		t.Errorf("One-click unsubscribe should not redirect")
+	}
+
+	i, _ := store.GetSubscriber(testNewsletter, testEmail)
+	if !i.Unsubscribed() {
+		t.Errorf("Unsubscribe time not updated. created=%v unsubscribe=%v", i.CreatedAt, i.UnsubscribedAt)
+	}
+}
+
+func multipartUnsubscribeRequest(newsletter, token, value string) (*http.Request, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField(common.ParamListUnsubscribe, value); err != nil {
+		return nil, err
+	}
+	mw.Close()
+
+	req, err := oneClickUnsubscribeRequest(newsletter, token, "")
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = ioutil.NopCloser(&body)
+	req.ContentLength = int64(body.Len())
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return req, nil
+}
+
+func TestUnsubscribeOneClickMultipart(t *testing.T) {
+	tests := []struct {
+		value        string
+		code         int
+		unsubscribed bool
+	}{
+		{common.ListUnsubscribeOneClick, http.StatusOK, true},
+		{"Other", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+
+		store := db.NewSubscribersMapStore()
+		store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+		nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+		nr.AddNewsletters([]string{testNewsletter})
+		nr.Setup(srv)
+
+		req, err := multipartUnsubscribeRequest(testNewsletter, unsubscribeToken(testEmail), tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		time.Sleep(10 * time.Nanosecond)
+		srv.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code. value=%v expected=%v actual=%v", tt.value, tt.code, w.Code)
+		}
+
+		i, _ := store.GetSubscriber(testNewsletter, testEmail)
+		if i.Unsubscribed() != tt.unsubscribed {
+			t.Errorf("Unexpected unsubscribe. value=%v unsubscribed=%v", tt.value, i.Unsubscribed())
+		}
+	}
+}
+
+func TestUnsubscribeOneClickErrors(t *testing.T) {
+	tests := []struct {
+		token string
+		body  string
+	}{
+		{unsubscribeToken(testEmail), ""},
+		{unsubscribeToken(testEmail), "List-Unsubscribe=Other"},
+		{confirmToken(testEmail), "List-Unsubscribe=One-Click"},
+		{"abcde", "List-Unsubscribe=One-Click"},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+
+		store := db.NewSubscribersMapStore()
+		store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+		nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+		nr.AddNewsletters([]string{testNewsletter})
+		nr.Setup(srv)
+
+		req, err := oneClickUnsubscribeRequest(testNewsletter, tt.token, tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		time.Sleep(10 * time.Nanosecond)
+		srv.ServeHTTP(w, req)
+		resp := w.Result()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Unexpected status code %d", resp.StatusCode)
+		}
+
+		i, _ := store.GetSubscriber(testNewsletter, testEmail)
+		if i.Unsubscribed() {
+			t.Errorf("Subscriber was unsubscribed. body=%v", tt.body)
+		}
+	}
+}
+
+func TestConfirmInterstitial(t *testing.T) {
+	srv := http.NewServeMux()
+
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Interstitial = true
+	nr.ConfirmRedirectURL = testUrl
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.ConfirmEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := confirmToken(testEmail)
+	q := req.URL.Query()
+	q.Add(common.ParamNewsletter, testNewsletter)
+	q.Add(common.ParamToken, token)
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	time.Sleep(10 * time.Nanosecond)
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `method="POST"`) {
+		t.Errorf("Interstitial page does not contain form. body=%v", string(body))
+	}
+
+	i, _ := store.GetSubscriber(testNewsletter, testEmail)
+	if i.Confirmed() {
+		t.Errorf("Subscriber was confirmed on GET request")
+	}
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamToken, token)
+
+	req, err = http.NewRequest("POST", common.ConfirmEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp = w.Result()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	i, _ = store.GetSubscriber(testNewsletter, testEmail)
+	if !i.Confirmed() {
+		t.Errorf("Confirm time not updated. created=%v confirm=%v", i.CreatedAt, i.ConfirmedAt)
+	}
+}
+
+func TestConfirmPostWithoutInterstitial(t *testing.T) {
+	srv := http.NewServeMux()
+
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamToken, confirmToken(testEmail))
+
+	req, err := http.NewRequest("POST", common.ConfirmEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+}
+
+func TestUnsubscribeInterstitial(t *testing.T) {
+	srv := http.NewServeMux()
+
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Interstitial = true
+	nr.UnsubscribeRedirectURL = testUrl
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.UnsubscribeEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := unsubscribeToken(testEmail)
+	q := req.URL.Query()
+	q.Add(common.ParamNewsletter, testNewsletter)
+	q.Add(common.ParamToken, token)
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	time.Sleep(10 * time.Nanosecond)
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	i, _ := store.GetSubscriber(testNewsletter, testEmail)
+	if i.Unsubscribed() {
+		t.Errorf("Subscriber was unsubscribed on GET request")
+	}
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamToken, token)
+
+	req, err = http.NewRequest("POST", common.UnsubscribeEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp = w.Result()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	l, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.String() != testUrl {
+		t.Errorf("Path does not match. expected=%v actual=%v", l.Path, testUrl)
+	}
+
+	i, _ = store.GetSubscriber(testNewsletter, testEmail)
+	if !i.Unsubscribed() {
+		t.Errorf("Unsubscribe time not updated. created=%v unsubscribe=%v", i.CreatedAt, i.UnsubscribedAt)
+	}
+}
+
+type CountingMailer struct {
+	count int
+}
+
+func (m *CountingMailer) SendConfirmation(newsletter *common.NewsletterConfig, email, name, locale string) error {
+	m.count++
+	return nil
+}
+
+func subscribeRequest(email, remoteAddr string) (*http.Request, error) {
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, email)
+
+	req, err := http.NewRequest("POST", common.SubscribeEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = remoteAddr
+
+	return req, nil
+}
+
+func TestSubscribeRateLimited(t *testing.T) {
+	tests := []struct {
+		ipLimit    int
+		emailLimit int
+		emails     []string
+		addrs      []string
+	}{
+		// same ip, different emails
+		{1, 0, []string{"a@foo.com", "b@foo.com"}, []string{"1.1.1.1:1", "1.1.1.1:2"}},
+		// different ips, same email
+		{0, 1, []string{"a@foo.com", "A@foo.com"}, []string{"1.1.1.1:1", "2.2.2.2:1"}},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+		store := db.NewSubscribersMapStore()
+		mailer := &CountingMailer{}
+		nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+		nr.Mailer = mailer
+		nr.AddNewsletters([]string{testNewsletter})
+		if tt.ipLimit > 0 {
+			nr.IPLimiter = NewMemoryRateLimiter(tt.ipLimit, 1*time.Hour)
+		}
+		if tt.emailLimit > 0 {
+			nr.EmailLimiter = NewMemoryRateLimiter(tt.emailLimit, 1*time.Hour)
+		}
+		nr.Setup(srv)
+
+		statuses := []int{http.StatusFound, http.StatusTooManyRequests}
+		for i := range tt.emails {
+			req, err := subscribeRequest(tt.emails[i], tt.addrs[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+			resp := w.Result()
+
+			if resp.StatusCode != statuses[i] {
+				t.Errorf("Unexpected status code %d", resp.StatusCode)
+			}
+		}
+
+		if mailer.count != 1 {
+			t.Errorf("Unexpected number of sent emails: %v", mailer.count)
+		}
+
+		if store.Count() != 1 {
+			t.Errorf("Wrong number of items in the store: %v", store.Count())
+		}
+	}
+}
+
+func TestSubscribeUnknownAddressNotLimited(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.IPLimiter = NewMemoryRateLimiter(1, 1*time.Hour)
+	nr.Setup(srv)
+
+	for _, email := range []string{"a@foo.com", "b@foo.com"} {
+		req, err := subscribeRequest(email, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusFound {
+			t.Errorf("Unexpected status code %d", w.Code)
+		}
+	}
+
+	if store.Count() != 2 {
+		t.Errorf("Requests without address share the limit. count=%v", store.Count())
+	}
+}
+
+func TestSubscribeSuppressed(t *testing.T) {
+	tests := []struct {
+		bounce   bool
+		lift     bool
+		expected int
+	}{
+		{true, false, 0},
+		{false, false, 0},
+		{true, true, 1},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+		store := db.NewSubscribersMapStore()
+		notifications := db.NewNotificationsMapStore()
+		if tt.bounce {
+			notifications.AddBounce(testEmail, "from@email.com", false /*is transient*/)
+		} else {
+			notifications.AddComplaint(testEmail, "from@email.com")
+		}
+		if tt.lift {
+			notifications.LiftSuppression(testEmail)
+		}
+
+		mailer := &CountingMailer{}
+		nr := NewTestNewsResource(store, notifications)
+		nr.Mailer = mailer
+		nr.AddNewsletters([]string{testNewsletter})
+		nr.Setup(srv)
+
+		req, err := subscribeRequest(testEmail, "1.1.1.1:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		resp := w.Result()
+
+		if resp.StatusCode != http.StatusFound {
+			t.Errorf("Unexpected status code %d", resp.StatusCode)
+		}
+
+		if mailer.count != tt.expected || store.Count() != tt.expected {
+			t.Errorf("Unexpected subscription. emails=%v count=%v expected=%v", mailer.count, store.Count(), tt.expected)
+		}
+	}
+}
+
+func TestSubscribeSoftBounced(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	notifications := db.NewNotificationsMapStore()
+	notifications.AddBounce(testEmail, "from@email.com", true /*is transient*/)
+	nr := NewTestNewsResource(store, notifications)
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	req, err := subscribeRequest(testEmail, "1.1.1.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if store.Count() != 1 {
+		t.Errorf("Wrong number of items in the store: %v", store.Count())
+	}
+}
+
+func TestLiftSuppression(t *testing.T) {
+	srv := http.NewServeMux()
+	notifications := db.NewNotificationsMapStore()
+	notifications.AddComplaint(testEmail, "from@email.com")
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), notifications)
+	ar.Setup(srv)
+
+	req, err := http.NewRequest("DELETE", common.ComplaintsEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := req.URL.Query()
+	q.Add(common.ParamEmail, testEmail)
+	req.URL.RawQuery = q.Encode()
+
+	req.SetBasicAuth("any username", apiToken)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	ns, _ := notifications.EmailNotifications(testEmail)
+	if common.SuppressedEmails(ns)[testEmail] {
+		t.Errorf("Suppression was not lifted")
+	}
+}
+
+func TestLiftSuppressionNormalizesEmail(t *testing.T) {
+	srv := http.NewServeMux()
+	notifications := db.NewNotificationsMapStore()
+	notifications.AddBounce(testEmail, "from@email.com", false /*is transient*/)
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), notifications)
+	ar.Setup(srv)
+
+	req, err := adminRequest("DELETE", common.ComplaintsEndpoint, "", map[string]string{common.ParamEmail: "foo@BAR.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+
+	ns, _ := notifications.EmailNotifications(testEmail)
+	if common.SuppressedEmails(ns)[testEmail] {
+		t.Errorf("Suppression was not lifted")
+	}
+}
+
+func TestLiftSuppressionInvalidEmail(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.Setup(srv)
+
+	req, err := http.NewRequest("DELETE", common.ComplaintsEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetBasicAuth("any username", apiToken)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+}
+
+func TestSubscribeAttributes(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.Attributes = []string{"country", "source"}
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, testEmail)
+	data.Set("country", "UA")
+	data.Set("plan", "premium")
+
+	req, err := http.NewRequest("POST", common.SubscribeEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	s, err := store.GetSubscriber(testNewsletter, testEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Attributes) != 1 || s.Attributes["country"] != "UA" {
+		t.Errorf("Unexpected attributes: %v", s.Attributes)
+	}
+}
+
+func TestSubscribeNewsletterConfig(t *testing.T) {
+	tests := []struct {
+		config   *common.NewsletterConfig
+		location string
+		emails   int
+	}{
+		{&common.NewsletterConfig{Name: testNewsletter}, testUrl + "/subscribed", 1},
+		{&common.NewsletterConfig{Name: testNewsletter, SubscribeRedirectURL: testUrl + "/weekly"}, testUrl + "/weekly", 1},
+		{&common.NewsletterConfig{Name: testNewsletter, OptIn: common.OptInSingle}, testUrl + "/confirmed", 0},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+		store := db.NewSubscribersMapStore()
+		mailer := &CountingMailer{}
+		nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+		nr.Mailer = mailer
+		nr.SubscribeRedirectURL = testUrl + "/subscribed"
+		nr.ConfirmRedirectURL = testUrl + "/confirmed"
+		nr.AddNewsletterConfigs([]*common.NewsletterConfig{tt.config})
+		nr.Setup(srv)
+
+		req, err := subscribeRequest(testEmail, "1.1.1.1:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		resp := w.Result()
+
+		if location := resp.Header.Get("Location"); location != tt.location {
+			t.Errorf("Unexpected redirect. actual=%v expected=%v", location, tt.location)
+		}
+
+		if mailer.count != tt.emails {
+			t.Errorf("Unexpected number of sent emails: %v", mailer.count)
+		}
+
+		s, err := store.GetSubscriber(testNewsletter, testEmail)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.Confirmed() == tt.config.DoubleOptIn() {
+			t.Errorf("Unexpected confirmation status. confirmed=%v", s.Confirmed())
+		}
+	}
+}
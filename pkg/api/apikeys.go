@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+type contextKey int
+
+const apiKeyContextKey contextKey = 0
+
+// legacyKeyName is the name of the key that API_TOKEN grants
+const legacyKeyName = "API_TOKEN"
+
+var (
+	errInvalidAPIKey       = errors.New("Invalid API key")
+	errNewsletterForbidden = errors.New("API key cannot access the newsletter")
+)
+
+// access lists minimal roles of API keys for the methods of the route.
+// Methods that are not listed require admin role.
+type access struct {
+	roles map[string]string
+	// global routes are not limited to a newsletter and cannot be used
+	// with keys scoped to some newsletters
+	global bool
+}
+
+var (
+	readAccess        = access{roles: map[string]string{"GET": common.RoleReadOnly}}
+	globalReadAccess  = access{roles: map[string]string{"GET": common.RoleReadOnly}, global: true}
+	adminAccess       = access{global: true}
+	subscribersAccess = access{roles: map[string]string{
+		"GET":    common.RoleReadOnly,
+		"PUT":    common.RoleImport,
+		"PATCH":  common.RoleImport,
+		"DELETE": common.RoleDelete,
+	}}
+	complaintsAccess = access{roles: map[string]string{
+		"GET":    common.RoleReadOnly,
+		"DELETE": common.RoleDelete,
+	}, global: true}
+)
+
+func (a access) role(method string) string {
+	if role, ok := a.roles[method]; ok {
+		return role
+	}
+
+	return common.RoleAdmin
+}
+
+// apiKey returns the key of the token. API_TOKEN is accepted as
+// a key with admin role for all newsletters.
+func (ar *AdminResource) apiKey(token string) (*common.APIKey, error) {
+	if ar.APIToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(ar.APIToken)) == 1 {
+		return &common.APIKey{Name: legacyKeyName, Role: common.RoleAdmin}, nil
+	}
+
+	if ar.APIKeys == nil {
+		return nil, errInvalidAPIKey
+	}
+
+	id, secret, ok := common.ParseAPIToken(token)
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+
+	key, err := ar.APIKeys.GetAPIKey(id)
+	if err == common.ErrAPIKeyNotFound {
+		return nil, errInvalidAPIKey
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.Verify(secret) || key.Expired(time.Now()) {
+		return nil, errInvalidAPIKey
+	}
+
+	return key, nil
+}
+
+// auth checks the key from Basic Auth password and its role for the
+// method of the request
+func (ar *AdminResource) auth(a access, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, pass, ok := r.BasicAuth()
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		key, err := ar.apiKey(pass)
+		if err == errInvalidAPIKey {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		if err != nil {
+			ar.Logger.Error("Failed to fetch API key", "err", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+
+		if !key.Allows(a.role(r.Method)) || (a.global && key.Scoped()) {
+			ar.Logger.Warn("API key is not allowed", "api_key", key.Name, "method", r.Method, "path", r.URL.Path)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)))
+	}
+}
+
+// requestAPIKey returns the key set by auth
+func requestAPIKey(r *http.Request) *common.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*common.APIKey)
+	return key
+}
+
+// allowsNewsletter checks if the key of the request can access the newsletter
+func (ar *AdminResource) allowsNewsletter(n string) bool {
+	return ar.key == nil || ar.key.AllowsNewsletter(n)
+}
+
+// serveAPIKeys lists and creates API keys
+func (ar *AdminResource) serveAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if ar.APIKeys == nil {
+		http.Error(w, "API keys are not configured", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		{
+			ar.listAPIKeys(w, r)
+		}
+	case "POST":
+		{
+			ar.createAPIKey(w, r)
+		}
+	default:
+		{
+			ar.Logger.Warn("Unsupported method for API keys", "method", r.Method)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+// serveAPIKey revokes the key from the path /apikeys/{id}
+func (ar *AdminResource) serveAPIKey(w http.ResponseWriter, r *http.Request) {
+	if ar.APIKeys == nil {
+		http.Error(w, "API keys are not configured", http.StatusNotFound)
+		return
+	}
+
+	id, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), common.APIKeysEndpoint+"/"))
+	if err != nil || id == "" || strings.Contains(id, "/") {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	if r.Method != "DELETE" {
+		ar.Logger.Warn("Unsupported method for API key", "method", r.Method)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	err = ar.APIKeys.DeleteAPIKey(id)
+	if err == common.ErrAPIKeyNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		ar.Logger.Error("Failed to revoke API key", "id", id, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	ar.Logger.Info("Revoked API key", "id", id)
+	w.WriteHeader(http.StatusOK)
+}
+
+// listAPIKeys returns keys without hashes
+func (ar *AdminResource) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := ar.APIKeys.APIKeys()
+	if err != nil {
+		ar.Logger.Error("Failed to fetch API keys", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	if keys == nil {
+		keys = make([]*common.APIKey, 0)
+	}
+
+	for _, k := range keys {
+		k.Hash = ""
+	}
+
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// createAPIKey responds with the token that is not kept anywhere
+func (ar *AdminResource) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type header is not application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPatchBodySize)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	kr := &common.APIKeyRequest{}
+	err := dec.Decode(kr)
+	if err != nil {
+		ar.Logger.Warn("Failed to decode API key", "err", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	if err := kr.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, n := range kr.Newsletters {
+		if !ar.isValidNewsletter(n) {
+			http.Error(w, "The newsletter is invalid", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if kr.ExpiresAt != nil && !kr.ExpiresAt.Time().After(time.Now()) {
+		http.Error(w, "Expiration time is in the past", http.StatusBadRequest)
+		return
+	}
+
+	key, token, err := common.GenerateAPIKey(kr)
+	if err == nil {
+		err = ar.APIKeys.AddAPIKey(key)
+	}
+
+	if err != nil {
+		ar.Logger.Error("Failed to create API key", "name", kr.Name, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	ar.Logger.Info("Created API key", "id", key.ID, "name", key.Name, "role", key.Role)
+
+	key.Hash = ""
+	writeJSON(w, http.StatusCreated, &common.NewAPIKey{Key: key, Token: token})
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func NewTestAPIKeysResource() (*http.ServeMux, *AdminResource) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.APIKeys = db.NewAPIKeysMapStore()
+	ar.AddNewsletters([]string{testNewsletter, otherNewsletter})
+	ar.Setup(srv)
+
+	return srv, ar
+}
+
+func serveKeyRequest(t *testing.T, srv *http.ServeMux, method, path, token, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("any", token)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	return w
+}
+
+func createTestAPIKey(t *testing.T, srv *http.ServeMux, body string) *common.NewAPIKey {
+	w := serveKeyRequest(t, srv, "POST", common.APIKeysEndpoint, apiToken, body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected status code. code=%v body=%v", w.Code, w.Body.String())
+	}
+
+	created := &common.NewAPIKey{}
+	if err := json.Unmarshal(w.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+
+	if created.Token == "" || created.Key.ID == "" || created.Key.Hash != "" {
+		t.Fatalf("Unexpected key %+v", created.Key)
+	}
+
+	return created
+}
+
+func TestCreateAPIKeyErrors(t *testing.T) {
+	srv, _ := NewTestAPIKeysResource()
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"name": "a", "role": "owner"}`, http.StatusBadRequest},
+		{`{"name": "", "role": "admin"}`, http.StatusBadRequest},
+		{`{"name": "a", "role": "admin", "newsletters": ["unknown"]}`, http.StatusBadRequest},
+		{`{"name": "a", "role": "admin", "expires_at": "2001-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{`{"name": "a", "role": "admin", "unknown": true}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := serveKeyRequest(t, srv, "POST", common.APIKeysEndpoint, apiToken, tt.body)
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code. body=%v expected=%v actual=%v", tt.body, tt.code, w.Code)
+		}
+	}
+}
+
+func TestReadOnlyAPIKey(t *testing.T) {
+	srv, _ := NewTestAPIKeysResource()
+	created := createTestAPIKey(t, srv, `{"name": "reader", "role": "read-only"}`)
+
+	w := serveKeyRequest(t, srv, "GET", common.SubscribersEndpoint+"?newsletter="+testNewsletter, created.Token, "")
+	if w.Code != http.StatusOK {
+		t.Errorf("Unexpected status code for GET %d", w.Code)
+	}
+
+	body := `[{"newsletter": "` + testNewsletter + `", "email": "` + testEmail + `"}]`
+	w = serveKeyRequest(t, srv, "PUT", common.SubscribersEndpoint, created.Token, body)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Unexpected status code for PUT %d", w.Code)
+	}
+
+	w = serveKeyRequest(t, srv, "GET", common.APIKeysEndpoint, created.Token, "")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Unexpected status code for API keys %d", w.Code)
+	}
+}
+
+func TestScopedAPIKey(t *testing.T) {
+	srv, _ := NewTestAPIKeysResource()
+	created := createTestAPIKey(t, srv, `{"name": "importer", "role": "import", "newsletters": ["`+testNewsletter+`"]}`)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"GET", common.SubscribersEndpoint + "?newsletter=" + testNewsletter, "", http.StatusOK},
+		{"GET", common.SubscribersEndpoint + "?newsletter=" + otherNewsletter, "", http.StatusForbidden},
+		{"PUT", common.SubscribersEndpoint, `[{"newsletter": "` + testNewsletter + `", "email": "` + testEmail + `"}]`, http.StatusOK},
+		{"PUT", common.SubscribersEndpoint, `[{"newsletter": "` + otherNewsletter + `", "email": "` + testEmail + `"}]`, http.StatusForbidden},
+		{"GET", common.NewslettersEndpoint + "/" + otherNewsletter, "", http.StatusForbidden},
+		{"GET", common.EventsEndpoint, "", http.StatusForbidden},
+		{"GET", common.ComplaintsEndpoint, "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		w := serveKeyRequest(t, srv, tt.method, tt.path, created.Token, tt.body)
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code. method=%v path=%v expected=%v actual=%v", tt.method, tt.path, tt.code, w.Code)
+		}
+	}
+
+	w := serveKeyRequest(t, srv, "GET", common.NewslettersEndpoint, created.Token, "")
+	var newsletters []*common.NewsletterConfig
+	if err := json.Unmarshal(w.Body.Bytes(), &newsletters); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(newsletters) != 1 || newsletters[0].Name != testNewsletter {
+		t.Errorf("Unexpected newsletters %v", newsletters)
+	}
+}
+
+func TestExpiredAPIKey(t *testing.T) {
+	srv, ar := NewTestAPIKeysResource()
+
+	expiresAt := common.JSONTime(time.Now().Add(-time.Minute))
+	key, token, err := common.GenerateAPIKey(&common.APIKeyRequest{Name: "expired", Role: common.RoleAdmin, ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar.APIKeys.AddAPIKey(key)
+
+	w := serveKeyRequest(t, srv, "GET", common.SubscribersEndpoint+"?newsletter="+testNewsletter, token, "")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	srv, _ := NewTestAPIKeysResource()
+	created := createTestAPIKey(t, srv, `{"name": "admin", "role": "admin"}`)
+
+	path := common.SubscribersEndpoint + "?newsletter=" + testNewsletter
+	if w := serveKeyRequest(t, srv, "GET", path, created.Token, ""); w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code before revoke %d", w.Code)
+	}
+
+	w := serveKeyRequest(t, srv, "DELETE", common.APIKeysEndpoint+"/"+created.Key.ID, apiToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code for revoke %d", w.Code)
+	}
+
+	if w := serveKeyRequest(t, srv, "GET", path, created.Token, ""); w.Code != http.StatusForbidden {
+		t.Errorf("Unexpected status code after revoke %d", w.Code)
+	}
+
+	w = serveKeyRequest(t, srv, "DELETE", common.APIKeysEndpoint+"/"+created.Key.ID, apiToken, "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Unexpected status code for second revoke %d", w.Code)
+	}
+}
+
+func TestListAPIKeys(t *testing.T) {
+	srv, _ := NewTestAPIKeysResource()
+	created := createTestAPIKey(t, srv, `{"name": "reader", "role": "read-only"}`)
+
+	w := serveKeyRequest(t, srv, "GET", common.APIKeysEndpoint, apiToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	if strings.Contains(w.Body.String(), "hash") {
+		t.Errorf("Hash is listed %v", w.Body.String())
+	}
+
+	var keys []*common.APIKey
+	if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 1 || keys[0].ID != created.Key.ID || keys[0].Role != common.RoleReadOnly {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+}
+
+func TestAPIKeysNotConfigured(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.Setup(srv)
+
+	w := serveKeyRequest(t, srv, "GET", common.APIKeysEndpoint, apiToken, "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+
+	w = serveKeyRequest(t, srv, "GET", common.SubscribersEndpoint+"?newsletter="+testNewsletter, "id.secret", "")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// OutcomeErased is returned after the address was erased
+const OutcomeErased = "erased"
+
+// PersonalData is everything stored about the address
+type PersonalData struct {
+	Email         string                    `json:"email"`
+	Subscriptions []*common.Subscriber      `json:"subscriptions"`
+	Notifications []*common.SesNotification `json:"notifications"`
+	Events        []*common.SubscriberEvent `json:"events,omitempty"`
+}
+
+// serveData returns personal data of the token owner
+func (nr *NewsletterResource) serveData(w http.ResponseWriter, r *http.Request) {
+	email, ok := nr.preferencesEmail(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := nr.personalData(email)
+	if err != nil {
+		nr.Logger.Error("Failed to collect personal data", "email", email, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	nr.Logger.Info("Exported personal data", "email", email)
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", `attachment; filename="personal-data.json"`)
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (nr *NewsletterResource) personalData(email string) (*PersonalData, error) {
+	data := &PersonalData{
+		Email:         email,
+		Subscriptions: make([]*common.Subscriber, 0),
+		Notifications: make([]*common.SesNotification, 0),
+	}
+
+	for _, s := range nr.subscriptions(email) {
+		data.Subscriptions = append(data.Subscriptions, s)
+	}
+
+	sort.Slice(data.Subscriptions, func(i, j int) bool {
+		return data.Subscriptions[i].Newsletter < data.Subscriptions[j].Newsletter
+	})
+
+	if nr.Notifications != nil {
+		notifications, err := nr.Notifications.EmailNotifications(email)
+		if err != nil {
+			return nil, err
+		}
+		data.Notifications = append(data.Notifications, notifications...)
+	}
+
+	if nr.Events != nil {
+		events, err := nr.Events.Events(email)
+		if err != nil {
+			return nil, err
+		}
+		data.Events = events
+	}
+
+	return data, nil
+}
+
+// serveErase removes the token owner from subscribers and notifications
+// and remembers the salted hash of the address
+func (nr *NewsletterResource) serveErase(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSubscribeBodySize)
+	if err := r.ParseForm(); err != nil {
+		nr.Logger.Warn("Failed to parse form", "err", err)
+	}
+
+	email, ok := nr.preferencesEmail(w, r)
+	if !ok {
+		return
+	}
+
+	if err := nr.erase(email); err != nil {
+		nr.Logger.Error("Failed to erase personal data", "email", email, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	nr.Logger.Info("Erased personal data", "hash", common.EmailHash(nr.ErasureSalt, email))
+	succeed(w, r, OutcomeErased, nr.UnsubscribeRedirectURL)
+}
+
+func (nr *NewsletterResource) erase(email string) error {
+	// hash goes first so that failed erasure can be repeated
+	// without a window for re-import
+	if nr.Erasures != nil {
+		if err := nr.Erasures.AddErasure(common.EmailHash(nr.ErasureSalt, email)); err != nil {
+			return err
+		}
+	}
+
+	subscriptions := nr.subscriptions(email)
+	keys := make([]*common.SubscriberKey, 0, len(subscriptions))
+	for newsletter := range subscriptions {
+		keys = append(keys, &common.SubscriberKey{Newsletter: newsletter, Email: email})
+	}
+
+	if len(keys) > 0 {
+		if err := nr.Subscribers.DeleteSubscribers(keys); err != nil {
+			return err
+		}
+	}
+
+	if nr.Notifications != nil {
+		if err := nr.Notifications.DeleteNotifications(email); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// erasedHashes returns hashes of all erased addresses
+func (ar *AdminResource) erasedHashes() (map[string]bool, error) {
+	hashes := make(map[string]bool)
+	if ar.Erasures == nil {
+		return hashes, nil
+	}
+
+	erasures, err := ar.Erasures.Erasures()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range erasures {
+		hashes[e.Hash] = true
+	}
+
+	return hashes, nil
+}
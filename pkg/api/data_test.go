@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+const erasureSalt = "salt123"
+
+func TestExportPersonalData(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+	store.AddSubscriber("other", testEmail, testName, "", nil)
+	store.AddSubscriber(testNewsletter, "other@email.com", testName, "", nil)
+	notifications := db.NewNotificationsMapStore()
+	notifications.AddBounce(testEmail, "from@email.com", true)
+	notifications.AddComplaint("other@email.com", "from@email.com")
+
+	nr := NewTestNewsResource(store, notifications)
+	nr.AddNewsletters([]string{testNewsletter, "other"})
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.DataEndpoint+"?token="+url.QueryEscape(preferencesToken(testEmail)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	var data PersonalData
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Email != testEmail || len(data.Subscriptions) != 2 || len(data.Notifications) != 1 {
+		t.Errorf("Unexpected personal data: %+v", data)
+	}
+}
+
+func TestExportPersonalDataLegacyToken(t *testing.T) {
+	srv := http.NewServeMux()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.TokenPolicy.LegacyUntil = time.Now().Add(time.Hour)
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.DataEndpoint+"?token="+url.QueryEscape(common.Sign(secret, testEmail)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+}
+
+func TestErasePersonalData(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+	store.AddSubscriber(testNewsletter, "other@email.com", testName, "", nil)
+	notifications := db.NewNotificationsMapStore()
+	notifications.AddComplaint(testEmail, "from@email.com")
+	erasures := db.NewErasuresMapStore()
+
+	nr := NewTestNewsResource(store, notifications)
+	nr.Erasures = erasures
+	nr.ErasureSalt = erasureSalt
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	data := url.Values{}
+	data.Set(common.ParamToken, preferencesToken(testEmail))
+	data.Set(common.ParamFormat, common.FormatJSON)
+
+	req, err := http.NewRequest("POST", common.EraseEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), OutcomeErased) {
+		t.Fatalf("Unexpected response. status=%d body=%v", w.Code, w.Body.String())
+	}
+
+	if _, err := store.GetSubscriber(testNewsletter, testEmail); err == nil {
+		t.Errorf("Subscriber was not erased")
+	}
+
+	if store.Count() != 1 {
+		t.Errorf("Other subscribers were erased")
+	}
+
+	if ns, _ := notifications.EmailNotifications(testEmail); len(ns) != 0 {
+		t.Errorf("Notifications were not erased")
+	}
+
+	es, _ := erasures.Erasures()
+	if len(es) != 1 || es[0].Hash != common.EmailHash(erasureSalt, testEmail) {
+		t.Errorf("Unexpected erasures: %v", es)
+	}
+}
+
+func TestImportSkipsErased(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	erasures := db.NewErasuresMapStore()
+	erasures.AddErasure(common.EmailHash(erasureSalt, testEmail))
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.Erasures = erasures
+	ar.ErasureSalt = erasureSalt
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	body, _ := json.Marshal([]*common.Subscriber{
+		{Newsletter: testNewsletter, Email: strings.ToUpper(testEmail)},
+		{Newsletter: testNewsletter, Email: "other@email.com"},
+	})
+
+	req, err := http.NewRequest("PUT", common.SubscribersEndpoint, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("any username", apiToken)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	if store.Count() != 1 {
+		t.Errorf("Erased subscriber was imported. count=%v", store.Count())
+	}
+}
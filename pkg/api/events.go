@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ribtoks/checkmail"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// user agent is stored as is but there is no reason to keep huge values
+const maxUserAgentLength = 512
+
+// newEvent returns lifecycle event with the details of the request
+func newEvent(r *http.Request, event, newsletter, email, source string) *common.SubscriberEvent {
+	e := common.NewSubscriberEvent(event, newsletter, email)
+	e.IP = clientIP(r)
+	e.UserAgent = r.UserAgent()
+	if len(e.UserAgent) > maxUserAgentLength {
+		e.UserAgent = e.UserAgent[:maxUserAgentLength]
+	}
+	e.Source = source
+
+	return e
+}
+
+// addEvents stores and publishes events if the store and the publisher
+// are configured. Errors are only logged because the subscription has
+// already been changed by then.
+func addEvents(logger *common.Logger, store common.EventsStore, publisher EventPublisher, events []*common.SubscriberEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	if store != nil {
+		if err := store.AddEvents(events); err != nil {
+			logger.Error("Failed to store events", "count", len(events), "err", err)
+		}
+	}
+
+	if publisher != nil {
+		publisher.Publish(events)
+	}
+}
+
+// addEvent stores event with the consent version of the newsletter
+func (nr *NewsletterResource) addEvent(r *http.Request, event, newsletter, email, source string) {
+	e := newEvent(r, event, newsletter, email, source)
+	e.ConsentVersion = nr.config(newsletter).ConsentVersion
+
+	addEvents(nr.Logger, nr.Events, nr.Publisher, []*common.SubscriberEvent{e})
+}
+
+func (ar *AdminResource) serveEvents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		{
+			ar.events(w, r)
+		}
+	default:
+		{
+			ar.Logger.Warn("Unsupported method for events", "method", r.Method)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+// events returns full history of the address in all newsletters
+func (ar *AdminResource) events(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get(common.ParamEmail)
+
+	if err := checkmail.ValidateFormat(email); err != nil {
+		http.Error(w, "The email parameter is invalid", http.StatusBadRequest)
+		return
+	}
+
+	// events are stored with normalized addresses
+	email, err := ar.EmailNormalizer.Normalize(email)
+	if err != nil {
+		http.Error(w, "The email parameter is invalid", http.StatusBadRequest)
+		return
+	}
+
+	if ar.Events == nil {
+		http.Error(w, "Events are not configured", http.StatusNotFound)
+		return
+	}
+
+	events, err := ar.Events.Events(email)
+	if err != nil {
+		ar.Logger.Error("Failed to fetch events", "email", email, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	if events == nil {
+		events = make([]*common.SubscriberEvent, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(events)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
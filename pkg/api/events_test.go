@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func eventTypes(events []*common.SubscriberEvent) []string {
+	types := make([]string, 0, len(events))
+	for _, e := range events {
+		types = append(types, e.Event)
+	}
+	return types
+}
+
+func TestSubscriptionEvents(t *testing.T) {
+	srv := http.NewServeMux()
+	events := db.NewEventsMapStore()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Events = events
+	nr.ConsentVersion = "v1"
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, testEmail)
+
+	req := formRequest(data)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "test-agent")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, r := range []struct {
+		endpoint string
+		token    string
+	}{
+		{common.ConfirmEndpoint, confirmToken(testEmail)},
+		{common.UnsubscribeEndpoint, unsubscribeToken(testEmail)},
+	} {
+		req, err := http.NewRequest("GET", r.endpoint, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := req.URL.Query()
+		q.Add(common.ParamNewsletter, testNewsletter)
+		q.Add(common.ParamToken, r.token)
+		req.URL.RawQuery = q.Encode()
+		srv.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	es, _ := events.Events(testEmail)
+	expected := []string{common.EventSubscribe, common.EventConfirmationSent, common.EventConfirm, common.EventUnsubscribe}
+	if types := eventTypes(es); len(types) != len(expected) {
+		t.Fatalf("Unexpected events: %v", types)
+	}
+
+	for i, e := range es {
+		if e.Event != expected[i] {
+			t.Errorf("Unexpected event. actual=%v expected=%v", e.Event, expected[i])
+		}
+
+		if e.Newsletter != testNewsletter || e.ConsentVersion != "v1" {
+			t.Errorf("Unexpected event details: %+v", e)
+		}
+	}
+
+	if es[0].IP != "192.0.2.1" || es[0].UserAgent != "test-agent" || es[0].Source != common.SourceForm {
+		t.Errorf("Unexpected request details: %+v", es[0])
+	}
+
+	if es[2].Source != common.SourceEmail {
+		t.Errorf("Unexpected confirm source: %v", es[2].Source)
+	}
+}
+
+func TestSubscriptionEventsConsentVersion(t *testing.T) {
+	srv := http.NewServeMux()
+	events := db.NewEventsMapStore()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Events = events
+	nr.ConsentVersion = "v1"
+	nr.AddNewsletterConfigs([]*common.NewsletterConfig{{Name: testNewsletter, ConsentVersion: "v2"}})
+	nr.Setup(srv)
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, testEmail)
+	srv.ServeHTTP(httptest.NewRecorder(), formRequest(data))
+
+	es, _ := events.Events(testEmail)
+	if len(es) == 0 || es[0].ConsentVersion != "v2" {
+		t.Errorf("Consent version of the newsletter is not used: %v", es)
+	}
+}
+
+func TestAdminEvents(t *testing.T) {
+	srv := http.NewServeMux()
+	events := db.NewEventsMapStore()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.Events = events
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	subscribers, _ := json.Marshal([]*common.Subscriber{{Newsletter: testNewsletter, Email: testEmail}})
+	keys, _ := json.Marshal([]*common.SubscriberKey{{Newsletter: testNewsletter, Email: testEmail}})
+
+	for _, r := range []struct {
+		method string
+		body   []byte
+	}{
+		{"PUT", subscribers},
+		{"DELETE", keys},
+	} {
+		req, err := http.NewRequest(r.method, common.SubscribersEndpoint, bytes.NewBuffer(r.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("any username", apiToken)
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status code %d", w.Code)
+		}
+	}
+
+	req, err := http.NewRequest("GET", common.EventsEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := req.URL.Query()
+	q.Add(common.ParamEmail, testEmail)
+	req.URL.RawQuery = q.Encode()
+	req.SetBasicAuth("any username", apiToken)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	var es []*common.SubscriberEvent
+	if err := json.NewDecoder(w.Body).Decode(&es); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(es) != 2 || es[0].Event != common.EventImport || es[1].Event != common.EventDelete {
+		t.Errorf("Unexpected events: %v", eventTypes(es))
+	}
+
+	if es[0].Source != common.SourceAdmin {
+		t.Errorf("Unexpected source: %v", es[0].Source)
+	}
+}
+
+func TestAdminEventsNormalizesEmail(t *testing.T) {
+	srv := http.NewServeMux()
+	events := db.NewEventsMapStore()
+	events.AddEvents([]*common.SubscriberEvent{
+		common.NewSubscriberEvent(common.EventSubscribe, testNewsletter, testEmail),
+	})
+
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.Events = events
+	ar.Setup(srv)
+
+	req, err := adminRequest("GET", common.EventsEndpoint, "", map[string]string{common.ParamEmail: "foo@BAR.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	var es []*common.SubscriberEvent
+	if err := json.NewDecoder(w.Body).Decode(&es); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(es) != 1 || es[0].Email != testEmail {
+		t.Errorf("Unexpected events: %v", eventTypes(es))
+	}
+}
+
+func TestAdminEventsInvalidEmail(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.Events = db.NewEventsMapStore()
+	ar.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.EventsEndpoint+"?email=invalid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("any username", apiToken)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+}
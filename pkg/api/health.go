@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// Pinger is implemented by stores and mailers that can check if the
+// remote service is reachable
+type Pinger interface {
+	Ping() error
+}
+
+// HealthStatus is a body of /healthz and /readyz endpoints
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+const (
+	healthOK     = "ok"
+	healthFailed = "failed"
+	// readyCacheTTL limits how often unauthenticated /readyz requests
+	// reach DynamoDB and SES
+	readyCacheTTL = 5 * time.Second
+)
+
+// serveHealth reports that the process is up without checking anything
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, &HealthStatus{Status: healthOK})
+}
+
+// serveReady pings dependencies that support it. Dependencies without
+// Ping (e.g. in-memory stores) and unset ones are considered ready.
+// The result is reused for readyCacheTTL.
+func serveReady(logger *common.Logger, dependencies map[string]interface{}) http.HandlerFunc {
+	var mu sync.Mutex
+	var checked time.Time
+	var hs *HealthStatus
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if hs == nil || time.Since(checked) >= readyCacheTTL {
+			hs = checkReady(logger, dependencies)
+			checked = time.Now()
+		}
+		current := hs
+		mu.Unlock()
+
+		status := http.StatusOK
+		if current.Status != healthOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, status, current)
+	}
+}
+
+func checkReady(logger *common.Logger, dependencies map[string]interface{}) *HealthStatus {
+	hs := &HealthStatus{
+		Status: healthOK,
+		Checks: make(map[string]string),
+	}
+
+	for name, d := range dependencies {
+		p, ok := d.(Pinger)
+		if !ok {
+			continue
+		}
+
+		if err := p.Ping(); err != nil {
+			logger.Warn("Dependency is not ready", "name", name, "err", err)
+			hs.Checks[name] = healthFailed
+			hs.Status = healthFailed
+
+			continue
+		}
+
+		hs.Checks[name] = healthOK
+	}
+
+	return hs
+}
+
+func (nr *NewsletterResource) dependencies() map[string]interface{} {
+	return map[string]interface{}{
+		"subscribers":   nr.Subscribers,
+		"notifications": nr.Notifications,
+		"mailer":        nr.Mailer,
+	}
+}
+
+func (ar *AdminResource) dependencies() map[string]interface{} {
+	return map[string]interface{}{
+		"subscribers":   ar.Subscribers,
+		"notifications": ar.Notifications,
+	}
+}
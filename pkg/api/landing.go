@@ -0,0 +1,152 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// pageStyle is shared by all server-rendered pages
+const pageStyle = `    <style>
+      body {
+        background-color: #f6f6f6;
+        font-family: sans-serif;
+        font-size: 14px;
+        line-height: 1.4;
+        margin: 0;
+        padding: 0;
+      }
+
+      .container {
+        background: #ffffff;
+        border-radius: 3px;
+        margin: 40px auto;
+        max-width: 480px;
+        padding: 20px;
+        text-align: center;
+      }
+
+      button {
+        background-color: #3498db;
+        border: solid 1px #3498db;
+        border-radius: 5px;
+        color: #ffffff;
+        cursor: pointer;
+        font-size: 14px;
+        font-weight: bold;
+        padding: 12px 25px;
+      }
+    </style>
+`
+
+// landingHTML is a template of the page with a single button that
+// submits newsletter and token back to the same url with POST method
+const landingHTML = `<!doctype html>
+<html>
+  <head>
+    <meta name="viewport" content="width=device-width" />
+    <meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
+    <meta name="robots" content="noindex, nofollow" />
+    <title>{{.Title}}</title>
+` + pageStyle + `  </head>
+  <body>
+    <div class="container">
+      <h1>{{.Title}}</h1>
+      <p>{{.Text}}</p>
+      <form method="POST" action="">
+        <input type="hidden" name="newsletter" value="{{.Newsletter}}" />
+        <input type="hidden" name="token" value="{{.Token}}" />
+        <button type="submit">{{.Button}}</button>
+      </form>
+    </div>
+  </body>
+</html>
+`
+
+var landingTemplate = template.Must(template.New("Landing").Parse(landingHTML))
+
+// landingText is a translation of the landing page. Text is a format
+// string that receives the newsletter title.
+type landingText struct {
+	Title  string
+	Text   string
+	Button string
+}
+
+// translations of the landing pages by locale
+var (
+	confirmTexts = map[string]*landingText{
+		"en": {
+			Title:  "Confirm subscription",
+			Text:   "Press the button below to confirm your subscription to %s newsletter.",
+			Button: "Confirm",
+		},
+		"uk": {
+			Title:  "Підтвердження підписки",
+			Text:   "Натисніть кнопку нижче, щоб підтвердити підписку на розсилку %s.",
+			Button: "Підтвердити",
+		},
+		"de": {
+			Title:  "Abonnement bestätigen",
+			Text:   "Klicken Sie auf die Schaltfläche unten, um Ihr Abonnement des Newsletters %s zu bestätigen.",
+			Button: "Bestätigen",
+		},
+	}
+	unsubscribeTexts = map[string]*landingText{
+		"en": {
+			Title:  "Unsubscribe",
+			Text:   "Press the button below to unsubscribe from %s newsletter.",
+			Button: "Unsubscribe",
+		},
+		"uk": {
+			Title:  "Відписатися",
+			Text:   "Натисніть кнопку нижче, щоб відписатися від розсилки %s.",
+			Button: "Відписатися",
+		},
+		"de": {
+			Title:  "Abmelden",
+			Text:   "Klicken Sie auf die Schaltfläche unten, um sich vom Newsletter %s abzumelden.",
+			Button: "Abmelden",
+		},
+	}
+)
+
+// landingPage is the data for landingTemplate
+type landingPage struct {
+	Title      string
+	Text       string
+	Button     string
+	Newsletter string
+	Token      string
+}
+
+// newLandingPage chooses translation for the locale of the request
+func newLandingPage(r *http.Request, texts map[string]*landingText, title, newsletter, token string) *landingPage {
+	t := texts[common.DefaultLocale]
+	for _, l := range common.LocaleFallbacks(requestLocale(r), common.DefaultLocale) {
+		if lt, ok := texts[l]; ok {
+			t = lt
+			break
+		}
+	}
+
+	return &landingPage{
+		Title:      t.Title,
+		Text:       fmt.Sprintf(t.Text, title),
+		Button:     t.Button,
+		Newsletter: newsletter,
+		Token:      token,
+	}
+}
+
+func renderLanding(w http.ResponseWriter, page *landingPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := landingTemplate.Execute(w, page); err != nil {
+		common.DefaultLogger.Error("Failed to render landing page", "err", err)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// requestLocale returns locale from the form field or from the most
+// preferred language of Accept-Language header
+func requestLocale(r *http.Request) string {
+	if locale := common.NormalizeLocale(r.FormValue(common.ParamLocale)); locale != "" {
+		return locale
+	}
+
+	return acceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// acceptLanguage parses header like "uk-UA,uk;q=0.9,en;q=0.8"
+// and returns the language with the highest quality
+func acceptLanguage(header string) string {
+	best := ""
+	bestQ := 0.0
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale := common.NormalizeLocale(fields[0])
+		if locale == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = locale, q
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+type LocaleMailer struct {
+	locale string
+}
+
+func (m *LocaleMailer) SendConfirmation(newsletter *common.NewsletterConfig, email, name, locale string) error {
+	m.locale = locale
+	return nil
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"*", ""},
+		{"uk", "uk"},
+		{"uk-UA,uk;q=0.9,en;q=0.8", "uk-ua"},
+		{"en;q=0.5, de-AT;q=0.9", "de-at"},
+		{"*;q=1, de;q=0.3", "de"},
+		{"en;q=abc", "en"},
+	}
+
+	for _, tt := range tests {
+		if l := acceptLanguage(tt.header); l != tt.expected {
+			t.Errorf("Unexpected locale. header=%q actual=%v expected=%v", tt.header, l, tt.expected)
+		}
+	}
+}
+
+func TestSubscribeLocale(t *testing.T) {
+	tests := []struct {
+		locale         string
+		acceptLanguage string
+		expected       string
+	}{
+		{"", "", ""},
+		{"de_AT", "uk", "de-at"},
+		{"", "uk-UA,uk;q=0.9,en;q=0.8", "uk-ua"},
+		{"<script>", "de", "de"},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+		store := db.NewSubscribersMapStore()
+		mailer := &LocaleMailer{}
+		nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+		nr.Mailer = mailer
+		nr.AddNewsletters([]string{testNewsletter})
+		nr.Setup(srv)
+
+		data := url.Values{}
+		data.Set(common.ParamNewsletter, testNewsletter)
+		data.Set(common.ParamEmail, testEmail)
+		if tt.locale != "" {
+			data.Set(common.ParamLocale, tt.locale)
+		}
+
+		req := formRequest(data)
+		if tt.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		s, err := store.GetSubscriber(testNewsletter, testEmail)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.Locale != tt.expected {
+			t.Errorf("Unexpected stored locale. actual=%v expected=%v", s.Locale, tt.expected)
+		}
+
+		if mailer.locale != tt.expected {
+			t.Errorf("Unexpected email locale. actual=%v expected=%v", mailer.locale, tt.expected)
+		}
+	}
+}
+
+func TestConfirmInterstitialLocalized(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		expected       string
+	}{
+		{"", "Confirm subscription"},
+		{"uk-UA", "Підтвердження підписки"},
+		{"de", "Abonnement bestätigen"},
+		{"fr", "Confirm subscription"},
+	}
+
+	for _, tt := range tests {
+		srv := http.NewServeMux()
+		store := db.NewSubscribersMapStore()
+		store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+		nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+		nr.AddNewsletters([]string{testNewsletter})
+		nr.Interstitial = true
+		nr.Setup(srv)
+
+		req, err := http.NewRequest("GET", common.ConfirmEndpoint, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q := req.URL.Query()
+		q.Add(common.ParamNewsletter, testNewsletter)
+		q.Add(common.ParamToken, confirmToken(testEmail))
+		req.URL.RawQuery = q.Encode()
+		if tt.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		body, _ := ioutil.ReadAll(w.Result().Body)
+		if !strings.Contains(string(body), tt.expected) {
+			t.Errorf("Landing page is not localized. language=%v body=%v", tt.acceptLanguage, string(body))
+		}
+	}
+}
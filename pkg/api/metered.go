@@ -0,0 +1,245 @@
+package api
+
+import (
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// meteredSubscribers records latency and errors of the store operations
+type meteredSubscribers struct {
+	store   common.SubscribersStore
+	metrics *Metrics
+}
+
+var _ common.SubscribersStore = (*meteredSubscribers)(nil)
+
+// meterSubscribers wraps the store unless it is already wrapped
+func meterSubscribers(store common.SubscribersStore, metrics *Metrics) common.SubscribersStore {
+	if _, ok := store.(*meteredSubscribers); ok || store == nil || metrics == nil {
+		return store
+	}
+
+	return &meteredSubscribers{store: store, metrics: metrics}
+}
+
+func (ms *meteredSubscribers) observe(operation string, start time.Time, err error) {
+	observeStore(ms.metrics, "subscribers", operation, start, err)
+}
+
+func (ms *meteredSubscribers) WithLogger(l *common.Logger) common.SubscribersStore {
+	return &meteredSubscribers{store: scopedSubscribers(ms.store, l), metrics: ms.metrics}
+}
+
+func (ms *meteredSubscribers) Ping() error {
+	return ping(ms.store)
+}
+
+func (ms *meteredSubscribers) AddSubscriber(newsletter, email, name, locale string, attributes map[string]string) error {
+	start := time.Now()
+	err := ms.store.AddSubscriber(newsletter, email, name, locale, attributes)
+	ms.observe("add_subscriber", start, err)
+
+	return err
+}
+
+func (ms *meteredSubscribers) RemoveSubscriber(newsletter, email string) error {
+	start := time.Now()
+	err := ms.store.RemoveSubscriber(newsletter, email)
+	ms.observe("remove_subscriber", start, err)
+
+	return err
+}
+
+func (ms *meteredSubscribers) Subscribers(newsletter string) ([]*common.Subscriber, error) {
+	start := time.Now()
+	subscribers, err := ms.store.Subscribers(newsletter)
+	ms.observe("subscribers", start, err)
+
+	return subscribers, err
+}
+
+func (ms *meteredSubscribers) QuerySubscribers(query *common.SubscribersQuery) (*common.SubscribersPage, error) {
+	start := time.Now()
+	page, err := ms.store.QuerySubscribers(query)
+	ms.observe("query_subscribers", start, err)
+
+	return page, err
+}
+
+func (ms *meteredSubscribers) AddSubscribers(subscribers []*common.Subscriber) error {
+	start := time.Now()
+	err := ms.store.AddSubscribers(subscribers)
+	ms.observe("add_subscribers", start, err)
+
+	return err
+}
+
+func (ms *meteredSubscribers) MarkReminded(newsletter, email string, remindedAt common.JSONTime) error {
+	start := time.Now()
+	err := ms.store.MarkReminded(newsletter, email, remindedAt)
+	ms.observe("mark_reminded", start, err)
+
+	return err
+}
+
+func (ms *meteredSubscribers) DeleteSubscribers(keys []*common.SubscriberKey) error {
+	start := time.Now()
+	err := ms.store.DeleteSubscribers(keys)
+	ms.observe("delete_subscribers", start, err)
+
+	return err
+}
+
+func (ms *meteredSubscribers) ConfirmSubscriber(newsletter, email string) error {
+	start := time.Now()
+	err := ms.store.ConfirmSubscriber(newsletter, email)
+	ms.observe("confirm_subscriber", start, err)
+
+	return err
+}
+
+func (ms *meteredSubscribers) UpdateSubscriber(newsletter, email string, update *common.SubscriberUpdate) (*common.Subscriber, error) {
+	start := time.Now()
+	s, err := ms.store.UpdateSubscriber(newsletter, email, update)
+	ms.observe("update_subscriber", start, err)
+
+	return s, err
+}
+
+func (ms *meteredSubscribers) GetSubscriber(newsletter, email string) (*common.Subscriber, error) {
+	start := time.Now()
+	s, err := ms.store.GetSubscriber(newsletter, email)
+	ms.observe("get_subscriber", start, err)
+
+	return s, err
+}
+
+// meteredNotifications records latency and errors of the store operations
+type meteredNotifications struct {
+	store   common.NotificationsStore
+	metrics *Metrics
+}
+
+var _ common.NotificationsStore = (*meteredNotifications)(nil)
+
+// meterNotifications wraps the store unless it is already wrapped
+func meterNotifications(store common.NotificationsStore, metrics *Metrics) common.NotificationsStore {
+	if _, ok := store.(*meteredNotifications); ok || store == nil || metrics == nil {
+		return store
+	}
+
+	return &meteredNotifications{store: store, metrics: metrics}
+}
+
+func (mn *meteredNotifications) observe(operation string, start time.Time, err error) {
+	observeStore(mn.metrics, "notifications", operation, start, err)
+}
+
+func (mn *meteredNotifications) WithLogger(l *common.Logger) common.NotificationsStore {
+	return &meteredNotifications{store: scopedNotifications(mn.store, l), metrics: mn.metrics}
+}
+
+func (mn *meteredNotifications) Ping() error {
+	return ping(mn.store)
+}
+
+func (mn *meteredNotifications) AddBounce(email, from string, isTransient bool) error {
+	start := time.Now()
+	err := mn.store.AddBounce(email, from, isTransient)
+	mn.observe("add_bounce", start, err)
+
+	return err
+}
+
+func (mn *meteredNotifications) AddComplaint(email, from string) error {
+	start := time.Now()
+	err := mn.store.AddComplaint(email, from)
+	mn.observe("add_complaint", start, err)
+
+	return err
+}
+
+func (mn *meteredNotifications) Notifications() ([]*common.SesNotification, error) {
+	start := time.Now()
+	notifications, err := mn.store.Notifications()
+	mn.observe("notifications", start, err)
+
+	return notifications, err
+}
+
+func (mn *meteredNotifications) EmailNotifications(email string) ([]*common.SesNotification, error) {
+	start := time.Now()
+	notifications, err := mn.store.EmailNotifications(email)
+	mn.observe("email_notifications", start, err)
+
+	return notifications, err
+}
+
+func (mn *meteredNotifications) LiftSuppression(email string) error {
+	start := time.Now()
+	err := mn.store.LiftSuppression(email)
+	mn.observe("lift_suppression", start, err)
+
+	return err
+}
+
+func (mn *meteredNotifications) DeleteNotifications(email string) error {
+	start := time.Now()
+	err := mn.store.DeleteNotifications(email)
+	mn.observe("delete_notifications", start, err)
+
+	return err
+}
+
+// meteredMailer counts sent and failed confirmation emails
+type meteredMailer struct {
+	mailer  common.Mailer
+	metrics *Metrics
+}
+
+var _ common.Mailer = (*meteredMailer)(nil)
+
+// meterMailer wraps the mailer unless it is already wrapped
+func meterMailer(mailer common.Mailer, metrics *Metrics) common.Mailer {
+	if _, ok := mailer.(*meteredMailer); ok || mailer == nil || metrics == nil {
+		return mailer
+	}
+
+	return &meteredMailer{mailer: mailer, metrics: metrics}
+}
+
+func (mm *meteredMailer) WithLogger(l *common.Logger) common.Mailer {
+	return &meteredMailer{mailer: scopedMailer(mm.mailer, l), metrics: mm.metrics}
+}
+
+func (mm *meteredMailer) Ping() error {
+	return ping(mm.mailer)
+}
+
+func (mm *meteredMailer) SendConfirmation(newsletter *common.NewsletterConfig, email, name, locale string) error {
+	err := mm.mailer.SendConfirmation(newsletter, email, name, locale)
+	if err != nil {
+		mm.metrics.Inc(MetricMailerFailures, "newsletter", newsletter.Name)
+	} else {
+		mm.metrics.Inc(MetricMailerSent, "newsletter", newsletter.Name)
+	}
+
+	return err
+}
+
+func observeStore(metrics *Metrics, store, operation string, start time.Time, err error) {
+	metrics.ObserveSince(MetricStoreDuration, start, "store", store, "operation", operation)
+	if err != nil {
+		metrics.Inc(MetricStoreErrors, "store", store, "operation", operation)
+	}
+}
+
+// ping checks the wrapped dependency if it supports it
+func ping(d interface{}) error {
+	if p, ok := d.(Pinger); ok {
+		return p.Ping()
+	}
+
+	return nil
+}
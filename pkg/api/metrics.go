@@ -0,0 +1,271 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// names of the collected metrics
+const (
+	MetricOutcomes       = "listing_outcomes_total"
+	MetricStoreDuration  = "listing_store_duration_seconds"
+	MetricStoreErrors    = "listing_store_errors_total"
+	MetricMailerSent     = "listing_mailer_sent_total"
+	MetricMailerFailures = "listing_mailer_failures_total"
+	MetricImportSize     = "listing_import_subscribers"
+)
+
+var (
+	latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+	sizeBuckets    = []float64{1, 10, 100, 1000, 10000, 100000}
+)
+
+var metricsHelp = map[string]string{
+	MetricOutcomes:       "Outcomes of the public endpoints.",
+	MetricStoreDuration:  "Latency of the store operations.",
+	MetricStoreErrors:    "Failed store operations.",
+	MetricMailerSent:     "Sent confirmation emails.",
+	MetricMailerFailures: "Confirmation emails that failed to send.",
+	MetricImportSize:     "Number of subscribers in admin imports.",
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(value float64) {
+	for i, b := range h.buckets {
+		if value <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// Metrics keeps counters and histograms in memory of the process and
+// writes them in Prometheus text format. Methods of nil Metrics do nothing.
+type Metrics struct {
+	mu         sync.Mutex
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+}
+
+// NewMetrics creates empty metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats pairs of label names and values
+func labels(pairs []string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1])))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Inc adds one to the counter with labels given as name/value pairs
+func (m *Metrics) Inc(name string, pairs ...string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	values, ok := m.counters[name]
+	if !ok {
+		values = make(map[string]float64)
+		m.counters[name] = values
+	}
+	values[labels(pairs)]++
+}
+
+// Observe adds the value to the histogram with labels given as
+// name/value pairs
+func (m *Metrics) Observe(name string, buckets []float64, value float64, pairs ...string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	values, ok := m.histograms[name]
+	if !ok {
+		values = make(map[string]*histogram)
+		m.histograms[name] = values
+	}
+
+	key := labels(pairs)
+	h, ok := values[key]
+	if !ok {
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		values[key] = h
+	}
+	h.observe(value)
+}
+
+// ObserveSince adds time passed from start in seconds to the histogram
+func (m *Metrics) ObserveSince(name string, start time.Time, pairs ...string) {
+	m.Observe(name, latencyBuckets, time.Since(start).Seconds(), pairs...)
+}
+
+func withLabel(key, label string) string {
+	if key == "" {
+		return "{" + label + "}"
+	}
+
+	return "{" + key + "," + label + "}"
+}
+
+func braces(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	return "{" + key + "}"
+}
+
+// WriteTo writes metrics in Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	names := make([]string, 0, len(m.counters))
+	for name := range m.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, metricsHelp[name], name)
+
+		values := m.counters[name]
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s%s %v\n", name, braces(k), values[k])
+		}
+	}
+
+	names = make([]string, 0, len(m.histograms))
+	for name := range m.histograms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", name, metricsHelp[name], name)
+
+		values := m.histograms[name]
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			h := values[k]
+			for i, bucket := range h.buckets {
+				fmt.Fprintf(&b, "%s_bucket%s %v\n", name, withLabel(k, fmt.Sprintf(`le="%v"`, bucket)), h.counts[i])
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %v\n", name, withLabel(k, `le="+Inf"`), h.count)
+			fmt.Fprintf(&b, "%s_sum%s %v\n", name, braces(k), h.sum)
+			fmt.Fprintf(&b, "%s_count%s %v\n", name, braces(k), h.count)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+func (m *Metrics) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := m.WriteTo(w); err != nil {
+		common.DefaultLogger.Error("Failed to write metrics", "err", err)
+	}
+}
+
+// outcomeWriter remembers the outcome of the public endpoint
+type outcomeWriter struct {
+	http.ResponseWriter
+	outcome string
+	status  int
+}
+
+func (ow *outcomeWriter) WriteHeader(status int) {
+	ow.status = status
+	ow.ResponseWriter.WriteHeader(status)
+}
+
+// setOutcome passes the outcome to the metered handler if there is one
+func setOutcome(w http.ResponseWriter, outcome string) {
+	if ow, ok := w.(*outcomeWriter); ok {
+		ow.outcome = outcome
+	}
+}
+
+// metered counts outcomes of the endpoint. Responses without outcome
+// are counted by the status code.
+func (nr *NewsletterResource) metered(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	if nr.Metrics == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ow := &outcomeWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(ow, r)
+
+		outcome := ow.outcome
+		if outcome == "" {
+			outcome = fmt.Sprintf("status_%d", ow.status)
+		}
+
+		nr.Metrics.Inc(MetricOutcomes, "endpoint", endpoint, "outcome", outcome)
+	}
+}
+
+// metricsAuth checks basic auth password of the metrics scraper
+func (nr *NewsletterResource) metricsAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, pass, ok := r.BasicAuth()
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		if pass != nr.MetricsToken {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
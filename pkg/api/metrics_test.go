@@ -0,0 +1,230 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+const metricsToken = "metrics-token"
+
+// UnreachableSubscribersStore is a store that fails readiness checks
+type UnreachableSubscribersStore struct {
+	*db.SubscribersMapStore
+}
+
+func (s *UnreachableSubscribersStore) Ping() error {
+	return errors.New("unreachable")
+}
+
+// CountingPinger counts readiness checks
+type CountingPinger struct {
+	pings int
+}
+
+func (p *CountingPinger) Ping() error {
+	p.pings++
+	return nil
+}
+
+func scrape(t *testing.T, srv *http.ServeMux, path, token string) string {
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("any", token)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected metrics status code %d", w.Code)
+	}
+
+	return w.Body.String()
+}
+
+func TestMetricsCountOutcomes(t *testing.T) {
+	srv := http.NewServeMux()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Metrics = NewMetrics()
+	nr.MetricsToken = metricsToken
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	for _, email := range []string{testEmail, "invalid"} {
+		req, err := subscribeRequest(email, "127.0.0.1:1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	body := scrape(t, srv, common.MetricsEndpoint, metricsToken)
+
+	for _, expected := range []string{
+		`listing_outcomes_total{endpoint="/subscribe",outcome="pending_confirmation"} 1`,
+		`listing_outcomes_total{endpoint="/subscribe",outcome="invalid_email"} 1`,
+		`listing_mailer_sent_total{newsletter="` + testNewsletter + `"} 1`,
+		`listing_store_duration_seconds_count{store="subscribers",operation="add_subscriber"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Metric is missing. expected=%v body=%v", expected, body)
+		}
+	}
+}
+
+func TestMetricsRequireToken(t *testing.T) {
+	srv := http.NewServeMux()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Metrics = NewMetrics()
+	nr.MetricsToken = metricsToken
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.MetricsEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+
+	req.SetBasicAuth("any", "wrong")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+}
+
+func TestMetricsImportSize(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.Metrics = NewMetrics()
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	subscribers := []*common.Subscriber{
+		{Newsletter: testNewsletter, Email: "foo@bar.com"},
+		{Newsletter: testNewsletter, Email: "bar@foo.com"},
+	}
+	data, _ := json.Marshal(subscribers)
+
+	req, err := http.NewRequest("PUT", common.SubscribersEndpoint, bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("any", apiToken)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	body := scrape(t, srv, common.MetricsEndpoint, apiToken)
+
+	for _, expected := range []string{
+		`listing_import_subscribers_bucket{le="10"} 1`,
+		`listing_import_subscribers_sum 2`,
+		`listing_store_duration_seconds_count{store="subscribers",operation="add_subscribers"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Metric is missing. expected=%v body=%v", expected, body)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	srv := http.NewServeMux()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Setup(srv)
+
+	for _, endpoint := range []string{common.HealthEndpoint, common.ReadyEndpoint} {
+		req, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Unexpected status code. endpoint=%v code=%v", endpoint, w.Code)
+		}
+	}
+}
+
+func TestReadyFailsForUnreachableStore(t *testing.T) {
+	srv := http.NewServeMux()
+	store := &UnreachableSubscribersStore{db.NewSubscribersMapStore()}
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.Metrics = NewMetrics()
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.ReadyEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	var hs HealthStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &hs); err != nil {
+		t.Fatal(err)
+	}
+
+	if hs.Checks["subscribers"] != healthFailed {
+		t.Errorf("Unexpected checks %v", hs.Checks)
+	}
+
+	req, _ = http.NewRequest("GET", common.HealthEndpoint, nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Liveness depends on the store. code=%v", w.Code)
+	}
+}
+
+func TestReadyIsCached(t *testing.T) {
+	p := &CountingPinger{}
+	handler := serveReady(nil, map[string]interface{}{"subscribers": p})
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("GET", common.ReadyEndpoint, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Unexpected status code %d", w.Code)
+		}
+	}
+
+	if p.pings != 1 {
+		t.Errorf("Dependencies are checked on every request. pings=%v", p.pings)
+	}
+}
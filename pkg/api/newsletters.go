@@ -0,0 +1,334 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+const archiveAction = "archive"
+
+// newsletterPath returns the name and the optional action from the path
+// /newsletters/{name}[/archive]
+func newsletterPath(u *url.URL) (string, string, bool) {
+	path := strings.TrimPrefix(u.EscapedPath(), common.NewslettersEndpoint+"/")
+	parts := strings.Split(path, "/")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != archiveAction) {
+		return "", "", false
+	}
+
+	name, err := url.PathUnescape(parts[0])
+	if err != nil || name == "" {
+		return "", "", false
+	}
+
+	if len(parts) == 2 {
+		return name, parts[1], true
+	}
+
+	return name, "", true
+}
+
+// serveNewsletters lists and creates newsletters
+func (ar *AdminResource) serveNewsletters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		{
+			ar.listNewsletters(w, r)
+		}
+	case "POST":
+		{
+			ar.createNewsletter(w, r)
+		}
+	default:
+		{
+			ar.Logger.Warn("Unsupported method for newsletters", "method", r.Method)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+// serveNewsletter manages one newsletter
+func (ar *AdminResource) serveNewsletter(w http.ResponseWriter, r *http.Request) {
+	name, action, ok := newsletterPath(r.URL)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	if !ar.allowsNewsletter(name) {
+		http.Error(w, errNewsletterForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case action == archiveAction && r.Method == "POST":
+		{
+			ar.archiveNewsletter(w, r, name)
+		}
+	case action == "" && r.Method == "GET":
+		{
+			ar.getNewsletter(w, r, name)
+		}
+	case action == "" && r.Method == "PUT":
+		{
+			ar.updateNewsletter(w, r, name)
+		}
+	case action == "" && r.Method == "DELETE":
+		{
+			ar.deleteNewsletter(w, r, name)
+		}
+	default:
+		{
+			ar.Logger.Warn("Unsupported method for newsletter", "method", r.Method, "action", action)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+// findNewsletter reads the config from the store bypassing the cache and
+// responds with an error if it cannot be fetched
+func (ar *AdminResource) findNewsletter(w http.ResponseWriter, name string) (*common.NewsletterConfig, bool) {
+	newsletters, err := ar.Newsletters.Store.Newsletters()
+	if err != nil {
+		ar.Logger.Error("Failed to fetch newsletters", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return nil, false
+	}
+
+	for _, nc := range newsletters {
+		if nc.Name == name {
+			return nc, true
+		}
+	}
+
+	http.Error(w, common.ErrNewsletterNotFound.Error(), http.StatusNotFound)
+	return nil, false
+}
+
+// decodeNewsletter reads the config from the request body
+func (ar *AdminResource) decodeNewsletter(w http.ResponseWriter, r *http.Request) (*common.NewsletterConfig, bool) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type header is not application/json", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPatchBodySize)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	nc := &common.NewsletterConfig{}
+	err := dec.Decode(nc)
+	if err != nil {
+		ar.Logger.Warn("Failed to decode newsletter", "err", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return nil, false
+	}
+
+	return nc, true
+}
+
+// saveNewsletter responds with the config or with the error of the store
+func (ar *AdminResource) saveNewsletter(w http.ResponseWriter, nc *common.NewsletterConfig, status int, save func(*common.NewsletterConfig) error) {
+	if err := nc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := save(nc)
+	switch err {
+	case nil:
+	case common.ErrNewsletterExists:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case common.ErrNewsletterNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	default:
+		ar.Logger.Error("Failed to save newsletter", "newsletter", nc.Name, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	ar.Newsletters.Invalidate()
+	ar.Logger.Info("Saved newsletter", "newsletter", nc.Name, "archived", nc.Archived)
+
+	writeJSON(w, status, nc)
+}
+
+func (ar *AdminResource) listNewsletters(w http.ResponseWriter, r *http.Request) {
+	newsletters, err := ar.Newsletters.Store.Newsletters()
+	if err != nil {
+		ar.Logger.Error("Failed to fetch newsletters", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	allowed := make([]*common.NewsletterConfig, 0, len(newsletters))
+	for _, nc := range newsletters {
+		if ar.allowsNewsletter(nc.Name) {
+			allowed = append(allowed, nc)
+		}
+	}
+	newsletters = allowed
+
+	sort.Slice(newsletters, func(i, j int) bool {
+		return newsletters[i].Name < newsletters[j].Name
+	})
+
+	writeJSON(w, http.StatusOK, newsletters)
+}
+
+func (ar *AdminResource) createNewsletter(w http.ResponseWriter, r *http.Request) {
+	nc, ok := ar.decodeNewsletter(w, r)
+	if !ok {
+		return
+	}
+
+	if !ar.allowsNewsletter(nc.Name) {
+		http.Error(w, errNewsletterForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	ar.saveNewsletter(w, nc, http.StatusCreated, ar.Newsletters.Store.AddNewsletter)
+}
+
+func (ar *AdminResource) getNewsletter(w http.ResponseWriter, r *http.Request, name string) {
+	nc, ok := ar.findNewsletter(w, name)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nc)
+}
+
+// updateNewsletter replaces all settings of the newsletter
+func (ar *AdminResource) updateNewsletter(w http.ResponseWriter, r *http.Request, name string) {
+	nc, ok := ar.decodeNewsletter(w, r)
+	if !ok {
+		return
+	}
+
+	if nc.Name == "" {
+		nc.Name = name
+	}
+
+	if nc.Name != name {
+		http.Error(w, "Newsletter cannot be renamed", http.StatusBadRequest)
+		return
+	}
+
+	ar.saveNewsletter(w, nc, http.StatusOK, ar.Newsletters.Store.UpdateNewsletter)
+}
+
+// archiveNewsletter stops new subscriptions and keeps existing subscribers
+func (ar *AdminResource) archiveNewsletter(w http.ResponseWriter, r *http.Request, name string) {
+	nc, ok := ar.findNewsletter(w, name)
+	if !ok {
+		return
+	}
+
+	nc.Archived = true
+	ar.saveNewsletter(w, nc, http.StatusOK, ar.Newsletters.Store.UpdateNewsletter)
+}
+
+// deleteNewsletter removes the newsletter if it has no subscribers.
+// Subscribers are deleted too with cascade parameter.
+func (ar *AdminResource) deleteNewsletter(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := ar.findNewsletter(w, name); !ok {
+		return
+	}
+
+	if r.URL.Query().Get(common.ParamCascade) == "true" {
+		deleted, more, err := ar.deleteSubscribersPage(r, name)
+		if err != nil {
+			ar.Logger.Error("Failed to delete subscribers", "newsletter", name, "err", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+		ar.Logger.Info("Deleted subscribers of newsletter", "newsletter", name, "deleted", deleted)
+
+		// the client repeats the request until the newsletter is deleted
+		if more {
+			writeJSON(w, http.StatusAccepted, &cascadeProgress{Deleted: deleted})
+			return
+		}
+	} else {
+		page, err := ar.Subscribers.QuerySubscribers(&common.SubscribersQuery{Newsletter: name, Limit: 1})
+		if err != nil {
+			ar.Logger.Error("Failed to fetch subscribers", "newsletter", name, "err", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+
+		if len(page.Subscribers) > 0 {
+			http.Error(w, "Newsletter has subscribers, delete them first or use cascade=true", http.StatusConflict)
+			return
+		}
+	}
+
+	err := ar.Newsletters.Store.DeleteNewsletter(name)
+	if err == common.ErrNewsletterNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		ar.Logger.Error("Failed to delete newsletter", "newsletter", name, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	ar.Newsletters.Invalidate()
+	ar.Logger.Info("Deleted newsletter", "newsletter", name)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// cascadeProgress is returned while the deleted newsletter has subscribers
+type cascadeProgress struct {
+	Deleted int `json:"deleted"`
+}
+
+// deleteSubscribersPage removes one page of subscribers of the newsletter
+// and returns the number of deleted ones and if there are more of them.
+// Deleted subscribers are not returned again, so the next call continues
+// where this one stopped.
+func (ar *AdminResource) deleteSubscribersPage(r *http.Request, newsletter string) (int, bool, error) {
+	page, err := ar.Subscribers.QuerySubscribers(&common.SubscribersQuery{
+		Newsletter: newsletter,
+		Limit:      maxSubscribersLimit,
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	keys := make([]*common.SubscriberKey, 0, len(page.Subscribers))
+	events := make([]*common.SubscriberEvent, 0, len(page.Subscribers))
+	for _, s := range page.Subscribers {
+		keys = append(keys, &common.SubscriberKey{Newsletter: newsletter, Email: s.Email})
+		events = append(events, newEvent(r, common.EventDelete, newsletter, s.Email, common.SourceAdmin))
+	}
+
+	if len(keys) > 0 {
+		if err := ar.Subscribers.DeleteSubscribers(keys); err != nil {
+			return 0, false, err
+		}
+		addEvents(ar.Logger, ar.Events, ar.Publisher, events)
+	}
+
+	return len(keys), page.Cursor != "", nil
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func TestCreateNewsletter(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{"b"})
+	ar.Setup(srv)
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"name": "a", "display_name": "Letter A"}`, http.StatusCreated},
+		{`{"name": "a"}`, http.StatusConflict},
+		{`{"name": "c", "opt_in": "triple"}`, http.StatusBadRequest},
+		{`{"name": ""}`, http.StatusBadRequest},
+		{`{"name": "d", "unknown": true}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, err := adminRequest("POST", common.NewslettersEndpoint, tt.body, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code. body=%v expected=%v actual=%v", tt.body, tt.code, w.Code)
+		}
+	}
+
+	if !ar.isValidNewsletter("a") {
+		t.Error("Created newsletter is not valid")
+	}
+
+	req, err := adminRequest("GET", common.NewslettersEndpoint, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	var newsletters []*common.NewsletterConfig
+	if err := json.Unmarshal(w.Body.Bytes(), &newsletters); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(newsletters) != 2 || newsletters[0].Name != "a" || newsletters[0].DisplayName != "Letter A" {
+		t.Errorf("Unexpected newsletters %v", newsletters)
+	}
+}
+
+func TestUpdateNewsletter(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	tests := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/" + testNewsletter, `{"display_name": "Updated"}`, http.StatusOK},
+		{"/" + testNewsletter, `{"name": "other"}`, http.StatusBadRequest},
+		{"/other", `{"display_name": "Other"}`, http.StatusNotFound},
+		{"/" + testNewsletter + "/name", `{}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req, err := adminRequest("PUT", common.NewslettersEndpoint+tt.path, tt.body, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code. path=%v expected=%v actual=%v", tt.path, tt.code, w.Code)
+		}
+	}
+
+	req, err := adminRequest("GET", common.NewslettersEndpoint+"/"+testNewsletter, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	nc := &common.NewsletterConfig{}
+	if err := json.Unmarshal(w.Body.Bytes(), nc); err != nil {
+		t.Fatal(err)
+	}
+
+	if nc.Name != testNewsletter || nc.DisplayName != "Updated" {
+		t.Errorf("Unexpected newsletter %+v", nc)
+	}
+}
+
+func TestArchiveNewsletter(t *testing.T) {
+	srv := http.NewServeMux()
+	subscribers := db.NewSubscribersMapStore()
+	ar := NewTestAdminResource(subscribers, db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	publicSrv := http.NewServeMux()
+	nr := NewTestNewsResource(subscribers, db.NewNotificationsMapStore())
+	nr.Newsletters = ar.Newsletters
+	nr.Setup(publicSrv)
+
+	req, err := adminRequest("POST", common.NewslettersEndpoint+"/"+testNewsletter+"/archive", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	req, err = subscribeRequest(testEmail, "1.1.1.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	publicSrv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Archived newsletter accepted subscription. status=%v", w.Code)
+	}
+
+	if subscribers.Count() != 0 {
+		t.Errorf("Unexpected subscribers count %v", subscribers.Count())
+	}
+
+	if !ar.isValidNewsletter(testNewsletter) {
+		t.Error("Archived newsletter is not managed by admin")
+	}
+}
+
+func TestDeleteNewsletter(t *testing.T) {
+	srv := http.NewServeMux()
+	subscribers := db.NewSubscribersMapStore()
+	for i := 0; i < maxSubscribersLimit+10; i++ {
+		subscribers.AddSubscriber(testNewsletter, fmt.Sprintf("email%v@domain.com", i), testName, "", nil)
+	}
+	subscribers.AddSubscriber("other", testEmail, testName, "", nil)
+	events := db.NewEventsMapStore()
+
+	ar := NewTestAdminResource(subscribers, db.NewNotificationsMapStore())
+	ar.Events = events
+	ar.AddNewsletters([]string{testNewsletter, "other", "empty"})
+	ar.Setup(srv)
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/" + testNewsletter, http.StatusConflict},
+		{"/empty", http.StatusOK},
+		{"/empty", http.StatusNotFound},
+		{"/" + testNewsletter + "?cascade=true", http.StatusAccepted},
+		{"/" + testNewsletter + "?cascade=true", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req, err := adminRequest("DELETE", common.NewslettersEndpoint+tt.path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code. path=%v expected=%v actual=%v", tt.path, tt.code, w.Code)
+		}
+	}
+
+	if subscribers.Count() != 1 {
+		t.Errorf("Unexpected subscribers count %v", subscribers.Count())
+	}
+
+	if ar.isValidNewsletter(testNewsletter) || !ar.isValidNewsletter("other") {
+		t.Errorf("Unexpected newsletters %v", ar.Newsletters.Names())
+	}
+
+	es, _ := events.Events("email0@domain.com")
+	if len(es) != 1 || es[0].Event != common.EventDelete {
+		t.Errorf("Unexpected events %v", es)
+	}
+}
+
+func TestNewsletterRegistryCache(t *testing.T) {
+	store := db.NewNewslettersMapStore()
+	registry := NewNewsletterRegistry(store)
+	registry.AddNames([]string{"a", ""})
+
+	if names := registry.Names(); len(names) != 1 || names[0] != "a" {
+		t.Fatalf("Unexpected newsletters %v", names)
+	}
+
+	store.AddNewsletter(&common.NewsletterConfig{Name: "b"})
+	if _, ok := registry.Get("b"); ok {
+		t.Error("Newsletter is visible before cache expired")
+	}
+
+	registry.TTL = 0
+	if _, ok := registry.Get("b"); !ok {
+		t.Error("Newsletter is not visible after cache expired")
+	}
+
+	registry.TTL = time.Hour
+	store.UpdateNewsletter(&common.NewsletterConfig{Name: "a", DisplayName: "A"})
+	registry.AddNames([]string{"a"})
+	if nc, _ := registry.Get("a"); nc.DisplayName != "A" {
+		t.Errorf("Existing newsletter is overwritten %+v", nc)
+	}
+}
+
+func TestNewsletterRegistrySeed(t *testing.T) {
+	registry := NewNewsletterRegistry(db.NewNewslettersMapStore())
+
+	seeded, err := registry.Seed([]*common.NewsletterConfig{{Name: "a"}, {Name: "b"}})
+	if err != nil || !seeded {
+		t.Fatalf("Empty store is not seeded. err=%v", err)
+	}
+
+	// newsletter deleted via admin API is not added back
+	registry.Store.DeleteNewsletter("b")
+
+	seeded, err = registry.Seed([]*common.NewsletterConfig{{Name: "a"}, {Name: "b"}})
+	if err != nil || seeded {
+		t.Fatalf("Store with newsletters is seeded. err=%v", err)
+	}
+
+	if names := registry.Names(); len(names) != 1 || names[0] != "a" {
+		t.Errorf("Unexpected newsletters %v", names)
+	}
+}
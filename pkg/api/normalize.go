@@ -0,0 +1,30 @@
+package api
+
+import (
+	"github.com/ribtoks/checkmail"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// validEmail returns canonical form of the email if it is valid
+func validEmail(en *common.EmailNormalizer, email string) (string, error) {
+	normalized, err := en.Normalize(email)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkmail.ValidateFormat(normalized); err != nil {
+		return "", err
+	}
+
+	return normalized, nil
+}
+
+// tokenEmail returns canonical form of the email from the token. Tokens
+// signed before normalization can contain addresses in any case.
+func (nr *NewsletterResource) tokenEmail(email string) string {
+	if normalized, err := nr.EmailNormalizer.Normalize(email); err == nil {
+		return normalized
+	}
+
+	return email
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+// DeleteRecordingStore remembers keys of deleted subscribers
+type DeleteRecordingStore struct {
+	*db.SubscribersMapStore
+	deleted []*common.SubscriberKey
+}
+
+func (s *DeleteRecordingStore) DeleteSubscribers(keys []*common.SubscriberKey) error {
+	s.deleted = append(s.deleted, keys...)
+	return s.SubscribersMapStore.DeleteSubscribers(keys)
+}
+
+func TestSubscribeNormalizesEmail(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.EmailNormalizer = &common.EmailNormalizer{LowercaseLocal: true}
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	for _, email := range []string{" Foo@Bar.com ", testEmail} {
+		req, err := subscribeRequest(email, "127.0.0.1:1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusFound {
+			t.Errorf("Unexpected status code. email=%v code=%v", email, w.Code)
+		}
+	}
+
+	if store.Count() != 1 {
+		t.Errorf("Duplicate subscriber is added. count=%v", store.Count())
+	}
+
+	if _, err := store.GetSubscriber(testNewsletter, testEmail); err != nil {
+		t.Errorf("Subscriber is not stored with canonical email")
+	}
+}
+
+func TestUnsubscribeTokenWithOtherCase(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.EmailNormalizer = &common.EmailNormalizer{LowercaseLocal: true}
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.UnsubscribeEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := req.URL.Query()
+	q.Add(common.ParamNewsletter, testNewsletter)
+	q.Add(common.ParamToken, unsubscribeToken("Foo@BAR.com"))
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	time.Sleep(10 * time.Nanosecond)
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusFound {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+
+	s, _ := store.GetSubscriber(testNewsletter, testEmail)
+	if !s.Unsubscribed() {
+		t.Errorf("Subscriber is not unsubscribed")
+	}
+}
+
+func TestUnsubscribeStoredBeforeNormalization(t *testing.T) {
+	const email = "foo+news@outlook.com"
+
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, email, testName, "", nil)
+
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.EmailNormalizer = &common.EmailNormalizer{PlusTags: true}
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.UnsubscribeEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := req.URL.Query()
+	q.Add(common.ParamNewsletter, testNewsletter)
+	q.Add(common.ParamToken, unsubscribeToken(email))
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	time.Sleep(10 * time.Nanosecond)
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusFound {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+
+	s, _ := store.GetSubscriber(testNewsletter, email)
+	if !s.Unsubscribed() {
+		t.Errorf("Subscriber is not unsubscribed")
+	}
+
+	if _, err := store.GetSubscriber(testNewsletter, "foo@outlook.com"); err == nil {
+		t.Errorf("Subscriber is added by unsubscribe")
+	}
+}
+
+func TestPutSubscribersMergesDuplicates(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.EmailNormalizer = &common.EmailNormalizer{LowercaseLocal: true, GmailDots: true}
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	created := time.Now().Add(-24 * time.Hour)
+	subscribers := []*common.Subscriber{
+		{Newsletter: testNewsletter, Email: "Foo.Bar@gmail.com", CreatedAt: common.JSONTime(created), ConfirmedAt: common.JSONTime(created.Add(time.Hour))},
+		{Newsletter: testNewsletter, Email: "foobar@googlemail.com", Name: testName},
+	}
+	data, _ := json.Marshal(subscribers)
+
+	req, err := http.NewRequest("PUT", common.SubscribersEndpoint, bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("any", apiToken)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	if store.Count() != 1 {
+		t.Fatalf("Duplicates are not merged. count=%v", store.Count())
+	}
+
+	s, err := store.GetSubscriber(testNewsletter, "foobar@gmail.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Confirmed() || s.Name != testName {
+		t.Errorf("Unexpected merged subscriber %+v", s)
+	}
+}
+
+func TestDeleteSubscribersNormalizesKeys(t *testing.T) {
+	for _, exact := range []bool{false, true} {
+		srv := http.NewServeMux()
+		store := &DeleteRecordingStore{SubscribersMapStore: db.NewSubscribersMapStore()}
+		ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+		ar.EmailNormalizer = &common.EmailNormalizer{LowercaseLocal: true}
+		ar.Setup(srv)
+
+		keys := []*common.SubscriberKey{
+			{Newsletter: testNewsletter, Email: "Foo@Bar.com"},
+			{Newsletter: testNewsletter, Email: testEmail},
+		}
+		data, _ := json.Marshal(keys)
+
+		endpoint := common.SubscribersEndpoint
+		if exact {
+			endpoint += "?" + common.ParamExact + "=true"
+		}
+
+		req, err := http.NewRequest("DELETE", endpoint, bytes.NewBuffer(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("any", apiToken)
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status code %d", w.Code)
+		}
+
+		expected := []string{testEmail}
+		if exact {
+			expected = []string{"Foo@Bar.com", testEmail}
+		}
+
+		if len(store.deleted) != len(expected) {
+			t.Fatalf("Unexpected deleted keys. exact=%v count=%v", exact, len(store.deleted))
+		}
+
+		for i, email := range expected {
+			if store.deleted[i].Email != email {
+				t.Errorf("Unexpected deleted key. exact=%v expected=%v actual=%v", exact, email, store.deleted[i].Email)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// maxSubscribersLimit keeps the page of subscribers far below the
+// Lambda response size limit. It is also the default page size.
+const maxSubscribersLimit = 1000
+
+// maxEvaluatedSubscribers limits the time of the page with filters that
+// match few subscribers
+const maxEvaluatedSubscribers = 10 * maxSubscribersLimit
+
+var (
+	errInvalidLimit  = errors.New("The limit parameter is invalid")
+	errInvalidStatus = errors.New("The status parameter is invalid")
+	errInvalidTime   = errors.New("The created_after and created_before parameters must be RFC 3339 times")
+)
+
+// subscribersQuery parses paging and filter parameters of GET /subscribers
+func subscribersQuery(params url.Values) (*common.SubscribersQuery, error) {
+	query := &common.SubscribersQuery{
+		Newsletter:   params.Get(common.ParamNewsletter),
+		Status:       params.Get(common.ParamStatus),
+		Cursor:       params.Get(common.ParamCursor),
+		MaxEvaluated: maxEvaluatedSubscribers,
+	}
+
+	// all subscribers are returned in one response unless paging is
+	// requested, as it was before paging was added
+	if query.Cursor != "" {
+		query.Limit = maxSubscribersLimit
+	}
+
+	if limit := params.Get(common.ParamLimit); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 || l > maxSubscribersLimit {
+			return nil, errInvalidLimit
+		}
+		query.Limit = l
+	}
+
+	if !common.ValidStatus(query.Status) {
+		return nil, errInvalidStatus
+	}
+
+	var err error
+	if query.CreatedAfter, err = parseTimeParam(params, common.ParamCreatedAfter); err != nil {
+		return nil, err
+	}
+
+	if query.CreatedBefore, err = parseTimeParam(params, common.ParamCreatedBefore); err != nil {
+		return nil, err
+	}
+
+	if _, err := query.StartKey(); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+// parseTimeParam returns zero time if the parameter is not set
+func parseTimeParam(params url.Values, name string) (time.Time, error) {
+	value := params.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errInvalidTime
+	}
+
+	return t, nil
+}
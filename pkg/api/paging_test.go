@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func TestGetSubscribersPages(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	for i := 0; i < 5; i++ {
+		store.AddSubscriber(testNewsletter, fmt.Sprintf("email%v@domain.com", i), testName, "", nil)
+	}
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	emails := make(map[string]bool)
+	cursor := ""
+
+	for pages := 1; ; pages++ {
+		params := map[string]string{common.ParamNewsletter: testNewsletter, common.ParamLimit: "2"}
+		if cursor != "" {
+			params[common.ParamCursor] = cursor
+		}
+
+		req, err := adminRequest("GET", common.SubscribersEndpoint, "", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status code %d", w.Code)
+		}
+
+		ss := make([]*common.Subscriber, 0)
+		if err := json.Unmarshal(w.Body.Bytes(), &ss); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ss) > 2 {
+			t.Errorf("Page is bigger than the limit. count=%v", len(ss))
+		}
+
+		for _, s := range ss {
+			emails[s.Email] = true
+		}
+
+		cursor = w.Header().Get(common.HeaderNextCursor)
+		if cursor == "" {
+			if pages != 3 {
+				t.Errorf("Unexpected number of pages %v", pages)
+			}
+			break
+		}
+	}
+
+	if len(emails) != 5 {
+		t.Errorf("Unexpected number of subscribers %v", len(emails))
+	}
+}
+
+func TestGetSubscribersWithoutPaging(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	for i := 0; i < maxSubscribersLimit+1; i++ {
+		store.AddSubscriber(testNewsletter, fmt.Sprintf("email%v@domain.com", i), testName, "", nil)
+	}
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	req, err := adminRequest("GET", common.SubscribersEndpoint, "", map[string]string{common.ParamNewsletter: testNewsletter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	ss := make([]*common.Subscriber, 0)
+	if err := json.Unmarshal(w.Body.Bytes(), &ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ss) != maxSubscribersLimit+1 || w.Header().Get(common.HeaderNextCursor) != "" {
+		t.Errorf("Not all subscribers are returned. count=%v", len(ss))
+	}
+}
+
+func TestGetSubscribersByStatus(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "", nil)
+	time.Sleep(10 * time.Nanosecond)
+	store.ConfirmSubscriber(testNewsletter, "email1@domain.com")
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	req, err := adminRequest("GET", common.SubscribersEndpoint, "", map[string]string{
+		common.ParamNewsletter: testNewsletter,
+		common.ParamStatus:     common.StatusPending,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	ss := make([]*common.Subscriber, 0)
+	if err := json.Unmarshal(w.Body.Bytes(), &ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ss) != 1 || ss[0].Email != "email2@domain.com" {
+		t.Errorf("Unexpected pending subscribers %v", ss)
+	}
+}
+
+func TestGetSubscribersInvalidQuery(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	tests := []map[string]string{
+		{common.ParamLimit: "0"},
+		{common.ParamLimit: "abc"},
+		{common.ParamLimit: fmt.Sprint(maxSubscribersLimit + 1)},
+		{common.ParamStatus: "bounced"},
+		{common.ParamCreatedAfter: "yesterday"},
+		{common.ParamCreatedBefore: "2020-01-01"},
+		{common.ParamCursor: "invalid"},
+		{common.ParamCursor: common.EncodeCursor(&common.SubscriberKey{Newsletter: "other", Email: testEmail})},
+	}
+
+	for _, params := range tests {
+		params[common.ParamNewsletter] = testNewsletter
+
+		req, err := adminRequest("GET", common.SubscribersEndpoint, "", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code. params=%v code=%v", params, w.Code)
+		}
+	}
+}
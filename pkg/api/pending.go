@@ -0,0 +1,168 @@
+package api
+
+import (
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// actions of the pending subscribers job
+const (
+	ActionRemind = "remind"
+	ActionPurge  = "purge"
+)
+
+// PendingAction is a reminder or a deletion of the unconfirmed subscriber
+type PendingAction struct {
+	Action     string          `json:"action"`
+	Newsletter string          `json:"newsletter"`
+	Email      string          `json:"email"`
+	CreatedAt  common.JSONTime `json:"created_at"`
+}
+
+func newPendingAction(action string, s *common.Subscriber) *PendingAction {
+	return &PendingAction{
+		Action:     action,
+		Newsletter: s.Newsletter,
+		Email:      s.Email,
+		CreatedAt:  s.CreatedAt,
+	}
+}
+
+// PendingReport lists what the pending subscribers job did (or would do
+// in dry run)
+type PendingReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Actions []*PendingAction `json:"actions"`
+}
+
+// Count returns number of actions of the given type
+func (pr *PendingReport) Count(action string) int {
+	count := 0
+
+	for _, a := range pr.Actions {
+		if a.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
+
+// ProcessPending sends one confirmation reminder to subscribers that are
+// unconfirmed for longer than remindAfter and deletes the ones unconfirmed
+// for longer than purgeAfter. Zero duration disables the step. Nothing is
+// changed and no emails are sent in dry run.
+func (nr *NewsletterResource) ProcessPending(remindAfter, purgeAfter time.Duration, dryRun bool) (*PendingReport, error) {
+	report := &PendingReport{
+		DryRun:  dryRun,
+		Actions: make([]*PendingAction, 0),
+	}
+
+	for _, newsletter := range nr.Newsletters.Names() {
+		actions, err := nr.processPending(nr.config(newsletter), remindAfter, purgeAfter, dryRun)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Actions = append(report.Actions, actions...)
+	}
+
+	// the job exits right after the report, so events are delivered now
+	if nr.Publisher != nil {
+		nr.Publisher.Flush()
+	}
+
+	nr.Logger.Info("Processed pending subscribers", "reminded", report.Count(ActionRemind), "purged", report.Count(ActionPurge), "dry_run", dryRun)
+
+	return report, nil
+}
+
+func (nr *NewsletterResource) processPending(nc *common.NewsletterConfig, remindAfter, purgeAfter time.Duration, dryRun bool) ([]*PendingAction, error) {
+	subscribers, err := nr.Subscribers.Subscribers(nc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	actions := make([]*PendingAction, 0)
+	keys := make([]*common.SubscriberKey, 0)
+	events := make([]*common.SubscriberEvent, 0)
+
+	for _, s := range subscribers {
+		if s.Confirmed() || s.Unsubscribed() {
+			continue
+		}
+
+		age := now.Sub(s.CreatedAt.Time())
+
+		switch {
+		case purgeAfter > 0 && age > purgeAfter:
+			{
+				actions = append(actions, newPendingAction(ActionPurge, s))
+				keys = append(keys, &common.SubscriberKey{Newsletter: s.Newsletter, Email: s.Email})
+			}
+		case remindAfter > 0 && age > remindAfter && !s.Reminded() && nc.DoubleOptIn():
+			{
+				// only reminded addresses are looked up to not read all notifications
+				suppressed, err := nr.isSuppressed(s.Email)
+				if err != nil {
+					return nil, err
+				}
+
+				if suppressed {
+					nr.Logger.Warn("Skipping reminder to suppressed email", "email", s.Email, "newsletter", nc.Name)
+					continue
+				}
+
+				if !dryRun {
+					if err := nr.Mailer.SendConfirmation(nc, s.Email, s.Name, s.Locale); err != nil {
+						nr.Logger.Error("Failed to send reminder", "email", s.Email, "newsletter", nc.Name, "err", err)
+						continue
+					}
+
+					// only the reminder time is set, so that the subscriber
+					// confirmed after the email was sent stays confirmed
+					err := nr.Subscribers.MarkReminded(s.Newsletter, s.Email, common.JsonTimeNow())
+					if err == common.ErrSubscriberNotPending {
+						nr.Logger.Info("Subscriber changed after reminder was sent", "email", s.Email, "newsletter", nc.Name)
+					} else if err != nil {
+						// the reminder is already sent so the error only leads to a second reminder next time
+						nr.Logger.Error("Failed to mark reminded subscriber", "email", s.Email, "newsletter", nc.Name, "err", err)
+					}
+
+					events = append(events, pendingEvent(common.EventConfirmationSent, nc, s.Email, common.SourceReminder))
+				}
+
+				actions = append(actions, newPendingAction(ActionRemind, s))
+			}
+		}
+	}
+
+	if dryRun {
+		return actions, nil
+	}
+
+	if len(keys) > 0 {
+		if err := nr.Subscribers.DeleteSubscribers(keys); err != nil {
+			addEvents(nr.Logger, nr.Events, nr.Publisher, events)
+			return nil, err
+		}
+
+		for _, k := range keys {
+			events = append(events, pendingEvent(common.EventDelete, nc, k.Email, common.SourcePurge))
+		}
+	}
+
+	addEvents(nr.Logger, nr.Events, nr.Publisher, events)
+
+	return actions, nil
+}
+
+func pendingEvent(event string, nc *common.NewsletterConfig, email, source string) *common.SubscriberEvent {
+	e := common.NewSubscriberEvent(event, nc.Name, email)
+	e.ConsentVersion = nc.ConsentVersion
+	e.Source = source
+
+	return e
+}
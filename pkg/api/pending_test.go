@@ -0,0 +1,183 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+const day = 24 * time.Hour
+
+func addPendingSubscriber(store common.SubscribersStore, email string, age time.Duration) {
+	store.AddSubscriber(testNewsletter, email, testName, "", nil)
+	s, _ := store.GetSubscriber(testNewsletter, email)
+	s.CreatedAt = common.JSONTime(time.Now().Add(-age))
+}
+
+func newPendingResource(store common.SubscribersStore, mailer common.Mailer) *NewsletterResource {
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.Mailer = mailer
+	nr.Events = db.NewEventsMapStore()
+	nr.AddNewsletters([]string{testNewsletter})
+	return nr
+}
+
+func TestProcessPendingRemindsOnce(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	addPendingSubscriber(store, "old@email.com", 3*day)
+	addPendingSubscriber(store, "new@email.com", 1*time.Hour)
+
+	mailer := &CountingMailer{}
+	nr := newPendingResource(store, mailer)
+
+	for i := 0; i < 2; i++ {
+		report, err := nr.ProcessPending(2*day, 7*day, false /*dry run*/)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := 1 - i
+		if report.Count(ActionRemind) != expected {
+			t.Errorf("Unexpected reminders count. run=%v count=%v expected=%v", i, report.Count(ActionRemind), expected)
+		}
+	}
+
+	if mailer.count != 1 {
+		t.Errorf("Unexpected emails count %v", mailer.count)
+	}
+
+	s, _ := store.GetSubscriber(testNewsletter, "old@email.com")
+	if !s.Reminded() {
+		t.Errorf("Subscriber is not marked as reminded")
+	}
+
+	events, _ := nr.Events.Events("old@email.com")
+	if len(events) != 1 || events[0].Event != common.EventConfirmationSent || events[0].Source != common.SourceReminder {
+		t.Errorf("Unexpected events %v", events)
+	}
+}
+
+// confirmingMailer confirms the subscriber while the reminder is being sent
+type confirmingMailer struct {
+	store common.SubscribersStore
+}
+
+func (m *confirmingMailer) SendConfirmation(newsletter *common.NewsletterConfig, email, name, locale string) error {
+	s, err := m.store.GetSubscriber(newsletter.Name, email)
+	if err != nil {
+		return err
+	}
+
+	// store a new record like a different process would do
+	confirmed := *s
+	confirmed.ConfirmedAt = common.JsonTimeNow()
+	return m.store.AddSubscribers([]*common.Subscriber{&confirmed})
+}
+
+func TestProcessPendingKeepsConfirmation(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	addPendingSubscriber(store, "old@email.com", 3*day)
+
+	nr := newPendingResource(store, &confirmingMailer{store: store})
+
+	report, err := nr.ProcessPending(2*day, 7*day, false /*dry run*/)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Count(ActionRemind) != 1 {
+		t.Errorf("Unexpected report %v", report.Actions)
+	}
+
+	s, _ := store.GetSubscriber(testNewsletter, "old@email.com")
+	if !s.Confirmed() {
+		t.Errorf("Confirmation is overwritten by the reminder")
+	}
+}
+
+func TestProcessPendingPurges(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	addPendingSubscriber(store, "stale@email.com", 10*day)
+	addPendingSubscriber(store, "confirmed@email.com", 10*day)
+	store.ConfirmSubscriber(testNewsletter, "confirmed@email.com")
+
+	mailer := &CountingMailer{}
+	nr := newPendingResource(store, mailer)
+
+	report, err := nr.ProcessPending(2*day, 7*day, false /*dry run*/)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Count(ActionPurge) != 1 || report.Actions[0].Email != "stale@email.com" {
+		t.Errorf("Unexpected report %v", report.Actions)
+	}
+
+	if mailer.count != 0 {
+		t.Errorf("Reminder was sent to purged subscriber")
+	}
+
+	if _, err := store.GetSubscriber(testNewsletter, "stale@email.com"); err == nil {
+		t.Errorf("Stale subscriber was not deleted")
+	}
+
+	if _, err := store.GetSubscriber(testNewsletter, "confirmed@email.com"); err != nil {
+		t.Errorf("Confirmed subscriber was deleted")
+	}
+
+	events, _ := nr.Events.Events("stale@email.com")
+	if len(events) != 1 || events[0].Event != common.EventDelete || events[0].Source != common.SourcePurge {
+		t.Errorf("Unexpected events %v", events)
+	}
+}
+
+func TestProcessPendingDryRun(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	addPendingSubscriber(store, "old@email.com", 3*day)
+	addPendingSubscriber(store, "stale@email.com", 10*day)
+
+	mailer := &CountingMailer{}
+	nr := newPendingResource(store, mailer)
+
+	report, err := nr.ProcessPending(2*day, 7*day, true /*dry run*/)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.DryRun || report.Count(ActionRemind) != 1 || report.Count(ActionPurge) != 1 {
+		t.Errorf("Unexpected report %v", report.Actions)
+	}
+
+	if mailer.count != 0 {
+		t.Errorf("Reminder was sent in dry run")
+	}
+
+	if store.Count() != 2 {
+		t.Errorf("Subscribers were deleted in dry run")
+	}
+
+	s, _ := store.GetSubscriber(testNewsletter, "old@email.com")
+	if s.Reminded() {
+		t.Errorf("Subscriber was marked as reminded in dry run")
+	}
+}
+
+func TestProcessPendingSkipsSuppressed(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	addPendingSubscriber(store, testEmail, 3*day)
+
+	mailer := &CountingMailer{}
+	nr := newPendingResource(store, mailer)
+	nr.Notifications.AddBounce(testEmail, "from@email.com", false /*is transient*/)
+
+	report, err := nr.ProcessPending(2*day, 0 /*purge after*/, false /*dry run*/)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Actions) != 0 || mailer.count != 0 {
+		t.Errorf("Reminder was sent to suppressed email")
+	}
+}
@@ -0,0 +1,366 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// statuses of the subscriber in the newsletter
+const (
+	StatusSubscribed   = "subscribed"
+	StatusPending      = "pending"
+	StatusUnsubscribed = "unsubscribed"
+	StatusNone         = "none"
+)
+
+// preferencesHTML is a template of the preference center with a checkbox
+// per newsletter. Hidden unsubscribe input goes along with every checkbox
+// so that unchecked newsletters are unsubscribed.
+const preferencesHTML = `<!doctype html>
+<html>
+  <head>
+    <meta name="viewport" content="width=device-width" />
+    <meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
+    <meta name="robots" content="noindex, nofollow" />
+    <title>Subscription preferences</title>
+` + pageStyle + `  </head>
+  <body>
+    <div class="container">
+      <h1>Subscription preferences</h1>
+      {{if .Saved}}<p><strong>Your preferences have been saved.</strong></p>{{end}}
+      <p>{{.Preferences.Email}}</p>
+      <form method="POST" action="">
+        <input type="hidden" name="token" value="{{.Token}}" />
+        <p>
+          <label>Name <input type="text" name="name" value="{{.Preferences.Name}}" /></label>
+        </p>
+        {{range .Preferences.Newsletters}}
+        <p>
+          <input type="hidden" name="unsubscribe" value="{{.Newsletter}}" />
+          <label>
+            <input type="checkbox" name="subscribe" value="{{.Newsletter}}"{{if .Active}} checked{{end}} />
+            {{.DisplayName}}
+          </label>
+        </p>
+        {{end}}
+        <button type="submit">Save</button>
+      </form>
+      <p><a href="data?token={{.Token}}">Download my data</a></p>
+      <form method="POST" action="data/erase">
+        <input type="hidden" name="token" value="{{.Token}}" />
+        <button type="submit">Delete my data</button>
+      </form>
+    </div>
+  </body>
+</html>
+`
+
+var preferencesTemplate = template.Must(template.New("Preferences").Parse(preferencesHTML))
+
+// NewsletterStatus is the status of the reader in one newsletter
+type NewsletterStatus struct {
+	Newsletter  string `json:"newsletter"`
+	DisplayName string `json:"display_name"`
+	Status      string `json:"status"`
+}
+
+// Active checks if reader receives (or is about to receive) the newsletter
+func (ns *NewsletterStatus) Active() bool {
+	return isActive(ns.Status)
+}
+
+func isActive(status string) bool {
+	return status == StatusSubscribed || status == StatusPending
+}
+
+// Preferences is a body of /preferences endpoint
+type Preferences struct {
+	Email       string              `json:"email"`
+	Name        string              `json:"name"`
+	Newsletters []*NewsletterStatus `json:"newsletters"`
+}
+
+// preferencesPage is the data for preferencesTemplate
+type preferencesPage struct {
+	Preferences *Preferences
+	Token       string
+	Saved       bool
+}
+
+func subscriberStatus(s *common.Subscriber) string {
+	switch {
+	case s == nil:
+		return StatusNone
+	case s.Unsubscribed():
+		return StatusUnsubscribed
+	case s.Confirmed():
+		return StatusSubscribed
+	default:
+		return StatusPending
+	}
+}
+
+func (nr *NewsletterResource) servePreferences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		{
+			nr.getPreferences(w, r)
+		}
+	case "POST":
+		{
+			r.Body = http.MaxBytesReader(w, r.Body, maxSubscribeBodySize)
+			nr.postPreferences(w, r)
+		}
+	default:
+		{
+			nr.Logger.Warn("Unsupported method for preferences", "method", r.Method)
+			fail(w, r, http.StatusBadRequest, OutcomeBadRequest, http.StatusText(http.StatusBadRequest))
+			return
+		}
+	}
+}
+
+// preferencesEmail checks preferences token that is issued for the
+// address and not for a specific newsletter. Legacy tokens are not
+// accepted since they are not bound to any purpose.
+func (nr *NewsletterResource) preferencesEmail(w http.ResponseWriter, r *http.Request) (string, bool) {
+	policy := nr.TokenPolicy
+	policy.LegacyUntil = time.Time{}
+
+	token := r.FormValue(common.ParamToken)
+
+	email, err := policy.Unsign(nr.Secret, token, common.PurposePreferences, "")
+	if err != nil {
+		nr.Logger.Warn("Failed to unsign token", "token", token, "purpose", common.PurposePreferences, "err", err)
+		fail(w, r, http.StatusBadRequest, OutcomeInvalidToken, "Invalid preferences token")
+
+		return "", false
+	}
+
+	return nr.tokenEmail(email), true
+}
+
+// subscriptions returns existing records of the address in all newsletters
+func (nr *NewsletterResource) subscriptions(email string) map[string]*common.Subscriber {
+	subscriptions := make(map[string]*common.Subscriber)
+
+	for _, newsletter := range nr.Newsletters.Names() {
+		if s, err := nr.Subscribers.GetSubscriber(newsletter, email); err == nil {
+			subscriptions[newsletter] = s
+		}
+	}
+
+	return subscriptions
+}
+
+func (nr *NewsletterResource) preferences(email string) *Preferences {
+	subscriptions := nr.subscriptions(email)
+	p := &Preferences{
+		Email:       email,
+		Newsletters: make([]*NewsletterStatus, 0),
+	}
+
+	for _, newsletter := range nr.Newsletters.Names() {
+		s := subscriptions[newsletter]
+		// archived newsletters are listed only to their readers
+		if s == nil && !nr.isActiveNewsletter(newsletter) {
+			continue
+		}
+
+		if s != nil && p.Name == "" {
+			p.Name = s.Name
+		}
+
+		p.Newsletters = append(p.Newsletters, &NewsletterStatus{
+			Newsletter:  newsletter,
+			DisplayName: nr.config(newsletter).Title(),
+			Status:      subscriberStatus(s),
+		})
+	}
+
+	return p
+}
+
+func (nr *NewsletterResource) renderPreferences(w http.ResponseWriter, r *http.Request, email string, saved bool) {
+	p := nr.preferences(email)
+
+	if wantsJSON(r) {
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, p)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	err := preferencesTemplate.Execute(w, &preferencesPage{
+		Preferences: p,
+		Token:       r.FormValue(common.ParamToken),
+		Saved:       saved,
+	})
+	if err != nil {
+		nr.Logger.Error("Failed to render preferences page", "err", err)
+	}
+}
+
+func (nr *NewsletterResource) getPreferences(w http.ResponseWriter, r *http.Request) {
+	email, ok := nr.preferencesEmail(w, r)
+	if !ok {
+		return
+	}
+
+	nr.renderPreferences(w, r, email, false)
+}
+
+// postPreferences subscribes to newsletters from subscribe parameters,
+// unsubscribes from newsletters from unsubscribe parameters and updates
+// the name if it is present. Newsletter in both lists is subscribed.
+func (nr *NewsletterResource) postPreferences(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		nr.Logger.Warn("Failed to parse form", "err", err)
+	}
+
+	email, ok := nr.preferencesEmail(w, r)
+	if !ok {
+		return
+	}
+
+	subscribe := make(map[string]bool)
+	for _, n := range r.PostForm[common.ParamSubscribe] {
+		subscribe[n] = true
+	}
+
+	unsubscribe := make(map[string]bool)
+	for _, n := range r.PostForm[common.ParamUnsubscribe] {
+		if !subscribe[n] {
+			unsubscribe[n] = true
+		}
+	}
+
+	for n := range subscribe {
+		if !nr.isActiveNewsletter(n) {
+			nr.Logger.Warn("Invalid newsletter", "newsletter", n)
+			fail(w, r, http.StatusBadRequest, OutcomeUnknownNewsletter, "Invalid newsletter param")
+
+			return
+		}
+	}
+
+	if err := nr.updatePreferences(r, email, subscribe, unsubscribe); err != nil {
+		nr.Logger.Error("Failed to update preferences", "email", email, "err", err)
+		fail(w, r, http.StatusInternalServerError, OutcomeInternalError, "Error updating preferences")
+
+		return
+	}
+
+	nr.renderPreferences(w, r, email, true)
+}
+
+func (nr *NewsletterResource) updatePreferences(r *http.Request, email string, subscribe, unsubscribe map[string]bool) error {
+	subscriptions := nr.subscriptions(email)
+
+	_, nameChanged := r.PostForm[common.ParamName]
+	name := strings.TrimSpace(r.PostFormValue(common.ParamName))
+
+	if nameChanged {
+		// only the name is written so that concurrent changes of the
+		// subscription (e.g. confirmation) are not overwritten
+		update := &common.SubscriberUpdate{Name: &name}
+		renamed := 0
+
+		for _, s := range subscriptions {
+			if s.Name == name {
+				continue
+			}
+
+			_, err := nr.Subscribers.UpdateSubscriber(s.Newsletter, s.Email, update)
+			if err == common.ErrSubscriberNotFound {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			s.Name = name
+			renamed++
+		}
+
+		if renamed > 0 {
+			nr.Logger.Info("Updated name", "email", email, "name", name)
+		}
+	}
+
+	if len(subscribe) > 0 {
+		suppressed, err := nr.isSuppressed(email)
+		if err != nil {
+			return err
+		}
+
+		if suppressed {
+			nr.Logger.Warn("Refused subscription of suppressed email", "email", email)
+			subscribe = nil
+		}
+	}
+
+	for newsletter := range subscribe {
+		s := subscriptions[newsletter]
+		status := subscriberStatus(s)
+
+		if status == StatusSubscribed {
+			continue
+		}
+
+		if status != StatusPending {
+			n := name
+			locale := requestLocale(r)
+			var attributes map[string]string
+			if s != nil {
+				attributes = s.Attributes
+				if s.Locale != "" {
+					locale = s.Locale
+				}
+				if !nameChanged {
+					n = s.Name
+				}
+			}
+
+			if err := nr.Subscribers.AddSubscriber(newsletter, email, n, locale, attributes); err != nil {
+				return err
+			}
+
+			nr.addEvent(r, common.EventSubscribe, newsletter, email, common.SourcePreferences)
+		}
+
+		// token proves the ownership of the address so confirmation email is not needed
+		if err := nr.Subscribers.ConfirmSubscriber(newsletter, email); err != nil {
+			return err
+		}
+
+		nr.addEvent(r, common.EventConfirm, newsletter, email, common.SourcePreferences)
+
+		nr.Logger.Info("Subscribed from preferences", "email", email, "newsletter", newsletter)
+	}
+
+	for newsletter := range unsubscribe {
+		if !isActive(subscriberStatus(subscriptions[newsletter])) {
+			continue
+		}
+
+		if err := nr.Subscribers.RemoveSubscriber(newsletter, email); err != nil {
+			return err
+		}
+
+		nr.addEvent(r, common.EventUnsubscribe, newsletter, email, common.SourcePreferences)
+
+		nr.Logger.Info("Unsubscribed from preferences", "email", email, "newsletter", newsletter)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+const otherNewsletter = "othernewsletter"
+
+func preferencesToken(email string) string {
+	return common.SignToken(secret, common.NewToken(common.PurposePreferences, "", email))
+}
+
+func preferencesResource(store common.SubscribersStore) (*http.ServeMux, *NewsletterResource) {
+	srv := http.NewServeMux()
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.AddNewsletters([]string{testNewsletter, otherNewsletter})
+	nr.Setup(srv)
+	return srv, nr
+}
+
+func decodePreferences(t *testing.T, resp *http.Response) map[string]string {
+	p := &Preferences{}
+	if err := json.NewDecoder(resp.Body).Decode(p); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := make(map[string]string)
+	for _, ns := range p.Newsletters {
+		statuses[ns.Newsletter] = ns.Status
+	}
+
+	return statuses
+}
+
+func TestGetPreferences(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+	srv, _ := preferencesResource(store)
+
+	req, err := http.NewRequest("GET", common.PreferencesEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := req.URL.Query()
+	q.Add(common.ParamToken, preferencesToken(testEmail))
+	q.Add(common.ParamFormat, common.FormatJSON)
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	statuses := decodePreferences(t, resp)
+	if statuses[testNewsletter] != StatusPending || statuses[otherNewsletter] != StatusNone {
+		t.Errorf("Unexpected statuses: %v", statuses)
+	}
+}
+
+func TestGetPreferencesHTML(t *testing.T) {
+	srv, _ := preferencesResource(db.NewSubscribersMapStore())
+
+	req, err := http.NewRequest("GET", common.PreferencesEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := req.URL.Query()
+	q.Add(common.ParamToken, preferencesToken(testEmail))
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, testNewsletter) || !strings.Contains(body, otherNewsletter) {
+		t.Errorf("Newsletters are missing in the page")
+	}
+}
+
+func TestPreferencesWrongToken(t *testing.T) {
+	srv, nr := preferencesResource(db.NewSubscribersMapStore())
+	// legacy tokens are not accepted even before the deadline
+	nr.TokenPolicy.LegacyUntil = time.Now().Add(time.Hour)
+
+	tokens := []string{
+		"",
+		unsubscribeToken(testEmail),
+		common.SignToken(secret, common.NewToken(common.PurposePreferences, testNewsletter, testEmail)),
+		common.Sign(secret, testEmail),
+	}
+
+	for _, token := range tokens {
+		req, err := http.NewRequest("GET", common.PreferencesEndpoint, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q := req.URL.Query()
+		q.Add(common.ParamToken, token)
+		req.URL.RawQuery = q.Encode()
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		resp := w.Result()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Unexpected status code %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestPostPreferences(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+	store.ConfirmSubscriber(testNewsletter, testEmail)
+	srv, _ := preferencesResource(store)
+
+	data := url.Values{}
+	data.Set(common.ParamToken, preferencesToken(testEmail))
+	data.Set(common.ParamName, "New Name")
+	data.Add(common.ParamUnsubscribe, testNewsletter)
+	data.Add(common.ParamUnsubscribe, otherNewsletter)
+	data.Add(common.ParamSubscribe, otherNewsletter)
+
+	req, err := http.NewRequest("POST", common.PreferencesEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	statuses := decodePreferences(t, resp)
+	if statuses[testNewsletter] != StatusUnsubscribed || statuses[otherNewsletter] != StatusSubscribed {
+		t.Errorf("Unexpected statuses: %v", statuses)
+	}
+
+	for _, n := range []string{testNewsletter, otherNewsletter} {
+		s, err := store.GetSubscriber(n, testEmail)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.Name != "New Name" {
+			t.Errorf("Name was not updated. newsletter=%v name=%v", n, s.Name)
+		}
+	}
+}
+
+func TestPostPreferencesUnknownNewsletter(t *testing.T) {
+	store := db.NewSubscribersMapStore()
+	srv, _ := preferencesResource(store)
+
+	data := url.Values{}
+	data.Set(common.ParamToken, preferencesToken(testEmail))
+	data.Add(common.ParamSubscribe, "unknown")
+
+	req, err := http.NewRequest("POST", common.PreferencesEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	if store.Count() != 0 {
+		t.Errorf("Wrong number of items in the store: %v", store.Count())
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// RateLimiter limits the number of actions per key within a time window
+type RateLimiter interface {
+	Allow(key string) (bool, error)
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// MemoryRateLimiter is a fixed window RateLimiter that keeps counters
+// in memory of the current process
+type MemoryRateLimiter struct {
+	Limit     int
+	Window    time.Duration
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	nextSweep time.Time
+}
+
+var _ RateLimiter = (*MemoryRateLimiter)(nil)
+
+// NewMemoryRateLimiter allows limit actions per key during window
+func NewMemoryRateLimiter(limit int, window time.Duration) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		Limit:   limit,
+		Window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+func (l *MemoryRateLimiter) Allow(key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.Window {
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+
+	w.count++
+
+	return w.count <= l.Limit, nil
+}
+
+// sweep removes expired windows so that memory does not grow forever
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.Window {
+			delete(l.windows, key)
+		}
+	}
+
+	l.nextSweep = now.Add(l.Window)
+}
+
+// StoreRateLimiter is a fixed window RateLimiter that keeps counters
+// in the store shared between all instances of the API
+type StoreRateLimiter struct {
+	Limit  int
+	Window time.Duration
+	Store  common.RateLimitStore
+}
+
+var _ RateLimiter = (*StoreRateLimiter)(nil)
+
+func (l *StoreRateLimiter) Allow(key string) (bool, error) {
+	start := time.Now().Truncate(l.Window)
+	windowKey := key + "#" + start.UTC().Format(time.RFC3339)
+
+	count, err := l.Store.Increment(windowKey, start.Add(l.Window))
+	if err != nil {
+		return true, err
+	}
+
+	return count <= int64(l.Limit), nil
+}
+
+// clientIP returns address of the client that sent the request: source IP
+// of API Gateway request or the address of the connection. Headers like
+// X-Forwarded-For are ignored because any client can set them. It is
+// empty if the address is unknown.
+func clientIP(r *http.Request) string {
+	if ctx, ok := core.GetAPIGatewayContextFromContext(r.Context()); ok && ctx.Identity.SourceIP != "" {
+		return ctx.Identity.SourceIP
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	l := NewMemoryRateLimiter(2, 1*time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("key"); !ok {
+			t.Errorf("Action is not allowed. attempt=%v", i)
+		}
+	}
+
+	if ok, _ := l.Allow("key"); ok {
+		t.Errorf("Action is allowed over the limit")
+	}
+
+	if ok, _ := l.Allow("other"); !ok {
+		t.Errorf("Action is not allowed for another key")
+	}
+}
+
+func TestMemoryRateLimiterWindow(t *testing.T) {
+	l := NewMemoryRateLimiter(1, 10*time.Millisecond)
+
+	l.Allow("key")
+	if ok, _ := l.Allow("key"); ok {
+		t.Errorf("Action is allowed over the limit")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if ok, _ := l.Allow("key"); !ok {
+		t.Errorf("Action is not allowed in the new window")
+	}
+}
+
+func TestStoreRateLimiter(t *testing.T) {
+	l := &StoreRateLimiter{
+		Limit:  1,
+		Window: 1 * time.Hour,
+		Store:  db.NewRateLimitsMapStore(),
+	}
+
+	if ok, _ := l.Allow("key"); !ok {
+		t.Errorf("Action is not allowed")
+	}
+
+	if ok, _ := l.Allow("key"); ok {
+		t.Errorf("Action is allowed over the limit")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		xff        string
+		ip         string
+	}{
+		{"1.2.3.4:5678", "", "1.2.3.4"},
+		{"1.2.3.4", "5.6.7.8", "1.2.3.4"},
+		{"", "5.6.7.8, 9.10.11.12", ""},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.xff != "" {
+			r.Header.Set("X-Forwarded-For", tt.xff)
+		}
+
+		if ip := clientIP(r); ip != tt.ip {
+			t.Errorf("Unexpected ip. actual=%v expected=%v", ip, tt.ip)
+		}
+	}
+}
+
+func TestClientIPFromAPIGateway(t *testing.T) {
+	event := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/subscribe",
+		Headers:    map[string]string{"X-Forwarded-For": "5.6.7.8"},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Identity: events.APIGatewayRequestIdentity{SourceIP: "1.2.3.4"},
+		},
+	}
+
+	r, err := (&core.RequestAccessor{}).EventToRequestWithContext(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ip := clientIP(r); ip != "1.2.3.4" {
+		t.Errorf("Unexpected ip %v", ip)
+	}
+}
+
+func TestRateLimitsMapStoreExpires(t *testing.T) {
+	store := db.NewRateLimitsMapStore()
+
+	for i := 0; i < 2; i++ {
+		store.Increment("key", time.Now().Add(-1*time.Second))
+	}
+
+	if count, _ := store.Increment("key", time.Now().Add(1*time.Hour)); count != 1 {
+		t.Errorf("Expired counter is not reset. count=%v", count)
+	}
+
+	if store.Count() != 1 {
+		t.Errorf("Unexpected number of counters %v", store.Count())
+	}
+}
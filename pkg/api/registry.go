@@ -0,0 +1,136 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// DefaultNewslettersTTL is how long newsletter configs are kept in memory
+const DefaultNewslettersTTL = 1 * time.Minute
+
+// NewsletterRegistry caches newsletter configs of the store. Changes made
+// via admin API on other instances become visible after at most TTL.
+type NewsletterRegistry struct {
+	Store  common.NewslettersStore
+	TTL    time.Duration
+	Logger *common.Logger
+
+	mu       sync.Mutex
+	configs  map[string]*common.NewsletterConfig
+	loadedAt time.Time
+}
+
+// NewNewsletterRegistry returns registry with default TTL
+func NewNewsletterRegistry(store common.NewslettersStore) *NewsletterRegistry {
+	return &NewsletterRegistry{
+		Store: store,
+		TTL:   DefaultNewslettersTTL,
+	}
+}
+
+// load returns cached configs and reloads them when they expire. Stale
+// configs are used until the next attempt if the store fails.
+func (reg *NewsletterRegistry) load() map[string]*common.NewsletterConfig {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	now := time.Now()
+	if reg.configs != nil && now.Sub(reg.loadedAt) < reg.TTL {
+		return reg.configs
+	}
+
+	newsletters, err := reg.Store.Newsletters()
+	if err != nil {
+		reg.Logger.Error("Failed to load newsletters", "err", err)
+		if reg.configs == nil {
+			return make(map[string]*common.NewsletterConfig)
+		}
+
+		reg.loadedAt = now
+		return reg.configs
+	}
+
+	configs := make(map[string]*common.NewsletterConfig, len(newsletters))
+	for _, nc := range newsletters {
+		configs[nc.Name] = nc
+	}
+
+	reg.configs = configs
+	reg.loadedAt = now
+
+	return configs
+}
+
+// Invalidate makes the next lookup reload configs from the store
+func (reg *NewsletterRegistry) Invalidate() {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.configs = nil
+}
+
+// Get returns config of the newsletter. It must not be modified.
+func (reg *NewsletterRegistry) Get(name string) (*common.NewsletterConfig, bool) {
+	nc, ok := reg.load()[name]
+	return nc, ok
+}
+
+// Names returns sorted names of all newsletters
+func (reg *NewsletterRegistry) Names() []string {
+	configs := reg.load()
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Add stores configs of newsletters that do not exist yet. Existing
+// newsletters keep the settings changed via admin API.
+func (reg *NewsletterRegistry) Add(configs []*common.NewsletterConfig) error {
+	defer reg.Invalidate()
+
+	for _, nc := range configs {
+		if nc == nil || nc.Name == "" {
+			continue
+		}
+
+		err := reg.Store.AddNewsletter(nc)
+		if err != nil && err != common.ErrNewsletterExists {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Seed stores configs only if there are no newsletters in the store yet,
+// so that newsletters deleted via admin API do not come back on start.
+// It returns false if the store is not empty.
+func (reg *NewsletterRegistry) Seed(configs []*common.NewsletterConfig) (bool, error) {
+	newsletters, err := reg.Store.Newsletters()
+	if err != nil {
+		return false, err
+	}
+
+	if len(newsletters) > 0 {
+		return false, nil
+	}
+
+	return true, reg.Add(configs)
+}
+
+// AddNames stores newsletters without settings, see Add
+func (reg *NewsletterRegistry) AddNames(names []string) error {
+	configs := make([]*common.NewsletterConfig, 0, len(names))
+	for _, name := range names {
+		configs = append(configs, &common.NewsletterConfig{Name: name})
+	}
+
+	return reg.Add(configs)
+}
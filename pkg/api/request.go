@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/rs/xid"
+)
+
+// HeaderRequestID is accepted from proxies and returned in every response
+const HeaderRequestID = "X-Request-Id"
+
+// longer IDs from the header are replaced with generated ones
+const maxRequestIDLength = 128
+
+type subscribersScoper interface {
+	WithLogger(l *common.Logger) common.SubscribersStore
+}
+
+type notificationsScoper interface {
+	WithLogger(l *common.Logger) common.NotificationsStore
+}
+
+type mailerScoper interface {
+	WithLogger(l *common.Logger) common.Mailer
+}
+
+// requestID returns ID of the API Gateway request, ID from the header
+// or a new one
+func requestID(r *http.Request) string {
+	if ctx, ok := core.GetAPIGatewayContextFromContext(r.Context()); ok && ctx.RequestID != "" {
+		return ctx.RequestID
+	}
+
+	if id := r.Header.Get(HeaderRequestID); id != "" && len(id) <= maxRequestIDLength {
+		return id
+	}
+
+	return xid.New().String()
+}
+
+// scopedSubscribers returns the store that logs with the logger if it supports it
+func scopedSubscribers(store common.SubscribersStore, l *common.Logger) common.SubscribersStore {
+	if s, ok := store.(subscribersScoper); ok {
+		return s.WithLogger(l)
+	}
+
+	return store
+}
+
+// scopedNotifications returns the store that logs with the logger if it supports it
+func scopedNotifications(store common.NotificationsStore, l *common.Logger) common.NotificationsStore {
+	if s, ok := store.(notificationsScoper); ok {
+		return s.WithLogger(l)
+	}
+
+	return store
+}
+
+// scopedMailer returns the mailer that logs with the logger if it supports it
+func scopedMailer(mailer common.Mailer, l *common.Logger) common.Mailer {
+	if m, ok := mailer.(mailerScoper); ok {
+		return m.WithLogger(l)
+	}
+
+	return mailer
+}
+
+// scoped runs the handler with a copy of the resource that adds request ID
+// to every log line of the request, including stores and mailer
+func (nr *NewsletterResource) scoped(handler func(*NewsletterResource, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r)
+		w.Header().Set(HeaderRequestID, id)
+
+		c := *nr
+		c.Logger = nr.Logger.With("request_id", id)
+		c.Subscribers = scopedSubscribers(nr.Subscribers, c.Logger)
+		c.Notifications = scopedNotifications(nr.Notifications, c.Logger)
+		c.Mailer = scopedMailer(nr.Mailer, c.Logger)
+
+		handler(&c, w, r)
+	}
+}
+
+// scoped runs the handler with a copy of the resource that adds request ID
+// and the name of the API key to every log line of the request, including stores
+func (ar *AdminResource) scoped(handler func(*AdminResource, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r)
+		w.Header().Set(HeaderRequestID, id)
+
+		c := *ar
+		c.Logger = ar.Logger.With("request_id", id)
+		if c.key = requestAPIKey(r); c.key != nil {
+			c.Logger = c.Logger.With("api_key", c.key.Name)
+		}
+		c.Subscribers = scopedSubscribers(ar.Subscribers, c.Logger)
+		c.Notifications = scopedNotifications(ar.Notifications, c.Logger)
+
+		handler(&c, w, r)
+	}
+}
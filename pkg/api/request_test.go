@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+// LoggingMailer logs every confirmation with its logger
+type LoggingMailer struct {
+	logger *common.Logger
+}
+
+func (m *LoggingMailer) SendConfirmation(newsletter *common.NewsletterConfig, email, name, locale string) error {
+	m.logger.Info("Sent email", "email", email)
+	return nil
+}
+
+func (m *LoggingMailer) WithLogger(l *common.Logger) common.Mailer {
+	return &LoggingMailer{logger: l}
+}
+
+func logLines(t *testing.T, b *bytes.Buffer) []map[string]interface{} {
+	lines := make([]map[string]interface{}, 0)
+
+	for _, s := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		line := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(s), &line); err != nil {
+			t.Fatalf("Failed to parse log line. line=%v err=%v", s, err)
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestSubscribeLogsRequestID(t *testing.T) {
+	var b bytes.Buffer
+	logger := common.NewLogger(&b)
+	logger.Emails = common.EmailsRedact
+
+	srv := http.NewServeMux()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Logger = logger
+	nr.Mailer = &LoggingMailer{logger: logger}
+	// metered mailer has to pass the logger through
+	nr.Metrics = NewMetrics()
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	req, err := subscribeRequest(testEmail, "127.0.0.1:1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(HeaderRequestID, "req-1")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Header().Get(HeaderRequestID) != "req-1" {
+		t.Errorf("Unexpected request ID header %v", w.Header().Get(HeaderRequestID))
+	}
+
+	lines := logLines(t, &b)
+	if len(lines) < 2 {
+		t.Fatalf("Unexpected number of log lines %v", len(lines))
+	}
+
+	for _, line := range lines {
+		if line["request_id"] != "req-1" {
+			t.Errorf("Log line is missing request ID. line=%v", line)
+		}
+
+		if email, ok := line["email"]; ok && email == testEmail {
+			t.Errorf("Email is not redacted. line=%v", line)
+		}
+	}
+}
+
+func TestGeneratedRequestID(t *testing.T) {
+	srv := http.NewServeMux()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Logger = common.NewLogger(&bytes.Buffer{})
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	ids := make(map[string]bool)
+
+	for i := 0; i < 2; i++ {
+		req, err := subscribeRequest(testEmail, "127.0.0.1:1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		id := w.Header().Get(HeaderRequestID)
+		if id == "" {
+			t.Fatalf("Request ID is not generated")
+		}
+		ids[id] = true
+	}
+
+	if len(ids) != 2 {
+		t.Errorf("Request IDs are not unique")
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// allowResend checks rate limit for the client address and the cooldown
+// of the email. Cooldown is applied to any email, existing or not, so
+// that responses do not disclose subscribers. Requests without known
+// address are not limited by it, see allowSubscribe.
+func (nr *NewsletterResource) allowResend(r *http.Request, email string) bool {
+	if ip := clientIP(r); nr.IPLimiter != nil && ip != "" {
+		ok, err := nr.IPLimiter.Allow("resend-ip:" + ip)
+		if err != nil {
+			nr.Logger.Error("Failed to check rate limit", "ip", ip, "err", err)
+		} else if !ok {
+			nr.Logger.Warn("Rate limit exceeded", "ip", ip)
+			return false
+		}
+	}
+
+	if nr.ResendLimiter != nil {
+		ok, err := nr.ResendLimiter.Allow("resend:" + strings.ToLower(email))
+		if err != nil {
+			nr.Logger.Error("Failed to check resend cooldown", "email", email, "err", err)
+		} else if !ok {
+			nr.Logger.Warn("Resend cooldown is active", "email", email)
+			return false
+		}
+	}
+
+	return true
+}
+
+// resend sends confirmation email again to the pending subscriber without
+// changing the stored record. The response is the same whether the email
+// was sent or not.
+func (nr *NewsletterResource) resend(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSubscribeBodySize)
+	if err := r.ParseForm(); err != nil {
+		nr.Logger.Warn("Failed to parse form", "err", err)
+	}
+
+	newsletter := r.FormValue(common.ParamNewsletter)
+	email, err := validEmail(nr.EmailNormalizer, r.FormValue(common.ParamEmail))
+	if err != nil {
+		nr.Logger.Warn("Failed to validate email", "email", r.FormValue(common.ParamEmail), "err", err)
+		fail(w, r, http.StatusBadRequest, OutcomeInvalidEmail, http.StatusText(http.StatusBadRequest))
+
+		return
+	}
+
+	if !nr.isActiveNewsletter(newsletter) {
+		nr.Logger.Warn("Invalid newsletter", "newsletter", newsletter)
+		fail(w, r, http.StatusBadRequest, OutcomeUnknownNewsletter, http.StatusText(http.StatusBadRequest))
+
+		return
+	}
+
+	if !nr.allowResend(r, email) {
+		fail(w, r, http.StatusTooManyRequests, OutcomeRateLimited, http.StatusText(http.StatusTooManyRequests))
+		return
+	}
+
+	nc := nr.config(newsletter)
+	nr.resendConfirmation(r, nc, email)
+
+	succeed(w, r, OutcomePendingConfirmation, nc.SubscribeRedirectURL)
+}
+
+func (nr *NewsletterResource) resendConfirmation(r *http.Request, nc *common.NewsletterConfig, email string) {
+	if !nc.DoubleOptIn() {
+		return
+	}
+
+	s, err := nr.Subscribers.GetSubscriber(nc.Name, email)
+	if err != nil {
+		nr.Logger.Warn("Subscriber for resend is not found", "email", email, "newsletter", nc.Name, "err", err)
+		return
+	}
+
+	if s.Confirmed() || s.Unsubscribed() {
+		nr.Logger.Warn("Subscriber for resend is not pending", "email", email, "newsletter", nc.Name)
+		return
+	}
+
+	suppressed, err := nr.isSuppressed(email)
+	if err != nil || suppressed {
+		nr.Logger.Warn("Refused resend to suppressed email", "email", email, "err", err)
+		return
+	}
+
+	if err := nr.Mailer.SendConfirmation(nc, email, s.Name, s.Locale); err != nil {
+		nr.Logger.Error("Failed to resend confirmation", "email", email, "newsletter", nc.Name, "err", err)
+		return
+	}
+
+	nr.Logger.Info("Resent confirmation", "email", email, "newsletter", nc.Name)
+	nr.addEvent(r, common.EventConfirmationSent, nc.Name, email, common.SourceResend)
+}
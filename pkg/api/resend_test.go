@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func resendRequest(email string) *http.Request {
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, email)
+	data.Set(common.ParamFormat, common.FormatJSON)
+
+	req := formRequest(data)
+	req.URL.Path = common.ResendEndpoint
+	return req
+}
+
+func TestResendConfirmation(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "uk", nil)
+	s, _ := store.GetSubscriber(testNewsletter, testEmail)
+	created := s.CreatedAt.Time()
+
+	mailer := &LocaleMailer{}
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.Mailer = mailer
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, resendRequest(testEmail))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	if mailer.locale != "uk" {
+		t.Errorf("Confirmation was not resent in subscriber locale. locale=%v", mailer.locale)
+	}
+
+	s, _ = store.GetSubscriber(testNewsletter, testEmail)
+	if !s.CreatedAt.Time().Equal(created) || s.Confirmed() {
+		t.Errorf("Subscriber was changed by resend")
+	}
+}
+
+func TestResendDoesNotDisclose(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, "confirmed@email.com", testName, "", nil)
+	store.ConfirmSubscriber(testNewsletter, "confirmed@email.com")
+
+	mailer := &CountingMailer{}
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.Mailer = mailer
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	var bodies []string
+	for _, email := range []string{"unknown@email.com", "confirmed@email.com"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, resendRequest(email))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Unexpected status code %d", w.Code)
+		}
+		bodies = append(bodies, w.Body.String())
+	}
+
+	if bodies[0] != bodies[1] {
+		t.Errorf("Responses differ. unknown=%v confirmed=%v", bodies[0], bodies[1])
+	}
+
+	if mailer.count != 0 {
+		t.Errorf("Unexpected confirmation emails: %v", mailer.count)
+	}
+}
+
+func TestResendCooldown(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	mailer := &CountingMailer{}
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.Mailer = mailer
+	nr.ResendLimiter = NewMemoryRateLimiter(1, time.Hour)
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	expected := []int{http.StatusOK, http.StatusTooManyRequests}
+	for _, status := range expected {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, resendRequest(testEmail))
+
+		if w.Code != status {
+			t.Errorf("Unexpected status code. actual=%v expected=%v", w.Code, status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, resendRequest("unknown@email.com"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Cooldown of other email is active")
+	}
+
+	if mailer.count != 1 {
+		t.Errorf("Unexpected number of emails: %v", mailer.count)
+	}
+}
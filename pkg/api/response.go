@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// machine-readable outcomes of the public endpoints
+const (
+	OutcomePendingConfirmation = "pending_confirmation"
+	OutcomeAlreadyConfirmed    = "already_confirmed"
+	OutcomeConfirmed           = "confirmed"
+	OutcomeUnsubscribed        = "unsubscribed"
+	OutcomeAlreadyUnsubscribed = "already_unsubscribed"
+	OutcomeInvalidEmail        = "invalid_email"
+	OutcomeUnknownNewsletter   = "unknown_newsletter"
+	OutcomeInvalidToken        = "invalid_token"
+	OutcomeBadRequest          = "bad_request"
+	OutcomeRateLimited         = "rate_limited"
+	OutcomeVerificationFailed  = "verification_failed"
+	OutcomeInternalError       = "internal_error"
+)
+
+// Response is a body returned by the public endpoints
+// when client asked for JSON instead of redirects
+type Response struct {
+	Status  int    `json:"status"`
+	Outcome string `json:"outcome"`
+	Message string `json:"message,omitempty"`
+}
+
+// wantsJSON checks if request asked for JSON response either via
+// format parameter or via Accept header
+func wantsJSON(r *http.Request) bool {
+	if r.FormValue(common.ParamFormat) == common.FormatJSON {
+		return true
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		if mediaType == "application/json" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		common.DefaultLogger.Error("Failed to encode response", "err", err)
+	}
+}
+
+// succeed redirects browsers to the url or writes outcome for JSON clients
+func succeed(w http.ResponseWriter, r *http.Request, outcome, url string) {
+	setOutcome(w, outcome)
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, &Response{
+			Status:  http.StatusOK,
+			Outcome: outcome,
+		})
+
+		return
+	}
+
+	w.Header().Set("Location", url)
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// fail writes plain text error for browsers or outcome for JSON clients
+func fail(w http.ResponseWriter, r *http.Request, status int, outcome, message string) {
+	setOutcome(w, outcome)
+
+	if wantsJSON(r) {
+		writeJSON(w, status, &Response{
+			Status:  status,
+			Outcome: outcome,
+			Message: message,
+		})
+
+		return
+	}
+
+	http.Error(w, message, status)
+}
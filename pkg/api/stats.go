@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+const (
+	// default range of daily stats ends today
+	defaultStatsDays = 30
+	// longer ranges make responses too big
+	maxStatsDays = 366
+	// suppressedCacheTTL limits how often stats read the whole
+	// notifications table
+	suppressedCacheTTL = 5 * time.Minute
+)
+
+// suppressedCache keeps suppressed addresses between stats requests
+type suppressedCache struct {
+	mu      sync.Mutex
+	checked time.Time
+	emails  map[string]bool
+}
+
+var errInvalidStatsRange = errors.New("The from and to parameters must be dates like 2020-01-31 within a year")
+
+// statsRange returns inclusive range of dates from the parameters
+func statsRange(params url.Values, now time.Time) (time.Time, time.Time, error) {
+	to := now.UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, 1-defaultStatsDays)
+	var err error
+
+	if value := params.Get(common.ParamTo); value != "" {
+		if to, err = time.Parse(common.StatsDateLayout, value); err != nil {
+			return from, to, errInvalidStatsRange
+		}
+		from = to.AddDate(0, 0, 1-defaultStatsDays)
+	}
+
+	if value := params.Get(common.ParamFrom); value != "" {
+		if from, err = time.Parse(common.StatsDateLayout, value); err != nil {
+			return from, to, errInvalidStatsRange
+		}
+	}
+
+	if from.After(to) || to.Sub(from) >= maxStatsDays*24*time.Hour {
+		return from, to, errInvalidStatsRange
+	}
+
+	return from, to, nil
+}
+
+// serveStats returns counts of subscribers of the newsletter
+func (ar *AdminResource) serveStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		ar.Logger.Warn("Unsupported method for stats", "method", r.Method)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	from, to, err := statsRange(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// every newsletter is counted in a separate request to keep it short
+	newsletter := r.URL.Query().Get(common.ParamNewsletter)
+	if !ar.isValidNewsletter(newsletter) {
+		http.Error(w, "The newsletter parameter is invalid", http.StatusBadRequest)
+		return
+	}
+
+	if !ar.allowsNewsletter(newsletter) {
+		http.Error(w, errNewsletterForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	suppressed, err := ar.suppressedEmails()
+	if err != nil {
+		ar.Logger.Error("Failed to fetch notifications", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	ns, err := ar.newsletterStats(newsletter, from, to, suppressed)
+	if err != nil {
+		ar.Logger.Error("Failed to count subscribers", "newsletter", newsletter, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	stats := &common.Stats{
+		From:        from.Format(common.StatsDateLayout),
+		To:          to.Format(common.StatsDateLayout),
+		Newsletters: []*common.NewsletterStats{ns},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(stats)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// suppressedEmails returns suppressed addresses, they are read from all
+// notifications at most once per suppressedCacheTTL
+func (ar *AdminResource) suppressedEmails() (map[string]bool, error) {
+	c := ar.suppressed
+	if c == nil {
+		c = &suppressedCache{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.emails == nil || time.Since(c.checked) >= suppressedCacheTTL {
+		notifications, err := ar.Notifications.Notifications()
+		if err != nil {
+			return nil, err
+		}
+
+		c.emails = common.SuppressedEmails(notifications)
+		c.checked = time.Now()
+	}
+
+	return c.emails, nil
+}
+
+// newsletterStats counts subscribers page by page to not keep all of
+// them in memory. Daily changes are built from the lifecycle events
+// because subscriber records keep only the latest change.
+func (ar *AdminResource) newsletterStats(newsletter string, from, to time.Time, suppressed map[string]bool) (*common.NewsletterStats, error) {
+	ns := common.NewNewsletterStats(newsletter, from, to)
+	query := &common.SubscribersQuery{
+		Newsletter: newsletter,
+		Limit:      maxSubscribersLimit,
+	}
+
+	for {
+		page, err := ar.Subscribers.QuerySubscribers(query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range page.Subscribers {
+			ns.Add(s, suppressed[s.Email])
+		}
+
+		if page.Cursor == "" {
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+
+	if ar.Events == nil {
+		return ns, nil
+	}
+
+	events, err := ar.Events.NewsletterEvents(newsletter, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		ns.AddEvent(e)
+	}
+
+	return ns, nil
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func TestStats(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "", nil)
+	store.AddSubscriber("other", "email3@domain.com", testName, "", nil)
+	time.Sleep(10 * time.Nanosecond)
+	store.ConfirmSubscriber(testNewsletter, "email1@domain.com")
+
+	notifications := db.NewNotificationsMapStore()
+	notifications.AddBounce("email2@domain.com", "from@email.com", false /*is transient*/)
+
+	events := db.NewEventsMapStore()
+	events.AddEvents([]*common.SubscriberEvent{
+		common.NewSubscriberEvent(common.EventSubscribe, testNewsletter, "email1@domain.com"),
+		common.NewSubscriberEvent(common.EventSubscribe, testNewsletter, "email2@domain.com"),
+		common.NewSubscriberEvent(common.EventSubscribe, "other", "email3@domain.com"),
+		common.NewSubscriberEvent(common.EventConfirm, testNewsletter, "email1@domain.com"),
+	})
+
+	ar := NewTestAdminResource(store, notifications)
+	ar.Events = events
+	ar.AddNewsletters([]string{testNewsletter, "other"})
+	ar.Setup(srv)
+
+	req, err := adminRequest("GET", common.StatsEndpoint, "", map[string]string{common.ParamNewsletter: testNewsletter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	stats := &common.Stats{}
+	if err := json.Unmarshal(w.Body.Bytes(), stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats.Newsletters) != 1 {
+		t.Fatalf("Unexpected number of newsletters %v", len(stats.Newsletters))
+	}
+
+	ns := stats.Newsletters[0]
+	if ns.Total != 2 || ns.Confirmed != 1 || ns.Pending != 1 || ns.Suppressed != 1 {
+		t.Errorf("Unexpected counts %+v", ns)
+	}
+
+	if ns.ConversionRate != 0.5 {
+		t.Errorf("Unexpected conversion rate %v", ns.ConversionRate)
+	}
+
+	if len(ns.Daily) != defaultStatsDays {
+		t.Fatalf("Unexpected number of days %v", len(ns.Daily))
+	}
+
+	today := ns.Daily[len(ns.Daily)-1]
+	if today.Date != stats.To || today.Signups != 2 || today.Confirmations != 1 {
+		t.Errorf("Unexpected daily stats %+v", today)
+	}
+}
+
+func TestStatsHistory(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+
+	// the subscriber has been purged and the other one resubscribed later
+	day := time.Date(2020, 1, 3, 10, 0, 0, 0, time.UTC)
+	events := db.NewEventsMapStore()
+	for i, e := range []*common.SubscriberEvent{
+		common.NewSubscriberEvent(common.EventSubscribe, testNewsletter, "email1@domain.com"),
+		common.NewSubscriberEvent(common.EventSubscribe, testNewsletter, "email2@domain.com"),
+		common.NewSubscriberEvent(common.EventConfirm, testNewsletter, "email1@domain.com"),
+		common.NewSubscriberEvent(common.EventUnsubscribe, testNewsletter, "email1@domain.com"),
+	} {
+		created := day.Add(time.Duration(i) * time.Minute)
+		e.CreatedAt = common.JSONTime(created)
+		e.ID = common.EventIDAt(created)
+		events.AddEvents([]*common.SubscriberEvent{e})
+	}
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.Events = events
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	req, err := adminRequest("GET", common.StatsEndpoint, "", map[string]string{
+		common.ParamNewsletter: testNewsletter,
+		common.ParamFrom:       "2020-01-01",
+		common.ParamTo:         "2020-01-07",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	stats := &common.Stats{}
+	if err := json.Unmarshal(w.Body.Bytes(), stats); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := stats.Newsletters[0]
+	if len(ns.Daily) != 7 {
+		t.Fatalf("Unexpected number of days %v", len(ns.Daily))
+	}
+
+	ds := ns.Daily[2]
+	if ds.Date != "2020-01-03" || ds.Signups != 2 || ds.Confirmations != 1 || ds.Unsubscribes != 1 {
+		t.Errorf("Unexpected daily stats %+v", ds)
+	}
+
+	if ns.ConversionRate != 0.5 {
+		t.Errorf("Unexpected conversion rate %v", ns.ConversionRate)
+	}
+}
+
+func TestStatsRequiresNewsletter(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	req, err := adminRequest("GET", common.StatsEndpoint, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+}
+
+func TestStatsFailingStore(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(NewFailingStore(), db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	req, err := adminRequest("GET", common.StatsEndpoint, "", map[string]string{common.ParamNewsletter: testNewsletter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+}
+
+func TestStatsSuppressedIsCached(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, "email1@domain.com", testName, "", nil)
+	store.AddSubscriber(testNewsletter, "email2@domain.com", testName, "", nil)
+
+	notifications := db.NewNotificationsMapStore()
+	notifications.AddBounce("email1@domain.com", "from@email.com", false /*is transient*/)
+
+	ar := NewTestAdminResource(store, notifications)
+	ar.Events = db.NewEventsMapStore()
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	for i := 0; i < 2; i++ {
+		req, err := adminRequest("GET", common.StatsEndpoint, "", map[string]string{common.ParamNewsletter: testNewsletter})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status code %d", w.Code)
+		}
+
+		stats := &common.Stats{}
+		if err := json.Unmarshal(w.Body.Bytes(), stats); err != nil {
+			t.Fatal(err)
+		}
+
+		if ns := stats.Newsletters[0]; ns.Suppressed != 1 {
+			t.Errorf("Unexpected suppressed count %v", ns.Suppressed)
+		}
+
+		// not visible until the cached addresses expire
+		notifications.AddComplaint("email2@domain.com", "from@email.com")
+	}
+}
+
+func TestStatsRange(t *testing.T) {
+	now := time.Date(2020, 3, 15, 13, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		from  string
+		to    string
+		valid bool
+		first string
+		last  string
+	}{
+		{"", "", true, "2020-02-15", "2020-03-15"},
+		{"2020-03-01", "", true, "2020-03-01", "2020-03-15"},
+		{"", "2020-01-30", true, "2020-01-01", "2020-01-30"},
+		{"2020-03-10", "2020-03-10", true, "2020-03-10", "2020-03-10"},
+		{"2020-03-10", "2020-03-01", false, "", ""},
+		{"2019-01-01", "2020-03-01", false, "", ""},
+		{"yesterday", "", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		params := url.Values{}
+		if tt.from != "" {
+			params.Set(common.ParamFrom, tt.from)
+		}
+		if tt.to != "" {
+			params.Set(common.ParamTo, tt.to)
+		}
+
+		from, to, err := statsRange(params, now)
+		if (err == nil) != tt.valid {
+			t.Errorf("Unexpected validation result. from=%v to=%v err=%v", tt.from, tt.to, err)
+			continue
+		}
+
+		if tt.valid && (from.Format(common.StatsDateLayout) != tt.first || to.Format(common.StatsDateLayout) != tt.last) {
+			t.Errorf("Unexpected range. from=%v to=%v", from, to)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// subscriberPath returns the newsletter and the email from the path
+// /subscribers/{newsletter}/{email}
+func subscriberPath(u *url.URL) (string, string, bool) {
+	path := strings.TrimPrefix(u.EscapedPath(), common.SubscribersEndpoint+"/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	newsletter, err := url.PathUnescape(parts[0])
+	if err != nil || newsletter == "" {
+		return "", "", false
+	}
+
+	email, err := url.PathUnescape(parts[1])
+	if err != nil || email == "" {
+		return "", "", false
+	}
+
+	return newsletter, email, true
+}
+
+// serveSubscriber manages one subscriber of the newsletter
+func (ar *AdminResource) serveSubscriber(w http.ResponseWriter, r *http.Request) {
+	newsletter, email, ok := subscriberPath(r.URL)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	if !ar.isValidNewsletter(newsletter) {
+		http.Error(w, "The newsletter is invalid", http.StatusBadRequest)
+		return
+	}
+
+	if !ar.allowsNewsletter(newsletter) {
+		http.Error(w, errNewsletterForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := ar.EmailNormalizer.Normalize(email)
+	if err != nil {
+		http.Error(w, "The email is invalid", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		{
+			ar.getSubscriber(w, r, newsletter, email)
+		}
+	case "PATCH":
+		{
+			ar.patchSubscriber(w, r, newsletter, email)
+		}
+	case "DELETE":
+		{
+			ar.deleteSubscriber(w, r, newsletter, email)
+		}
+	default:
+		{
+			ar.Logger.Warn("Unsupported method for subscriber", "method", r.Method)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+}
+
+// findSubscriber responds with an error if the subscriber cannot be fetched
+func (ar *AdminResource) findSubscriber(w http.ResponseWriter, newsletter, email string) (*common.Subscriber, bool) {
+	s, err := ar.Subscribers.GetSubscriber(newsletter, email)
+	if err == common.ErrSubscriberNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+
+	if err != nil {
+		ar.Logger.Error("Failed to fetch subscriber", "email", email, "newsletter", newsletter, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return nil, false
+	}
+
+	return s, true
+}
+
+func writeSubscriber(w http.ResponseWriter, s *common.Subscriber) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (ar *AdminResource) getSubscriber(w http.ResponseWriter, r *http.Request, newsletter, email string) {
+	s, ok := ar.findSubscriber(w, newsletter, email)
+	if !ok {
+		return
+	}
+
+	writeSubscriber(w, s)
+}
+
+// patchSubscriber changes only the fields present in the request
+func (ar *AdminResource) patchSubscriber(w http.ResponseWriter, r *http.Request, newsletter, email string) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type header is not application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPatchBodySize)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	patch := &common.SubscriberPatch{}
+	err := dec.Decode(patch)
+	if err != nil {
+		ar.Logger.Warn("Failed to decode subscriber patch", "err", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	s, ok := ar.findSubscriber(w, newsletter, email)
+	if !ok {
+		return
+	}
+
+	update := patch.Update(s, common.JsonTimeNow())
+	if update.Empty() {
+		writeSubscriber(w, s)
+		return
+	}
+
+	// in-memory stores change the same instance
+	confirmed, unsubscribed := s.Confirmed(), s.Unsubscribed()
+
+	updated, err := ar.Subscribers.UpdateSubscriber(newsletter, email, update)
+	if err == common.ErrSubscriberNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		ar.Logger.Error("Failed to update subscriber", "email", email, "newsletter", newsletter, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	ar.Logger.Info("Updated subscriber", "email", email, "newsletter", newsletter)
+
+	events := make([]*common.SubscriberEvent, 0)
+	if updated.Confirmed() && !confirmed {
+		events = append(events, newEvent(r, common.EventConfirm, newsletter, email, common.SourceAdmin))
+	}
+	if updated.Unsubscribed() && !unsubscribed {
+		events = append(events, newEvent(r, common.EventUnsubscribe, newsletter, email, common.SourceAdmin))
+	}
+	addEvents(ar.Logger, ar.Events, ar.Publisher, events)
+
+	writeSubscriber(w, updated)
+}
+
+func (ar *AdminResource) deleteSubscriber(w http.ResponseWriter, r *http.Request, newsletter, email string) {
+	if _, ok := ar.findSubscriber(w, newsletter, email); !ok {
+		return
+	}
+
+	err := ar.Subscribers.DeleteSubscribers([]*common.SubscriberKey{{Newsletter: newsletter, Email: email}})
+	if err != nil {
+		ar.Logger.Error("Failed to delete subscriber", "email", email, "newsletter", newsletter, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	ar.Logger.Info("Deleted subscriber", "email", email, "newsletter", newsletter)
+	addEvents(ar.Logger, ar.Events, ar.Publisher, []*common.SubscriberEvent{
+		newEvent(r, common.EventDelete, newsletter, email, common.SourceAdmin),
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
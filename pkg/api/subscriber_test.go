@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func TestGetSubscriber(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.EmailNormalizer = &common.EmailNormalizer{LowercaseLocal: true}
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/" + testNewsletter + "/" + testEmail, http.StatusOK},
+		{"/" + testNewsletter + "/Foo%40Bar.com", http.StatusOK},
+		{"/" + testNewsletter + "/other@bar.com", http.StatusNotFound},
+		{"/other/" + testEmail, http.StatusBadRequest},
+		{"/" + testNewsletter + "/not-an-email", http.StatusBadRequest},
+		{"/" + testNewsletter, http.StatusNotFound},
+		{"/" + testNewsletter + "/" + testEmail + "/name", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req, err := adminRequest("GET", common.SubscribersEndpoint+tt.path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code. path=%v expected=%v actual=%v", tt.path, tt.code, w.Code)
+			continue
+		}
+
+		if tt.code == http.StatusOK {
+			s := &common.Subscriber{}
+			if err := json.Unmarshal(w.Body.Bytes(), s); err != nil {
+				t.Fatal(err)
+			}
+
+			if s.Email != testEmail || s.Name != testName {
+				t.Errorf("Unexpected subscriber %+v", s)
+			}
+		}
+	}
+}
+
+func TestPatchSubscriber(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", map[string]string{"country": "UA"})
+	events := db.NewEventsMapStore()
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.Events = events
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	time.Sleep(10 * time.Nanosecond)
+	req, err := adminRequest("PATCH", common.SubscribersEndpoint+"/"+testNewsletter+"/"+testEmail, `{"name": "Bar", "confirmed": true}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	s, _ := store.GetSubscriber(testNewsletter, testEmail)
+	if s.Name != "Bar" || !s.Confirmed() || s.Unsubscribed() || s.Attributes["country"] != "UA" {
+		t.Errorf("Unexpected subscriber %+v", s)
+	}
+
+	history, _ := events.Events(testEmail)
+	if len(history) != 1 || history[0].Event != common.EventConfirm || history[0].Source != common.SourceAdmin {
+		t.Errorf("Unexpected events %v", history)
+	}
+
+	req, err = adminRequest("PATCH", common.SubscribersEndpoint+"/"+testNewsletter+"/"+testEmail, `{"attributes": {}, "unsubscribed": true}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	if s.Name != "Bar" || !s.Unsubscribed() || s.Attributes != nil {
+		t.Errorf("Unexpected subscriber %+v", s)
+	}
+}
+
+func TestPatchSubscriberErrors(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	tests := []struct {
+		email string
+		body  string
+		code  int
+	}{
+		{testEmail, `{"email": "other@bar.com"}`, http.StatusBadRequest},
+		{testEmail, `{"confirmed": "yes"}`, http.StatusBadRequest},
+		{"other@bar.com", `{"confirmed": true}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req, err := adminRequest("PATCH", common.SubscribersEndpoint+"/"+testNewsletter+"/"+tt.email, tt.body, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code. body=%v expected=%v actual=%v", tt.body, tt.code, w.Code)
+		}
+	}
+}
+
+func TestDeleteSubscriber(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	store.AddSubscriber(testNewsletter, testEmail, testName, "", nil)
+
+	ar := NewTestAdminResource(store, db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	for _, code := range []int{http.StatusOK, http.StatusNotFound} {
+		req, err := adminRequest("DELETE", common.SubscribersEndpoint+"/"+testNewsletter+"/"+testEmail, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != code {
+			t.Errorf("Unexpected status code. expected=%v actual=%v", code, w.Code)
+		}
+	}
+
+	if store.Count() != 0 {
+		t.Errorf("Subscriber is not deleted")
+	}
+}
+
+func TestSubscriberFailingStore(t *testing.T) {
+	srv := http.NewServeMux()
+	ar := NewTestAdminResource(NewFailingStore(), db.NewNotificationsMapStore())
+	ar.AddNewsletters([]string{testNewsletter})
+	ar.Setup(srv)
+
+	req, err := adminRequest("GET", common.SubscribersEndpoint+"/"+testNewsletter+"/"+testEmail, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected status code %d", w.Code)
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+var (
+	errHoneypotFilled = errors.New("Honeypot field is filled")
+	errInvalidStamp   = errors.New("Form timestamp is invalid")
+	errTooFast        = errors.New("Form was submitted too fast")
+	errStampExpired   = errors.New("Form timestamp has expired")
+	errMissingCaptcha = errors.New("Captcha response is missing")
+	errCaptchaFailed  = errors.New("Captcha verification failed")
+)
+
+const (
+	stampPrefix           = "stamp:"
+	defaultCaptchaTimeout = 5 * time.Second
+	// DefaultCaptchaField is the field that reCAPTCHA widget adds to the form
+	DefaultCaptchaField = "g-recaptcha-response"
+)
+
+// SubmissionVerifier checks that the subscribe form was submitted by a human
+type SubmissionVerifier interface {
+	Verify(r *http.Request) error
+}
+
+// stamper is implemented by verifiers that need a value rendered in the form
+type stamper interface {
+	Stamp() string
+}
+
+func serveStamp(s stamper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, map[string]string{
+			"stamp": s.Stamp(),
+		})
+	}
+}
+
+// HoneypotVerifier rejects forms where hidden field was filled.
+// Bots tend to fill every input they find in the form.
+type HoneypotVerifier struct {
+	Field string
+}
+
+var _ SubmissionVerifier = (*HoneypotVerifier)(nil)
+
+func (v *HoneypotVerifier) Verify(r *http.Request) error {
+	if r.FormValue(v.Field) != "" {
+		return errHoneypotFilled
+	}
+
+	return nil
+}
+
+// FormTimestampVerifier checks signed timestamp of the moment when form
+// was rendered and rejects forms submitted too fast or too late
+type FormTimestampVerifier struct {
+	Secret   string
+	Field    string
+	MinDelay time.Duration
+	// MaxAge of the form, zero means forms do not expire
+	MaxAge time.Duration
+}
+
+var _ SubmissionVerifier = (*FormTimestampVerifier)(nil)
+var _ stamper = (*FormTimestampVerifier)(nil)
+
+// Stamp returns signed current time to be put in the form
+func (v *FormTimestampVerifier) Stamp() string {
+	return common.Sign(v.Secret, stampPrefix+strconv.FormatInt(time.Now().UnixNano(), 10))
+}
+
+func (v *FormTimestampVerifier) Verify(r *http.Request) error {
+	payload, ok := common.Unsign(v.Secret, r.FormValue(v.Field))
+	if !ok || !strings.HasPrefix(payload, stampPrefix) {
+		return errInvalidStamp
+	}
+
+	ns, err := strconv.ParseInt(strings.TrimPrefix(payload, stampPrefix), 10, 64)
+	if err != nil {
+		return errInvalidStamp
+	}
+
+	elapsed := time.Since(time.Unix(0, ns))
+	if elapsed < v.MinDelay {
+		return errTooFast
+	}
+
+	if v.MaxAge > 0 && elapsed > v.MaxAge {
+		return errStampExpired
+	}
+
+	return nil
+}
+
+// CaptchaVerifier checks captcha response with remote verification
+// API compatible with reCAPTCHA, hCaptcha and Turnstile
+type CaptchaVerifier struct {
+	// URL of the verification API, e.g. https://www.google.com/recaptcha/api/siteverify
+	URL    string
+	Secret string
+	// Field of the form with captcha response, DefaultCaptchaField if empty
+	Field  string
+	Client *http.Client
+}
+
+var _ SubmissionVerifier = (*CaptchaVerifier)(nil)
+
+type captchaResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func (v *CaptchaVerifier) client() *http.Client {
+	if v.Client != nil {
+		return v.Client
+	}
+
+	return &http.Client{Timeout: defaultCaptchaTimeout}
+}
+
+func (v *CaptchaVerifier) field() string {
+	if v.Field != "" {
+		return v.Field
+	}
+
+	return DefaultCaptchaField
+}
+
+func (v *CaptchaVerifier) Verify(r *http.Request) error {
+	response := r.FormValue(v.field())
+	if response == "" {
+		return errMissingCaptcha
+	}
+
+	data := url.Values{}
+	data.Set("secret", v.Secret)
+	data.Set("response", response)
+	if ip := clientIP(r); ip != "" {
+		data.Set("remoteip", ip)
+	}
+
+	resp, err := v.client().PostForm(v.URL, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errCaptchaFailed
+	}
+
+	cr := &captchaResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(cr); err != nil {
+		return err
+	}
+
+	if !cr.Success {
+		return errCaptchaFailed
+	}
+
+	return nil
+}
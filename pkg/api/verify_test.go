@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+func formRequest(data url.Values) *http.Request {
+	req, _ := http.NewRequest("POST", common.SubscribeEndpoint, strings.NewReader(data.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestHoneypotVerifier(t *testing.T) {
+	v := &HoneypotVerifier{Field: "website"}
+
+	if err := v.Verify(formRequest(url.Values{})); err != nil {
+		t.Errorf("Empty honeypot is rejected. err=%v", err)
+	}
+
+	data := url.Values{}
+	data.Set("website", "http://spam.com")
+	if err := v.Verify(formRequest(data)); err != errHoneypotFilled {
+		t.Errorf("Filled honeypot is accepted. err=%v", err)
+	}
+}
+
+func TestFormTimestampVerifier(t *testing.T) {
+	v := &FormTimestampVerifier{
+		Secret:   secret,
+		Field:    "stamp",
+		MinDelay: 10 * time.Millisecond,
+		MaxAge:   1 * time.Hour,
+	}
+
+	data := url.Values{}
+	data.Set("stamp", v.Stamp())
+	if err := v.Verify(formRequest(data)); err != errTooFast {
+		t.Errorf("Fast submission is accepted. err=%v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := v.Verify(formRequest(data)); err != nil {
+		t.Errorf("Valid submission is rejected. err=%v", err)
+	}
+
+	old := strconvStamp(time.Now().Add(-2 * time.Hour))
+	data.Set("stamp", common.Sign(secret, old))
+	if err := v.Verify(formRequest(data)); err != errStampExpired {
+		t.Errorf("Expired submission is accepted. err=%v", err)
+	}
+
+	data.Set("stamp", common.Sign("other", old))
+	if err := v.Verify(formRequest(data)); err != errInvalidStamp {
+		t.Errorf("Invalid stamp is accepted. err=%v", err)
+	}
+
+	data.Set("stamp", common.Sign(secret, testEmail))
+	if err := v.Verify(formRequest(data)); err != errInvalidStamp {
+		t.Errorf("Token is accepted as stamp. err=%v", err)
+	}
+}
+
+func strconvStamp(t time.Time) string {
+	return stampPrefix + strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func captchaStub(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secret") != secret {
+			t.Errorf("Unexpected captcha secret %v", r.FormValue("secret"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&captchaResponse{
+			Success: r.FormValue("response") == "human",
+		})
+	}))
+}
+
+func TestCaptchaVerifier(t *testing.T) {
+	stub := captchaStub(t)
+	defer stub.Close()
+
+	v := &CaptchaVerifier{
+		URL:    stub.URL,
+		Secret: secret,
+		Field:  "captcha",
+		Client: stub.Client(),
+	}
+
+	tests := []struct {
+		response string
+		err      error
+	}{
+		{"", errMissingCaptcha},
+		{"robot", errCaptchaFailed},
+		{"human", nil},
+	}
+
+	for _, tt := range tests {
+		data := url.Values{}
+		if tt.response != "" {
+			data.Set("captcha", tt.response)
+		}
+
+		if err := v.Verify(formRequest(data)); err != tt.err {
+			t.Errorf("Unexpected error. response=%v err=%v expected=%v", tt.response, err, tt.err)
+		}
+	}
+}
+
+func TestCaptchaVerifierDefaultField(t *testing.T) {
+	stub := captchaStub(t)
+	defer stub.Close()
+
+	v := &CaptchaVerifier{
+		URL:    stub.URL,
+		Secret: secret,
+		Client: stub.Client(),
+	}
+
+	data := url.Values{}
+	data.Set(DefaultCaptchaField, "human")
+
+	if err := v.Verify(formRequest(data)); err != nil {
+		t.Errorf("Captcha response is not found in the default field. err=%v", err)
+	}
+}
+
+func TestSubscribeVerificationFailed(t *testing.T) {
+	srv := http.NewServeMux()
+	store := db.NewSubscribersMapStore()
+	mailer := &CountingMailer{}
+	nr := NewTestNewsResource(store, db.NewNotificationsMapStore())
+	nr.Mailer = mailer
+	nr.Verifiers = []SubmissionVerifier{&HoneypotVerifier{Field: "website"}}
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, testEmail)
+	data.Set("website", "http://spam.com")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, formRequest(data))
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	if store.Count() != 0 || mailer.count != 0 {
+		t.Errorf("Unverified submission was processed. count=%v emails=%v", store.Count(), mailer.count)
+	}
+}
+
+func TestStampEndpoint(t *testing.T) {
+	srv := http.NewServeMux()
+	v := &FormTimestampVerifier{Secret: secret, Field: "stamp"}
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Verifiers = []SubmissionVerifier{v}
+	nr.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.StampEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	body := make(map[string]string)
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	data := url.Values{}
+	data.Set("stamp", body["stamp"])
+	if err := v.Verify(formRequest(data)); err != nil {
+		t.Errorf("Stamp from endpoint is rejected. err=%v", err)
+	}
+}
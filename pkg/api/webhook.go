@@ -0,0 +1,294 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ribtoks/backoff"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// EventPublisher sends lifecycle events to external systems
+type EventPublisher interface {
+	// Publish queues events and returns without waiting for delivery
+	Publish(events []*common.SubscriberEvent)
+	// Flush waits until queued events are delivered or failed
+	Flush()
+}
+
+// headers of the webhook requests
+const (
+	HeaderWebhookSignature = "X-Listing-Signature"
+	HeaderWebhookTimestamp = "X-Listing-Timestamp"
+)
+
+const (
+	defaultWebhookAttempts = 3
+	defaultWebhookTimeout  = 5 * time.Second
+	defaultWebhookQueue    = 1000
+	// payloads are kept in dead letters and DynamoDB items
+	// cannot be larger than 400KB
+	defaultWebhookPayload = 256 * kilobyte
+)
+
+const errWebhookQueueFull = "Webhook queue is full"
+
+// webhookEvents are published to webhooks, other events are internal
+var webhookEvents = map[string]bool{
+	common.EventSubscribe:   true,
+	common.EventConfirm:     true,
+	common.EventUnsubscribe: true,
+	common.EventImport:      true,
+	common.EventDelete:      true,
+}
+
+// WebhookPayload is a JSON body of the webhook request
+type WebhookPayload struct {
+	Events []*common.SubscriberEvent `json:"events"`
+}
+
+type webhookDelivery struct {
+	url  string
+	body []byte
+}
+
+// WebhookPublisher posts events to every URL in the background and retries
+// failed deliveries with exponential backoff. Events are split into
+// payloads of at most MaxPayloadSize bytes. Deliveries that failed all
+// attempts or did not fit into the queue of QueueSize are kept
+// in DeadLetters.
+type WebhookPublisher struct {
+	URLs           []string
+	Secret         string
+	Client         *http.Client
+	MaxAttempts    int
+	MinDelay       time.Duration
+	MaxDelay       time.Duration
+	QueueSize      int
+	MaxPayloadSize int
+	DeadLetters    common.DeadLettersStore
+	Logger         *common.Logger
+
+	once    sync.Once
+	queue   chan *webhookDelivery
+	pending sync.WaitGroup
+}
+
+var _ EventPublisher = (*WebhookPublisher)(nil)
+
+// WebhookSignature returns signature of the webhook request. Receivers
+// compute it with the shared secret to verify X-Listing-Signature header.
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	return common.Signature(secret, timestamp+"."+string(body))
+}
+
+func (wp *WebhookPublisher) Publish(events []*common.SubscriberEvent) {
+	published := make([]*common.SubscriberEvent, 0, len(events))
+	for _, e := range events {
+		if webhookEvents[e.Event] {
+			published = append(published, e)
+		}
+	}
+
+	if len(published) == 0 || len(wp.URLs) == 0 {
+		return
+	}
+
+	bodies, err := wp.payloads(published)
+	if err != nil {
+		wp.Logger.Error("Failed to encode webhook payload", "err", err)
+		return
+	}
+
+	wp.once.Do(wp.start)
+
+	for _, body := range bodies {
+		for _, url := range wp.URLs {
+			wp.pending.Add(1)
+
+			select {
+			case wp.queue <- &webhookDelivery{url: url, body: body}:
+			default:
+				wp.pending.Done()
+				wp.Logger.Error("Failed to queue webhook", "url", url, "queued", len(wp.queue))
+				wp.addDeadLetter(url, body, errWebhookQueueFull, 0)
+			}
+		}
+	}
+}
+
+// Flush waits until queued deliveries succeed or are kept in DeadLetters
+func (wp *WebhookPublisher) Flush() {
+	wp.pending.Wait()
+}
+
+func (wp *WebhookPublisher) start() {
+	size := wp.QueueSize
+	if size <= 0 {
+		size = defaultWebhookQueue
+	}
+
+	wp.queue = make(chan *webhookDelivery, size)
+
+	// one worker keeps the order of events for every URL
+	go func() {
+		for d := range wp.queue {
+			attempts, err := wp.deliver(d.url, d.body)
+			if err != nil {
+				wp.Logger.Error("Failed to deliver webhook", "url", d.url, "attempts", attempts, "err", err)
+				wp.addDeadLetter(d.url, d.body, err.Error(), attempts)
+			}
+
+			wp.pending.Done()
+		}
+	}()
+}
+
+// payloads encodes events into bodies that are not larger than
+// MaxPayloadSize unless a single event is larger than that
+func (wp *WebhookPublisher) payloads(events []*common.SubscriberEvent) ([][]byte, error) {
+	maxSize := wp.MaxPayloadSize
+	if maxSize <= 0 {
+		maxSize = defaultWebhookPayload
+	}
+
+	bodies := make([][]byte, 0, 1)
+
+	for start := 0; start < len(events); {
+		end := len(events)
+
+		body, err := json.Marshal(&WebhookPayload{Events: events[start:end]})
+		if err != nil {
+			return nil, err
+		}
+
+		for len(body) > maxSize && end-start > 1 {
+			end = start + (end-start)/2
+
+			body, err = json.Marshal(&WebhookPayload{Events: events[start:end]})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		bodies = append(bodies, body)
+		start = end
+	}
+
+	return bodies, nil
+}
+
+func (wp *WebhookPublisher) addDeadLetter(url string, body []byte, reason string, attempts int) {
+	if wp.DeadLetters == nil {
+		return
+	}
+
+	if err := wp.DeadLetters.AddDeadLetter(common.NewDeadLetter(url, string(body), reason, attempts)); err != nil {
+		wp.Logger.Error("Failed to store dead letter", "url", url, "err", err)
+	}
+}
+
+// deliver sends body to the url until it succeeds, fails permanently
+// or runs out of attempts and returns the number of attempts made
+func (wp *WebhookPublisher) deliver(url string, body []byte) (int, error) {
+	maxAttempts := wp.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultWebhookAttempts
+	}
+
+	b := &backoff.Backoff{
+		Min:    wp.MinDelay,
+		Max:    wp.MaxDelay,
+		Factor: 2,
+		Jitter: false,
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := wp.send(url, body)
+		if err == nil {
+			return attempt, nil
+		}
+
+		if !retry || attempt >= maxAttempts {
+			return attempt, err
+		}
+
+		time.Sleep(b.Duration())
+	}
+}
+
+// send makes one delivery attempt and reports if it can be retried
+func (wp *WebhookPublisher) send(url string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	req.Header.Set(HeaderWebhookSignature, WebhookSignature(wp.Secret, timestamp, body))
+
+	client := wp.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	// drain the body to reuse the connection
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4*kilobyte))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("Unexpected status code %d", resp.StatusCode)
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func (ar *AdminResource) serveDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		ar.Logger.Warn("Unsupported method for dead letters", "method", r.Method)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	if ar.DeadLetters == nil {
+		http.Error(w, "Dead letters are not configured", http.StatusNotFound)
+		return
+	}
+
+	deadLetters, err := ar.DeadLetters.DeadLetters()
+	if err != nil {
+		ar.Logger.Error("Failed to fetch dead letters", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	if deadLetters == nil {
+		deadLetters = make([]*common.DeadLetter, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(deadLetters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
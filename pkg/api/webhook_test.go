@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+const webhookSecret = "webhook123"
+
+func TestWebhookDelivery(t *testing.T) {
+	var payload WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		signature := WebhookSignature(webhookSecret, r.Header.Get(HeaderWebhookTimestamp), body)
+		if r.Header.Get(HeaderWebhookSignature) != signature {
+			t.Errorf("Unexpected signature. actual=%v expected=%v", r.Header.Get(HeaderWebhookSignature), signature)
+		}
+
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	srv := http.NewServeMux()
+	nr := NewTestNewsResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	nr.Publisher = &WebhookPublisher{URLs: []string{server.URL}, Secret: webhookSecret}
+	nr.AddNewsletters([]string{testNewsletter})
+	nr.Setup(srv)
+
+	data := url.Values{}
+	data.Set(common.ParamNewsletter, testNewsletter)
+	data.Set(common.ParamEmail, testEmail)
+	srv.ServeHTTP(httptest.NewRecorder(), formRequest(data))
+	nr.Publisher.Flush()
+
+	// confirmation_sent is not published
+	if len(payload.Events) != 1 || payload.Events[0].Event != common.EventSubscribe || payload.Events[0].Email != testEmail {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		statuses    []int
+		requests    int
+		deadLetters int
+	}{
+		{[]int{http.StatusOK}, 1, 0},
+		{[]int{http.StatusInternalServerError, http.StatusOK}, 2, 0},
+		{[]int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable}, 3, 1},
+		{[]int{http.StatusBadRequest}, 1, 1},
+	}
+
+	for _, tt := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.statuses[requests])
+			requests++
+		}))
+
+		deadLetters := db.NewDeadLettersMapStore()
+		wp := &WebhookPublisher{
+			URLs:        []string{server.URL},
+			Secret:      webhookSecret,
+			MaxAttempts: 3,
+			MinDelay:    1,
+			MaxDelay:    1,
+			DeadLetters: deadLetters,
+		}
+
+		wp.Publish([]*common.SubscriberEvent{common.NewSubscriberEvent(common.EventUnsubscribe, testNewsletter, testEmail)})
+		wp.Flush()
+		server.Close()
+
+		if requests != tt.requests {
+			t.Errorf("Unexpected number of requests. actual=%v expected=%v", requests, tt.requests)
+		}
+
+		ds, _ := deadLetters.DeadLetters()
+		if len(ds) != tt.deadLetters {
+			t.Fatalf("Unexpected number of dead letters: %v", len(ds))
+		}
+
+		if len(ds) > 0 && (ds[0].URL != server.URL || ds[0].Attempts != tt.requests || ds[0].Payload == "") {
+			t.Errorf("Unexpected dead letter: %+v", ds[0])
+		}
+	}
+}
+
+func TestWebhookPayloadSize(t *testing.T) {
+	const maxSize = 1024
+
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	events := make([]*common.SubscriberEvent, 0)
+	for i := 0; i < 50; i++ {
+		events = append(events, common.NewSubscriberEvent(common.EventImport, testNewsletter, fmt.Sprintf("foo%v@bar.com", i)))
+	}
+
+	wp := &WebhookPublisher{URLs: []string{server.URL}, Secret: webhookSecret, MaxPayloadSize: maxSize}
+	wp.Publish(events)
+	wp.Flush()
+
+	if len(bodies) < 2 {
+		t.Fatalf("Payload was not split. requests=%v", len(bodies))
+	}
+
+	delivered := 0
+	for _, body := range bodies {
+		if len(body) > maxSize {
+			t.Errorf("Payload is too large. size=%v", len(body))
+		}
+
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatal(err)
+		}
+		delivered += len(payload.Events)
+	}
+
+	if delivered != len(events) {
+		t.Errorf("Unexpected number of delivered events. actual=%v expected=%v", delivered, len(events))
+	}
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	deadLetters := db.NewDeadLettersMapStore()
+	wp := &WebhookPublisher{
+		URLs:        []string{server.URL},
+		Secret:      webhookSecret,
+		QueueSize:   1,
+		DeadLetters: deadLetters,
+	}
+
+	// the first delivery blocks the worker, the second one waits in
+	// the queue and the rest do not fit
+	for i := 0; i < 4; i++ {
+		wp.Publish([]*common.SubscriberEvent{common.NewSubscriberEvent(common.EventSubscribe, testNewsletter, testEmail)})
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	wp.Flush()
+
+	ds, _ := deadLetters.DeadLetters()
+	if len(ds) != 2 || ds[0].Error != errWebhookQueueFull || ds[0].Attempts != 0 {
+		t.Errorf("Unexpected dead letters: %+v", ds)
+	}
+}
+
+func TestDeadLettersEndpoint(t *testing.T) {
+	srv := http.NewServeMux()
+	deadLetters := db.NewDeadLettersMapStore()
+	deadLetters.AddDeadLetter(common.NewDeadLetter("http://crm.example.com", "{}", "Unexpected status code 500", 3))
+
+	ar := NewTestAdminResource(db.NewSubscribersMapStore(), db.NewNotificationsMapStore())
+	ar.DeadLetters = deadLetters
+	ar.Setup(srv)
+
+	req, err := http.NewRequest("GET", common.DeadLettersEndpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("any username", apiToken)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code %d", w.Code)
+	}
+
+	var ds []*common.DeadLetter
+	if err := json.NewDecoder(w.Body).Decode(&ds); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ds) != 1 || ds[0].Attempts != 3 {
+		t.Errorf("Unexpected dead letters: %v", ds)
+	}
+}
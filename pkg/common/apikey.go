@@ -0,0 +1,156 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// Roles of API keys, every role includes permissions of the previous ones
+const (
+	RoleReadOnly = "read-only"
+	RoleImport   = "import"
+	RoleDelete   = "delete"
+	RoleAdmin    = "admin"
+)
+
+const apiKeySecretSize = 32
+
+var (
+	roleRanks = map[string]int{
+		RoleReadOnly: 1,
+		RoleImport:   2,
+		RoleDelete:   3,
+		RoleAdmin:    4,
+	}
+
+	errAPIKeyName = errors.New("API key name is empty")
+	errAPIKeyRole = errors.New("Unknown API key role")
+)
+
+// ValidRole checks if the role is known
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// APIKey is a named credential of the admin API. Only the hash of the
+// secret is kept, the token is shown once when the key is created.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Hash is SHA-256 of the secret part of the token
+	Hash string `json:"hash,omitempty"`
+	Role string `json:"role"`
+	// Newsletters limit the key to these newsletters, empty means all
+	Newsletters []string  `json:"newsletters,omitempty"`
+	CreatedAt   JSONTime  `json:"created_at"`
+	ExpiresAt   *JSONTime `json:"expires_at,omitempty"`
+}
+
+// APIKeyRequest is a body of the request to create API key
+type APIKeyRequest struct {
+	Name        string    `json:"name"`
+	Role        string    `json:"role"`
+	Newsletters []string  `json:"newsletters,omitempty"`
+	ExpiresAt   *JSONTime `json:"expires_at,omitempty"`
+}
+
+// NewAPIKey is a created API key together with its token
+type NewAPIKey struct {
+	Key   *APIKey `json:"key"`
+	Token string  `json:"token"`
+}
+
+// Validate checks required fields of the request
+func (kr *APIKeyRequest) Validate() error {
+	if strings.TrimSpace(kr.Name) == "" {
+		return errAPIKeyName
+	}
+
+	if !ValidRole(kr.Role) {
+		return errAPIKeyRole
+	}
+
+	return nil
+}
+
+func apiKeyHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey creates the key for the request and returns it with the
+// token in the form of id.secret
+func GenerateAPIKey(kr *APIKeyRequest) (*APIKey, string, error) {
+	b := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	k := &APIKey{
+		ID:          xid.New().String(),
+		Name:        kr.Name,
+		Hash:        apiKeyHash(secret),
+		Role:        kr.Role,
+		Newsletters: kr.Newsletters,
+		CreatedAt:   JsonTimeNow(),
+		ExpiresAt:   kr.ExpiresAt,
+	}
+
+	return k, k.ID + "." + secret, nil
+}
+
+// ParseAPIToken splits the token into the key ID and the secret
+func ParseAPIToken(token string) (string, string, bool) {
+	i := strings.Index(token, ".")
+	if i <= 0 || i == len(token)-1 {
+		return "", "", false
+	}
+
+	return token[:i], token[i+1:], true
+}
+
+// Verify checks the secret in constant time
+func (k *APIKey) Verify(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.Hash), []byte(apiKeyHash(secret))) == 1
+}
+
+// Expired checks if the key cannot be used at the time
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(k.ExpiresAt.Time())
+}
+
+// Allows checks if the role of the key includes the role
+func (k *APIKey) Allows(role string) bool {
+	rank, ok := roleRanks[k.Role]
+	return ok && rank >= roleRanks[role]
+}
+
+// Scoped checks if the key is limited to some newsletters
+func (k *APIKey) Scoped() bool {
+	return len(k.Newsletters) > 0
+}
+
+// AllowsNewsletter checks if the key can access the newsletter
+func (k *APIKey) AllowsNewsletter(newsletter string) bool {
+	if !k.Scoped() {
+		return true
+	}
+
+	for _, n := range k.Newsletters {
+		if n == newsletter {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	k, token, err := GenerateAPIKey(&APIKeyRequest{Name: "test", Role: RoleImport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, secret, ok := ParseAPIToken(token)
+	if !ok || id != k.ID {
+		t.Fatalf("Failed to parse token. token=%v id=%v", token, k.ID)
+	}
+
+	if k.Hash == secret || !k.Verify(secret) {
+		t.Errorf("Secret is not verified")
+	}
+
+	if k.Verify(secret + "a") {
+		t.Errorf("Wrong secret is verified")
+	}
+
+	other, otherToken, _ := GenerateAPIKey(&APIKeyRequest{Name: "test", Role: RoleImport})
+	if other.ID == k.ID || otherToken == token {
+		t.Errorf("Keys are not unique")
+	}
+}
+
+func TestParseAPIToken(t *testing.T) {
+	tests := []struct {
+		token string
+		ok    bool
+	}{
+		{"id.secret", true},
+		{"id.sec.ret", true},
+		{"idsecret", false},
+		{".secret", false},
+		{"id.", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if _, _, ok := ParseAPIToken(tt.token); ok != tt.ok {
+			t.Errorf("Unexpected result. token=%v expected=%v", tt.token, tt.ok)
+		}
+	}
+}
+
+func TestAPIKeyAllows(t *testing.T) {
+	tests := []struct {
+		key   string
+		role  string
+		allow bool
+	}{
+		{RoleReadOnly, RoleReadOnly, true},
+		{RoleReadOnly, RoleImport, false},
+		{RoleImport, RoleReadOnly, true},
+		{RoleImport, RoleDelete, false},
+		{RoleDelete, RoleImport, true},
+		{RoleDelete, RoleAdmin, false},
+		{RoleAdmin, RoleDelete, true},
+		{"unknown", RoleReadOnly, false},
+	}
+
+	for _, tt := range tests {
+		k := &APIKey{Role: tt.key}
+		if k.Allows(tt.role) != tt.allow {
+			t.Errorf("Unexpected result. key=%v role=%v expected=%v", tt.key, tt.role, tt.allow)
+		}
+	}
+}
+
+func TestAPIKeyNewsletters(t *testing.T) {
+	k := &APIKey{Role: RoleReadOnly}
+	if k.Scoped() || !k.AllowsNewsletter("a") {
+		t.Errorf("Key without newsletters is limited")
+	}
+
+	k.Newsletters = []string{"a", "b"}
+	if !k.Scoped() || !k.AllowsNewsletter("b") || k.AllowsNewsletter("c") {
+		t.Errorf("Key is not limited to its newsletters")
+	}
+}
+
+func TestAPIKeyExpired(t *testing.T) {
+	now := time.Now()
+	k := &APIKey{Role: RoleReadOnly}
+	if k.Expired(now) {
+		t.Errorf("Key without expiration is expired")
+	}
+
+	expiresAt := JSONTime(now.Add(time.Hour))
+	k.ExpiresAt = &expiresAt
+	if k.Expired(now) || !k.Expired(now.Add(time.Hour)) {
+		t.Errorf("Unexpected expiration. expires_at=%v", expiresAt)
+	}
+}
+
+func TestAPIKeyRequestValidate(t *testing.T) {
+	if err := (&APIKeyRequest{Name: " ", Role: RoleAdmin}).Validate(); err == nil {
+		t.Errorf("Empty name is valid")
+	}
+
+	if err := (&APIKeyRequest{Name: "test", Role: "owner"}).Validate(); err == nil {
+		t.Errorf("Unknown role is valid")
+	}
+
+	if err := (&APIKeyRequest{Name: "test", Role: RoleDelete}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
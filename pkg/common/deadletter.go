@@ -0,0 +1,25 @@
+package common
+
+import "github.com/rs/xid"
+
+// DeadLetter is a webhook delivery that failed after all retries
+type DeadLetter struct {
+	ID       string   `json:"id"`
+	URL      string   `json:"url"`
+	Payload  string   `json:"payload"`
+	Error    string   `json:"error"`
+	Attempts int      `json:"attempts"`
+	FailedAt JSONTime `json:"failed_at"`
+}
+
+// NewDeadLetter returns failed delivery with new ID
+func NewDeadLetter(url, payload, err string, attempts int) *DeadLetter {
+	return &DeadLetter{
+		ID:       xid.New().String(),
+		URL:      url,
+		Payload:  payload,
+		Error:    err,
+		Attempts: attempts,
+		FailedAt: JsonTimeNow(),
+	}
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+var errInvalidEmail = errors.New("Email must contain local part and domain")
+
+// emailProvider describes addressing rules of well-known mailbox providers
+type emailProvider struct {
+	// domain is used instead of aliases like googlemail.com
+	domain string
+	// dots in the local part are ignored by the provider
+	dots bool
+	// "+tag" suffix of the local part is delivered to the same mailbox
+	tags bool
+}
+
+var emailProviders = map[string]*emailProvider{
+	"gmail.com":      {domain: "gmail.com", dots: true, tags: true},
+	"googlemail.com": {domain: "gmail.com", dots: true, tags: true},
+	"outlook.com":    {tags: true},
+	"hotmail.com":    {tags: true},
+	"live.com":       {tags: true},
+	"icloud.com":     {tags: true},
+	"me.com":         {tags: true},
+	"fastmail.com":   {tags: true},
+	"protonmail.com": {tags: true},
+	"proton.me":      {tags: true},
+}
+
+// EmailNormalizer converts addresses to the canonical form that is used
+// as a key of subscribers. The address is trimmed, the domain is lowercased
+// and the internationalized domain is converted to punycode. The local part
+// is case sensitive by RFC 5321, so lowercasing it and provider rules are
+// optional because the canonical address is also the one emails are sent to.
+type EmailNormalizer struct {
+	// LowercaseLocal lowercases the local part of all addresses
+	LowercaseLocal bool
+	// GmailDots removes dots from the local part of Gmail addresses
+	GmailDots bool
+	// PlusTags removes "+tag" suffix from the local part for providers
+	// that deliver such addresses to the same mailbox
+	PlusTags bool
+}
+
+// Normalize returns canonical form of the email. Methods of nil
+// normalizer apply only the default rules.
+func (en *EmailNormalizer) Normalize(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	i := strings.LastIndex(email, "@")
+	if i <= 0 || i == len(email)-1 {
+		return "", errInvalidEmail
+	}
+
+	local, domain := email[:i], strings.TrimSuffix(strings.ToLower(email[i+1:]), ".")
+
+	if en != nil && en.LowercaseLocal {
+		local = strings.ToLower(local)
+	}
+
+	if !isASCII(domain) {
+		var err error
+		if domain, err = domainToASCII(domain); err != nil {
+			return "", err
+		}
+	}
+
+	if p, ok := emailProviders[domain]; ok && en != nil {
+		if en.PlusTags && p.tags {
+			if j := strings.Index(local, "+"); j > 0 {
+				local = local[:j]
+			}
+		}
+
+		if en.GmailDots && p.dots {
+			local = strings.Replace(local, ".", "", -1)
+		}
+
+		if en.GmailDots && p.domain != "" {
+			domain = p.domain
+		}
+	}
+
+	return local + "@" + domain, nil
+}
+
+// NormalizeEmail returns canonical form of the email with default rules
+func NormalizeEmail(email string) (string, error) {
+	return (*EmailNormalizer)(nil).Normalize(email)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
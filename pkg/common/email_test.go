@@ -0,0 +1,69 @@
+package common
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email    string
+		expected string
+	}{
+		{"foo@bar.com", "foo@bar.com"},
+		{" Foo@Example.COM ", "Foo@example.com"},
+		{"foo@example.com.", "foo@example.com"},
+		{"foo@bücher.de", "foo@xn--bcher-kva.de"},
+		{"foo@BÜCHER.de", "foo@xn--bcher-kva.de"},
+		{"foo@mail.münchen.de", "foo@mail.xn--mnchen-3ya.de"},
+		{"foo@例え.テスト", "foo@xn--r8jz45g.xn--zckzah"},
+		{"foo@пример。рф", "foo@xn--e1afmkfd.xn--p1ai"},
+		{"Foo.Bar+news@gmail.com", "Foo.Bar+news@gmail.com"},
+	}
+
+	for _, tt := range tests {
+		actual, err := NormalizeEmail(tt.email)
+		if err != nil {
+			t.Errorf("Failed to normalize. email=%v err=%v", tt.email, err)
+			continue
+		}
+
+		if actual != tt.expected {
+			t.Errorf("Unexpected email. expected=%v actual=%v", tt.expected, actual)
+		}
+	}
+}
+
+func TestNormalizeInvalidEmail(t *testing.T) {
+	for _, email := range []string{"", "foo", "@bar.com", "foo@"} {
+		if _, err := NormalizeEmail(email); err == nil {
+			t.Errorf("Invalid email is normalized. email=%v", email)
+		}
+	}
+}
+
+func TestNormalizeProviderRules(t *testing.T) {
+	en := &EmailNormalizer{LowercaseLocal: true, GmailDots: true, PlusTags: true}
+
+	tests := []struct {
+		email    string
+		expected string
+	}{
+		{"Foo.Bar+news@gmail.com", "foobar@gmail.com"},
+		{"foo.bar@googlemail.com", "foobar@gmail.com"},
+		{"foo.bar+news@outlook.com", "foo.bar@outlook.com"},
+		{" Foo@Example.COM ", "foo@example.com"},
+		// unknown providers can treat tags and dots as different mailboxes
+		{"foo.bar+news@example.com", "foo.bar+news@example.com"},
+		{"+news@gmail.com", "+news@gmail.com"},
+	}
+
+	for _, tt := range tests {
+		actual, err := en.Normalize(tt.email)
+		if err != nil {
+			t.Errorf("Failed to normalize. email=%v err=%v", tt.email, err)
+			continue
+		}
+
+		if actual != tt.expected {
+			t.Errorf("Unexpected email. expected=%v actual=%v", tt.expected, actual)
+		}
+	}
+}
@@ -6,8 +6,40 @@ const (
 	UnsubscribeEndpoint = "/unsubscribe"
 	ComplaintsEndpoint  = "/complaints"
 	ConfirmEndpoint     = "/confirm"
+	ResendEndpoint      = "/confirm/resend"
+	StampEndpoint       = "/stamp"
+	PreferencesEndpoint = "/preferences"
+	EventsEndpoint      = "/events"
+	DataEndpoint        = "/data"
+	EraseEndpoint       = "/data/erase"
+	DeadLettersEndpoint = "/deadletters"
+	HealthEndpoint      = "/healthz"
+	ReadyEndpoint       = "/readyz"
+	MetricsEndpoint     = "/metrics"
+	StatsEndpoint       = "/stats"
+	NewslettersEndpoint = "/newsletters"
+	APIKeysEndpoint     = "/apikeys"
 	ParamNewsletter     = "newsletter"
 	ParamToken          = "token"
 	ParamEmail          = "email"
 	ParamName           = "name"
+	ParamLocale         = "locale"
+	ParamFormat         = "format"
+	ParamExact          = "exact"
+	ParamLimit          = "limit"
+	ParamCursor         = "cursor"
+	ParamStatus         = "status"
+	ParamCreatedAfter   = "created_after"
+	ParamCreatedBefore  = "created_before"
+	ParamFrom           = "from"
+	ParamTo             = "to"
+	FormatJSON          = "json"
+	ParamSubscribe      = "subscribe"
+	ParamUnsubscribe    = "unsubscribe"
+	ParamCascade        = "cascade"
+	// HeaderNextCursor is set by paged endpoints if there are more items
+	HeaderNextCursor = "X-Next-Cursor"
+	// RFC 8058 one-click unsubscribe
+	ParamListUnsubscribe    = "List-Unsubscribe"
+	ListUnsubscribeOneClick = "One-Click"
 )
@@ -0,0 +1,22 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Erasure is a record of the address erased on request of its owner.
+// Only the salted hash of the address is kept.
+type Erasure struct {
+	Hash     string   `json:"hash"`
+	ErasedAt JSONTime `json:"erased_at"`
+}
+
+// EmailHash returns salted hash of the email that does not depend on case
+func EmailHash(salt, email string) string {
+	m := hmac.New(sha256.New, []byte(salt))
+	m.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,19 @@
+package common
+
+import "testing"
+
+func TestEmailHash(t *testing.T) {
+	h := EmailHash("salt", "foo@bar.com")
+
+	if h == "" || h == "foo@bar.com" {
+		t.Fatalf("Unexpected hash: %v", h)
+	}
+
+	if EmailHash("salt", " Foo@Bar.com ") != h {
+		t.Errorf("Hash depends on case of the email")
+	}
+
+	if EmailHash("other", "foo@bar.com") == h {
+		t.Errorf("Hash does not depend on salt")
+	}
+}
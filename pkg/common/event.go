@@ -0,0 +1,64 @@
+package common
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// types of the subscription lifecycle events
+const (
+	EventSubscribe        = "subscribe"
+	EventConfirmationSent = "confirmation_sent"
+	EventConfirm          = "confirm"
+	EventUnsubscribe      = "unsubscribe"
+	EventImport           = "import"
+	EventDelete           = "delete"
+)
+
+// sources of the subscription lifecycle events
+const (
+	SourceForm        = "form"
+	SourceEmail       = "email"
+	SourceOneClick    = "one-click"
+	SourcePreferences = "preferences"
+	SourceResend      = "resend"
+	SourceReminder    = "reminder"
+	SourcePurge       = "purge"
+	SourceAdmin       = "admin"
+)
+
+// SubscriberEvent is an immutable record of the change in subscription
+// that is kept as a proof of consent
+type SubscriberEvent struct {
+	Email string `json:"email"`
+	// ID is unique and sortable by creation time
+	ID             string   `json:"id"`
+	Newsletter     string   `json:"newsletter"`
+	Event          string   `json:"event"`
+	CreatedAt      JSONTime `json:"created_at"`
+	IP             string   `json:"ip,omitempty"`
+	UserAgent      string   `json:"user_agent,omitempty"`
+	ConsentVersion string   `json:"consent_version,omitempty"`
+	Source         string   `json:"source,omitempty"`
+}
+
+// NewSubscriberEvent returns event with new ID created now
+func NewSubscriberEvent(event, newsletter, email string) *SubscriberEvent {
+	return &SubscriberEvent{
+		Email:      email,
+		ID:         xid.New().String(),
+		Newsletter: newsletter,
+		Event:      event,
+		CreatedAt:  JsonTimeNow(),
+	}
+}
+
+// EventIDAt returns ID that is sorted before IDs of all events created
+// at the time or later, it is used to query events by time
+func EventIDAt(t time.Time) string {
+	var id xid.ID
+	binary.BigEndian.PutUint32(id[:4], uint32(t.Unix()))
+	return id.String()
+}
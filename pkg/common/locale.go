@@ -0,0 +1,70 @@
+package common
+
+import "strings"
+
+// DefaultLocale is used when subscriber did not provide any locale
+const DefaultLocale = "en"
+
+const maxLocaleLength = 35
+
+// NormalizeLocale converts language tag like "de_AT" to "de-at" form.
+// Empty string is returned for malformed tags.
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	locale = strings.Replace(locale, "_", "-", -1)
+
+	if locale == "" || len(locale) > maxLocaleLength {
+		return ""
+	}
+
+	for _, part := range strings.Split(locale, "-") {
+		if part == "" {
+			return ""
+		}
+
+		for _, c := range part {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+				return ""
+			}
+		}
+	}
+
+	return locale
+}
+
+// LocaleFallbacks returns locales to look for in the order of preference:
+// the locale itself, its language and the default locale
+func LocaleFallbacks(locale, defaultLocale string) []string {
+	fallbacks := make([]string, 0, 3)
+	add := func(l string) {
+		for _, f := range fallbacks {
+			if f == l {
+				return
+			}
+		}
+		fallbacks = append(fallbacks, l)
+	}
+
+	if locale = NormalizeLocale(locale); locale != "" {
+		add(locale)
+
+		if i := strings.Index(locale, "-"); i > 0 {
+			add(locale[:i])
+		}
+	}
+
+	if defaultLocale = NormalizeLocale(defaultLocale); defaultLocale != "" {
+		add(defaultLocale)
+	}
+
+	return fallbacks
+}
+
+// MatchLocale checks if locale is the same as or more specific than filter,
+// e.g. "de-at" matches "de"
+func MatchLocale(locale, filter string) bool {
+	locale = NormalizeLocale(locale)
+	filter = NormalizeLocale(filter)
+
+	return locale != "" && (locale == filter || strings.HasPrefix(locale, filter+"-"))
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		locale   string
+		expected string
+	}{
+		{"uk", "uk"},
+		{" de_AT ", "de-at"},
+		{"en-US", "en-us"},
+		{"", ""},
+		{"de--at", ""},
+		{"<script>", ""},
+		{"a-very-long-locale-tag-that-is-not-valid", ""},
+	}
+
+	for _, tt := range tests {
+		if actual := NormalizeLocale(tt.locale); actual != tt.expected {
+			t.Errorf("Unexpected locale. value=%q actual=%q expected=%q", tt.locale, actual, tt.expected)
+		}
+	}
+}
+
+func TestLocaleFallbacks(t *testing.T) {
+	tests := []struct {
+		locale   string
+		expected []string
+	}{
+		{"de-AT", []string{"de-at", "de", "en"}},
+		{"uk", []string{"uk", "en"}},
+		{"en", []string{"en"}},
+		{"", []string{"en"}},
+	}
+
+	for _, tt := range tests {
+		if actual := LocaleFallbacks(tt.locale, DefaultLocale); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Unexpected fallbacks. value=%q actual=%v expected=%v", tt.locale, actual, tt.expected)
+		}
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	tests := []struct {
+		locale   string
+		filter   string
+		expected bool
+	}{
+		{"de", "de", true},
+		{"de-at", "de", true},
+		{"de", "de-at", false},
+		{"dell", "de", false},
+		{"", "de", false},
+	}
+
+	for _, tt := range tests {
+		if actual := MatchLocale(tt.locale, tt.filter); actual != tt.expected {
+			t.Errorf("Unexpected match. locale=%q filter=%q actual=%v", tt.locale, tt.filter, actual)
+		}
+	}
+}
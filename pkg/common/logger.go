@@ -0,0 +1,212 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// log levels
+const (
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// modes of logging email addresses
+const (
+	// EmailsPlain logs addresses as is (default)
+	EmailsPlain = "plain"
+	// EmailsRedact keeps only the domain of the address
+	EmailsRedact = "redact"
+	// EmailsHash replaces the address with its salted hash
+	EmailsHash = "hash"
+)
+
+// emailKeys are the fields that contain email addresses
+var emailKeys = map[string]bool{
+	"email": true,
+	"from":  true,
+}
+
+// tokenKeys are the fields that contain signed tokens
+var tokenKeys = map[string]bool{
+	"token": true,
+}
+
+// pathKeys are the fields that contain URL paths with email segments
+var pathKeys = map[string]bool{
+	"path": true,
+}
+
+// Logger writes JSON lines with the message and key/value pairs.
+// Loggers created with With share the output. Methods of nil Logger
+// use DefaultLogger.
+type Logger struct {
+	out    io.Writer
+	mu     *sync.Mutex
+	fields []interface{}
+	// Emails is one of EmailsPlain, EmailsRedact or EmailsHash
+	Emails string
+	// Salt of the email hashes
+	Salt string
+}
+
+// DefaultLogger writes to stderr without redaction
+var DefaultLogger = NewLogger(os.Stderr)
+
+// NewLogger creates logger that writes to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{
+		out:    w,
+		mu:     &sync.Mutex{},
+		Emails: EmailsPlain,
+	}
+}
+
+// With returns logger that adds key/value pairs to every line
+func (l *Logger) With(kv ...interface{}) *Logger {
+	if l == nil {
+		l = DefaultLogger
+	}
+
+	c := *l
+	c.fields = make([]interface{}, 0, len(l.fields)+len(kv))
+	c.fields = append(c.fields, l.fields...)
+	c.fields = append(c.fields, kv...)
+
+	return &c
+}
+
+// Info logs normal operation
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
+}
+
+// Warn logs rejected input and skipped items
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
+}
+
+// Error logs failures
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
+}
+
+// email formats the address according to the Emails mode
+func (l *Logger) email(v interface{}) interface{} {
+	email, ok := v.(string)
+	if !ok || email == "" {
+		return v
+	}
+
+	switch l.Emails {
+	case EmailsRedact:
+		if i := strings.LastIndex(email, "@"); i >= 0 {
+			return "***" + email[i:]
+		}
+		return "***"
+	case EmailsHash:
+		return EmailHash(l.Salt, email)
+	default:
+		return email
+	}
+}
+
+// TokenDigest returns short hash of the token that identifies it in logs
+// without making the link usable
+func TokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:6])
+}
+
+// path formats email segments of the path according to the Emails mode
+func (l *Logger) path(v interface{}) interface{} {
+	path, ok := v.(string)
+	if !ok {
+		return v
+	}
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if u, err := url.PathUnescape(s); err == nil && strings.Contains(u, "@") {
+			segments[i] = fmt.Sprint(l.email(u))
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (l *Logger) value(key string, v interface{}) []byte {
+	switch {
+	case emailKeys[key]:
+		v = l.email(v)
+	case pathKeys[key]:
+		v = l.path(v)
+	case tokenKeys[key]:
+		if token, ok := v.(string); ok && token != "" {
+			v = TokenDigest(token)
+		}
+	}
+
+	switch t := v.(type) {
+	case error:
+		v = t.Error()
+	case JSONTime:
+		v = t.Time()
+	case time.Time:
+		// marshaled as RFC3339
+	case fmt.Stringer:
+		v = t.String()
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+
+	return data
+}
+
+func (l *Logger) log(level, msg string, kv []interface{}) {
+	if l == nil {
+		l = DefaultLogger
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	b.Write(l.value("time", time.Now().UTC().Format(time.RFC3339Nano)))
+	b.WriteString(`,"level":`)
+	b.Write(l.value("level", level))
+	b.WriteString(`,"msg":`)
+	b.Write(l.value("msg", msg))
+
+	fields := append(append([]interface{}{}, l.fields...), kv...)
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		var v interface{}
+		if i+1 < len(fields) {
+			v = fields[i+1]
+		}
+
+		b.WriteString(",")
+		b.Write(l.value("", key))
+		b.WriteString(":")
+		b.Write(l.value(key, v))
+	}
+	b.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// logging must not fail the request
+	_, _ = l.out.Write(b.Bytes())
+}
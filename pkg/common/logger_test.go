@@ -0,0 +1,140 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func logLine(t *testing.T, b *bytes.Buffer) map[string]interface{} {
+	line := make(map[string]interface{})
+	if err := json.Unmarshal(b.Bytes(), &line); err != nil {
+		t.Fatalf("Failed to parse log line. line=%v err=%v", b.String(), err)
+	}
+	b.Reset()
+
+	return line
+}
+
+func TestLoggerFields(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLogger(&b).With("request_id", "abc")
+
+	l.Error("Failed to add subscription", "newsletter", "Listing1", "count", 2, "err", errors.New("boom"))
+
+	line := logLine(t, &b)
+	expected := map[string]interface{}{
+		"level":      LevelError,
+		"msg":        "Failed to add subscription",
+		"request_id": "abc",
+		"newsletter": "Listing1",
+		"count":      float64(2),
+		"err":        "boom",
+	}
+
+	for k, v := range expected {
+		if line[k] != v {
+			t.Errorf("Unexpected field. key=%v expected=%v actual=%v", k, v, line[k])
+		}
+	}
+
+	if _, ok := line["time"]; !ok {
+		t.Errorf("Time is missing")
+	}
+}
+
+func TestLoggerDoesNotChangeParent(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLogger(&b)
+	_ = l.With("request_id", "abc")
+
+	l.Info("Started")
+
+	if _, ok := logLine(t, &b)["request_id"]; ok {
+		t.Errorf("Fields leaked to the parent logger")
+	}
+}
+
+func TestLoggerEmails(t *testing.T) {
+	const email = "foo@bar.com"
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{EmailsPlain, email},
+		{EmailsRedact, "***@bar.com"},
+		{EmailsHash, EmailHash("salt", email)},
+	}
+
+	for _, tt := range tests {
+		var b bytes.Buffer
+		l := NewLogger(&b)
+		l.Emails = tt.mode
+		l.Salt = "salt"
+
+		l.Info("Added subscription", "email", email, "name", "foo@bar.com is not an email field")
+
+		line := logLine(t, &b)
+		if line["email"] != tt.expected {
+			t.Errorf("Unexpected email. mode=%v expected=%v actual=%v", tt.mode, tt.expected, line["email"])
+		}
+	}
+}
+
+func TestLoggerPaths(t *testing.T) {
+	tests := []struct {
+		mode     string
+		path     string
+		expected string
+	}{
+		{EmailsPlain, "/subscribers/Listing1/foo@bar.com", "/subscribers/Listing1/foo@bar.com"},
+		{EmailsRedact, "/subscribers/Listing1/foo@bar.com", "/subscribers/Listing1/***@bar.com"},
+		{EmailsRedact, "/subscribers/Listing1/foo%40bar.com", "/subscribers/Listing1/***@bar.com"},
+		{EmailsHash, "/subscribers/Listing1/foo@bar.com", "/subscribers/Listing1/" + EmailHash("salt", "foo@bar.com")},
+		{EmailsRedact, "/subscribers", "/subscribers"},
+	}
+
+	for _, tt := range tests {
+		var b bytes.Buffer
+		l := NewLogger(&b)
+		l.Emails = tt.mode
+		l.Salt = "salt"
+
+		l.Warn("API key is not allowed", "path", tt.path)
+
+		line := logLine(t, &b)
+		if line["path"] != tt.expected {
+			t.Errorf("Unexpected path. mode=%v expected=%v actual=%v", tt.mode, tt.expected, line["path"])
+		}
+	}
+}
+
+func TestLoggerTokens(t *testing.T) {
+	const token = "v1.signature.payload"
+
+	var b bytes.Buffer
+	l := NewLogger(&b)
+
+	l.Warn("Failed to unsign token", "token", token)
+
+	line := logLine(t, &b)
+	if line["token"] != TokenDigest(token) {
+		t.Errorf("Token is not hashed. actual=%v", line["token"])
+	}
+}
+
+func TestNilLogger(t *testing.T) {
+	var b bytes.Buffer
+	saved := DefaultLogger
+	DefaultLogger = NewLogger(&b)
+	defer func() { DefaultLogger = saved }()
+
+	var l *Logger
+	l.Warn("Skipped")
+
+	if logLine(t, &b)["level"] != LevelWarn {
+		t.Errorf("Nil logger does not use default logger")
+	}
+}
@@ -0,0 +1,112 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// OptInDouble requires confirmation of the email (default)
+	OptInDouble = "double"
+	// OptInSingle confirms subscriptions right away without sending emails
+	OptInSingle = "single"
+)
+
+var (
+	errNewsletterName = errors.New("Newsletter name is empty")
+	errOptInPolicy    = errors.New("Unknown opt-in policy")
+)
+
+// NewsletterConfig keeps settings of a single newsletter. Empty fields
+// fall back to the defaults of the resource or the mailer.
+type NewsletterConfig struct {
+	// Name is the identifier used in requests and in the subscribers table
+	Name                   string `json:"name" yaml:"name"`
+	DisplayName            string `json:"display_name,omitempty" yaml:"display_name,omitempty"`
+	SubscribeRedirectURL   string `json:"subscribe_redirect_url,omitempty" yaml:"subscribe_redirect_url,omitempty"`
+	UnsubscribeRedirectURL string `json:"unsubscribe_redirect_url,omitempty" yaml:"unsubscribe_redirect_url,omitempty"`
+	ConfirmRedirectURL     string `json:"confirm_redirect_url,omitempty" yaml:"confirm_redirect_url,omitempty"`
+	ConfirmURL             string `json:"confirm_url,omitempty" yaml:"confirm_url,omitempty"`
+	PreferencesURL         string `json:"preferences_url,omitempty" yaml:"preferences_url,omitempty"`
+	SenderEmail            string `json:"sender_email,omitempty" yaml:"sender_email,omitempty"`
+	SenderName             string `json:"sender_name,omitempty" yaml:"sender_name,omitempty"`
+	ReplyTo                string `json:"reply_to,omitempty" yaml:"reply_to,omitempty"`
+	// Templates is the name of confirmation email template set
+	Templates string `json:"templates,omitempty" yaml:"templates,omitempty"`
+	// OptIn is either "double" or "single"
+	OptIn string `json:"opt_in,omitempty" yaml:"opt_in,omitempty"`
+	// ConsentVersion identifies the wording of the subscribe form
+	ConsentVersion string `json:"consent_version,omitempty" yaml:"consent_version,omitempty"`
+	// Archived newsletters keep their subscribers but do not accept new ones
+	Archived bool `json:"archived,omitempty" yaml:"archived,omitempty"`
+}
+
+// Title returns the name of the newsletter shown to readers
+func (nc *NewsletterConfig) Title() string {
+	if nc.DisplayName != "" {
+		return nc.DisplayName
+	}
+
+	return nc.Name
+}
+
+// DoubleOptIn checks if subscriptions have to be confirmed via email
+func (nc *NewsletterConfig) DoubleOptIn() bool {
+	return nc.OptIn != OptInSingle
+}
+
+// Validate checks required fields of the config
+func (nc *NewsletterConfig) Validate() error {
+	if strings.TrimSpace(nc.Name) == "" {
+		return errNewsletterName
+	}
+
+	switch nc.OptIn {
+	case "", OptInDouble, OptInSingle:
+		return nil
+	default:
+		return errOptInPolicy
+	}
+}
+
+// ParseNewsletterConfigs parses list of configs in JSON or YAML (isYAML) format
+func ParseNewsletterConfigs(data []byte, isYAML bool) ([]*NewsletterConfig, error) {
+	var configs []*NewsletterConfig
+
+	var err error
+	if isYAML {
+		err = yaml.Unmarshal(data, &configs)
+	} else {
+		err = json.Unmarshal(data, &configs)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range configs {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return configs, nil
+}
+
+// LoadNewsletterConfigs reads configs from JSON or YAML file
+// depending on the file extension
+func LoadNewsletterConfigs(path string) ([]*NewsletterConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	return ParseNewsletterConfigs(data, ext == ".yaml" || ext == ".yml")
+}
@@ -0,0 +1,60 @@
+package common
+
+import "testing"
+
+const newslettersJSON = `[
+  {"name": "weekly", "display_name": "Weekly digest", "confirm_redirect_url": "https://example.com/weekly"},
+  {"name": "news", "opt_in": "single"}
+]`
+
+const newslettersYAML = `
+- name: weekly
+  display_name: Weekly digest
+  confirm_redirect_url: https://example.com/weekly
+- name: news
+  opt_in: single
+`
+
+func TestParseNewsletterConfigs(t *testing.T) {
+	tests := []struct {
+		data   string
+		isYAML bool
+	}{
+		{newslettersJSON, false},
+		{newslettersYAML, true},
+	}
+
+	for _, tt := range tests {
+		configs, err := ParseNewsletterConfigs([]byte(tt.data), tt.isYAML)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(configs) != 2 {
+			t.Fatalf("Unexpected number of configs: %v", len(configs))
+		}
+
+		weekly, news := configs[0], configs[1]
+		if weekly.Title() != "Weekly digest" || weekly.ConfirmRedirectURL != "https://example.com/weekly" || !weekly.DoubleOptIn() {
+			t.Errorf("Unexpected config: %+v", weekly)
+		}
+
+		if news.Title() != "news" || news.DoubleOptIn() {
+			t.Errorf("Unexpected config: %+v", news)
+		}
+	}
+}
+
+func TestParseInvalidNewsletterConfigs(t *testing.T) {
+	tests := []string{
+		`[{"display_name": "No name"}]`,
+		`[{"name": "weekly", "opt_in": "triple"}]`,
+		`{"name": "weekly"}`,
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseNewsletterConfigs([]byte(tt), false); err == nil {
+			t.Errorf("Invalid config is accepted: %v", tt)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// parameters of punycode from RFC 3492
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+	// punyPrefix marks labels encoded with punycode
+	punyPrefix = "xn--"
+)
+
+var errInvalidDomain = errors.New("Domain is not a valid internationalized name")
+
+// domainToASCII converts labels of lowercased internationalized domain
+// to punycode, ASCII labels are kept as is
+func domainToASCII(domain string) (string, error) {
+	// ideographic full stops separate labels as well
+	domain = strings.NewReplacer("。", ".", "．", ".", "｡", ".").Replace(domain)
+
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+
+		if !utf8.ValidString(label) {
+			return "", errInvalidDomain
+		}
+
+		labels[i] = punyPrefix + punycode(label)
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// punycode encodes the label as described in RFC 3492
+func punycode(label string) string {
+	runes := []rune(label)
+	var b strings.Builder
+
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		}
+	}
+
+	basic := b.Len()
+	handled := basic
+	if basic > 0 {
+		b.WriteByte('-')
+	}
+
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for handled < len(runes) {
+		// the smallest code point that is not encoded yet
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+
+		delta += int(m-n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+
+				if q < t {
+					break
+				}
+
+				b.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+
+			b.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+
+		delta++
+		n++
+	}
+
+	return b.String()
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+
+	return byte('0' + d - 26)
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+
+	delta += delta / points
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
@@ -0,0 +1,161 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Statuses of subscribers used to filter subscribers
+const (
+	StatusPending      = "pending"
+	StatusConfirmed    = "confirmed"
+	StatusUnsubscribed = "unsubscribed"
+)
+
+var errInvalidCursor = errors.New("Cursor is invalid")
+
+// SubscribersQuery describes one page of subscribers of the newsletter
+type SubscribersQuery struct {
+	Newsletter string
+	// Status is one of StatusPending, StatusConfirmed and StatusUnsubscribed,
+	// empty status matches all subscribers
+	Status string
+	// CreatedAfter and CreatedBefore are ignored if zero
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Limit is the maximum number of subscribers in the page, all
+	// subscribers are returned if it is not positive
+	Limit int
+	// MaxEvaluated is the maximum number of stored subscribers checked
+	// by filters for one page if Limit is positive. The page can be
+	// returned with fewer subscribers than Limit and the cursor then.
+	MaxEvaluated int
+	// Cursor is returned with the previous page
+	Cursor string
+}
+
+// SubscribersPage is the result of SubscribersQuery
+type SubscribersPage struct {
+	Subscribers []*Subscriber
+	// Cursor of the next page, empty for the last page
+	Cursor string
+}
+
+// ValidStatus checks if subscribers can be filtered by the status
+func ValidStatus(status string) bool {
+	switch status {
+	case "", StatusPending, StatusConfirmed, StatusUnsubscribed:
+		return true
+	}
+
+	return false
+}
+
+// EncodeCursor returns opaque cursor of the page that starts after the key
+func EncodeCursor(key *SubscriberKey) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// StartKey returns the key after which the page starts or nil for the
+// first page. Cursors of other newsletters are not valid.
+func (q *SubscribersQuery) StartKey() (*SubscriberKey, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	key := &SubscriberKey{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	if key.Newsletter != q.Newsletter || key.Email == "" {
+		return nil, errInvalidCursor
+	}
+
+	return key, nil
+}
+
+// Match checks if the subscriber passes filters of the query
+func (q *SubscribersQuery) Match(s *Subscriber) bool {
+	if s.Newsletter != q.Newsletter {
+		return false
+	}
+
+	switch q.Status {
+	case StatusPending:
+		if !s.pending() {
+			return false
+		}
+	case StatusConfirmed:
+		if !s.active() {
+			return false
+		}
+	case StatusUnsubscribed:
+		if !s.Unsubscribed() {
+			return false
+		}
+	}
+
+	if !q.CreatedAfter.IsZero() && !s.CreatedAt.Time().After(q.CreatedAfter) {
+		return false
+	}
+
+	if !q.CreatedBefore.IsZero() && !s.CreatedAt.Time().Before(q.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// Page returns the page of subscribers sorted by email the same way as
+// DynamoDB does. It is used by in-memory stores.
+func (q *SubscribersQuery) Page(subscribers []*Subscriber) (*SubscribersPage, error) {
+	start, err := q.StartKey()
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]*Subscriber, len(subscribers))
+	copy(sorted, subscribers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Email < sorted[j].Email
+	})
+
+	page := &SubscribersPage{Subscribers: make([]*Subscriber, 0)}
+	evaluated := 0
+
+	for i, s := range sorted {
+		if start != nil && s.Email <= start.Email {
+			continue
+		}
+
+		evaluated++
+
+		if q.Match(s) {
+			page.Subscribers = append(page.Subscribers, s)
+		}
+
+		if q.Limit <= 0 {
+			continue
+		}
+
+		full := len(page.Subscribers) == q.Limit
+		if full || (q.MaxEvaluated > 0 && evaluated == q.MaxEvaluated) {
+			if i < len(sorted)-1 {
+				page.Cursor = EncodeCursor(&SubscriberKey{Newsletter: q.Newsletter, Email: s.Email})
+			}
+			break
+		}
+	}
+
+	return page, nil
+}
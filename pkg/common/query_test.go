@@ -0,0 +1,147 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func querySubscribers(count int) []*Subscriber {
+	created := time.Now().Add(-24 * time.Hour)
+	subscribers := make([]*Subscriber, 0, count)
+
+	// reversed to check that pages are sorted by email
+	for i := count - 1; i >= 0; i-- {
+		subscribers = append(subscribers, &Subscriber{
+			Newsletter:     "Listing1",
+			Email:          fmt.Sprintf("email%v@domain.com", i),
+			CreatedAt:      JSONTime(created.Add(time.Duration(i) * time.Hour)),
+			ConfirmedAt:    JSONTime(time.Unix(1, 1)),
+			UnsubscribedAt: JSONTime(time.Unix(1, 1)),
+		})
+	}
+
+	return subscribers
+}
+
+func TestSubscribersQueryPages(t *testing.T) {
+	subscribers := querySubscribers(5)
+	q := &SubscribersQuery{Newsletter: "Listing1", Limit: 2}
+	emails := make([]string, 0)
+	pages := 0
+
+	for {
+		page, err := q.Page(subscribers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+
+		for _, s := range page.Subscribers {
+			emails = append(emails, s.Email)
+		}
+
+		if page.Cursor == "" {
+			break
+		}
+		q.Cursor = page.Cursor
+	}
+
+	if pages != 3 {
+		t.Errorf("Unexpected number of pages %v", pages)
+	}
+
+	if len(emails) != 5 {
+		t.Fatalf("Unexpected number of subscribers %v", len(emails))
+	}
+
+	for i, email := range emails {
+		if expected := fmt.Sprintf("email%v@domain.com", i); email != expected {
+			t.Errorf("Unexpected order. expected=%v actual=%v", expected, email)
+		}
+	}
+}
+
+func TestSubscribersQueryMaxEvaluated(t *testing.T) {
+	subscribers := querySubscribers(5)
+	// only the last subscriber matches
+	q := &SubscribersQuery{
+		Newsletter:   "Listing1",
+		CreatedAfter: time.Now().Add(-21 * time.Hour),
+		Limit:        2,
+		MaxEvaluated: 2,
+	}
+
+	page, err := q.Page(subscribers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Subscribers) != 0 || page.Cursor == "" {
+		t.Fatalf("Unexpected partial page. count=%v cursor=%v", len(page.Subscribers), page.Cursor)
+	}
+
+	emails := make([]string, 0)
+	for page.Cursor != "" {
+		q.Cursor = page.Cursor
+		if page, err = q.Page(subscribers); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range page.Subscribers {
+			emails = append(emails, s.Email)
+		}
+	}
+
+	if len(emails) != 1 || emails[0] != "email4@domain.com" {
+		t.Errorf("Unexpected subscribers %v", emails)
+	}
+}
+
+func TestSubscribersQueryFilters(t *testing.T) {
+	subscribers := querySubscribers(4)
+	// email3 is confirmed and email2 is unsubscribed
+	subscribers[0].ConfirmedAt = JSONTime(subscribers[0].CreatedAt.Time().Add(time.Minute))
+	subscribers[1].UnsubscribedAt = JSONTime(subscribers[1].CreatedAt.Time().Add(time.Minute))
+	created := subscribers[3].CreatedAt.Time()
+
+	tests := []struct {
+		query    SubscribersQuery
+		expected int
+	}{
+		{SubscribersQuery{Newsletter: "Listing1"}, 4},
+		{SubscribersQuery{Newsletter: "Listing2"}, 0},
+		{SubscribersQuery{Newsletter: "Listing1", Status: StatusConfirmed}, 1},
+		{SubscribersQuery{Newsletter: "Listing1", Status: StatusUnsubscribed}, 1},
+		{SubscribersQuery{Newsletter: "Listing1", Status: StatusPending}, 2},
+		{SubscribersQuery{Newsletter: "Listing1", CreatedAfter: created}, 3},
+		{SubscribersQuery{Newsletter: "Listing1", CreatedBefore: created.Add(2 * time.Hour)}, 2},
+		{SubscribersQuery{Newsletter: "Listing1", Status: StatusPending, CreatedAfter: created}, 1},
+	}
+
+	for i, tt := range tests {
+		page, err := tt.query.Page(subscribers)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page.Subscribers) != tt.expected {
+			t.Errorf("Unexpected number of subscribers. test=%v expected=%v actual=%v", i, tt.expected, len(page.Subscribers))
+		}
+	}
+}
+
+func TestSubscribersQueryInvalidCursor(t *testing.T) {
+	cursors := []string{
+		"not a cursor",
+		EncodeCursor(&SubscriberKey{Newsletter: "Listing2", Email: "foo@bar.com"}),
+		EncodeCursor(&SubscriberKey{Newsletter: "Listing1"}),
+	}
+
+	for _, cursor := range cursors {
+		q := &SubscribersQuery{Newsletter: "Listing1", Cursor: cursor}
+		if _, err := q.StartKey(); err != errInvalidCursor {
+			t.Errorf("Cursor is accepted. cursor=%v", cursor)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package common
 
+import "time"
+
 const (
 	SoftBounceType = "sb"
 	HardBounceType = "hb"
 	ComplaintType  = "ct"
+	// SuppressionLiftedType marks that admin allowed emails to the address
+	// despite earlier hard bounces or complaints
+	SuppressionLiftedType = "sl"
 )
 
 type SesNotification struct {
@@ -13,6 +18,39 @@ type SesNotification struct {
 	Notification string   `json:"notification"`
 }
 
+// SuppressedEmails returns addresses that should not receive any emails:
+// the ones with a hard bounce or a complaint received after the suppression
+// of the address was lifted (if ever)
+func SuppressedEmails(notifications []*SesNotification) map[string]bool {
+	lifted := make(map[string]time.Time)
+
+	for _, n := range notifications {
+		if n.Notification != SuppressionLiftedType {
+			continue
+		}
+
+		if t := n.ReceivedAt.Time(); t.After(lifted[n.Email]) {
+			lifted[n.Email] = t
+		}
+	}
+
+	suppressed := make(map[string]bool)
+
+	for _, n := range notifications {
+		if n.Notification != HardBounceType && n.Notification != ComplaintType {
+			continue
+		}
+
+		if t, ok := lifted[n.Email]; ok && !n.ReceivedAt.Time().After(t) {
+			continue
+		}
+
+		suppressed[n.Email] = true
+	}
+
+	return suppressed
+}
+
 // types used for deserializing of SES notifications
 
 type SesMessage struct {
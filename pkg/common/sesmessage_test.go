@@ -3,6 +3,7 @@ package common
 import (
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 const sesNotificationJson = `{
@@ -41,3 +42,31 @@ func TestParseSesNotification(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestSuppressedEmails(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) JSONTime { return JSONTime(now.Add(d)) }
+
+	notifications := []*SesNotification{
+		{Email: "hard@bounce.com", Notification: HardBounceType, ReceivedAt: at(0)},
+		{Email: "soft@bounce.com", Notification: SoftBounceType, ReceivedAt: at(0)},
+		{Email: "complaint@bounce.com", Notification: ComplaintType, ReceivedAt: at(0)},
+		{Email: "lifted@bounce.com", Notification: ComplaintType, ReceivedAt: at(0)},
+		{Email: "lifted@bounce.com", Notification: SuppressionLiftedType, ReceivedAt: at(time.Minute)},
+		{Email: "again@bounce.com", Notification: SuppressionLiftedType, ReceivedAt: at(0)},
+		{Email: "again@bounce.com", Notification: HardBounceType, ReceivedAt: at(time.Minute)},
+	}
+
+	suppressed := SuppressedEmails(notifications)
+	expected := []string{"hard@bounce.com", "complaint@bounce.com", "again@bounce.com"}
+
+	if len(suppressed) != len(expected) {
+		t.Errorf("Unexpected suppressed emails: %v", suppressed)
+	}
+
+	for _, e := range expected {
+		if !suppressed[e] {
+			t.Errorf("Email is not suppressed: %v", e)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package common
+
+import "time"
+
+// StatsDateLayout is the format of dates in stats
+const StatsDateLayout = "2006-01-02"
+
+// Stats contains statistics of newsletters for the range of dates
+type Stats struct {
+	// From and To are inclusive dates in UTC
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Newsletters []*NewsletterStats `json:"newsletters"`
+}
+
+// NewsletterStats contains subscriber counts of the newsletter and
+// daily changes within the range of dates
+type NewsletterStats struct {
+	Newsletter   string `json:"newsletter"`
+	Total        int    `json:"total"`
+	Confirmed    int    `json:"confirmed"`
+	Pending      int    `json:"pending"`
+	Unsubscribed int    `json:"unsubscribed"`
+	// Suppressed subscribers hard-bounced or complained. They are
+	// also counted in one of the statuses above.
+	Suppressed int `json:"suppressed"`
+	// ConversionRate is the share of signups within the range that
+	// have been confirmed
+	ConversionRate float64       `json:"conversion_rate"`
+	Daily          []*DailyStats `json:"daily"`
+
+	days map[string]*DailyStats
+	// signups are addresses that subscribed within the range
+	signups   map[string]bool
+	converted int
+}
+
+// DailyStats contains changes of subscriptions during one day
+type DailyStats struct {
+	Date          string `json:"date"`
+	Signups       int    `json:"signups"`
+	Confirmations int    `json:"confirmations"`
+	Unsubscribes  int    `json:"unsubscribes"`
+}
+
+// NewNewsletterStats creates empty stats with a daily entry for every
+// date from the first to the last one
+func NewNewsletterStats(newsletter string, from, to time.Time) *NewsletterStats {
+	ns := &NewsletterStats{
+		Newsletter: newsletter,
+		Daily:      make([]*DailyStats, 0),
+		days:       make(map[string]*DailyStats),
+		signups:    make(map[string]bool),
+	}
+
+	for d := from.UTC(); !d.After(to.UTC()); d = d.AddDate(0, 0, 1) {
+		ds := &DailyStats{Date: d.Format(StatsDateLayout)}
+		ns.Daily = append(ns.Daily, ds)
+		ns.days[ds.Date] = ds
+	}
+
+	return ns
+}
+
+// day returns the entry of the date if it is within the range
+func (ns *NewsletterStats) day(t JSONTime) (*DailyStats, bool) {
+	ds, ok := ns.days[t.Time().UTC().Format(StatsDateLayout)]
+	return ds, ok
+}
+
+// Add counts the subscriber by its current status
+func (ns *NewsletterStats) Add(s *Subscriber, suppressed bool) {
+	ns.Total++
+
+	switch {
+	case s.Unsubscribed():
+		ns.Unsubscribed++
+	case s.Confirmed():
+		ns.Confirmed++
+	default:
+		ns.Pending++
+	}
+
+	if suppressed {
+		ns.Suppressed++
+	}
+}
+
+// AddEvent counts the lifecycle event in the daily changes. Events
+// are expected to be added sorted by time.
+func (ns *NewsletterStats) AddEvent(e *SubscriberEvent) {
+	ds, ok := ns.day(e.CreatedAt)
+	if !ok {
+		return
+	}
+
+	switch e.Event {
+	case EventSubscribe:
+		ds.Signups++
+		ns.signups[e.Email] = true
+	case EventConfirm:
+		ds.Confirmations++
+		if ns.signups[e.Email] {
+			delete(ns.signups, e.Email)
+			ns.converted++
+		}
+	case EventUnsubscribe:
+		ds.Unsubscribes++
+	}
+
+	if signups := ns.converted + len(ns.signups); signups > 0 {
+		ns.ConversionRate = float64(ns.converted) / float64(signups)
+	}
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewsletterStats(t *testing.T) {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)
+	ns := NewNewsletterStats("Listing1", from, to)
+	never := JSONTime(time.Unix(1, 1))
+
+	day := func(d, h int) JSONTime {
+		return JSONTime(from.AddDate(0, 0, d).Add(time.Duration(h) * time.Hour))
+	}
+
+	// confirmed on the next day
+	ns.Add(&Subscriber{CreatedAt: day(0, 1), ConfirmedAt: day(1, 1), UnsubscribedAt: never}, false)
+	// pending and suppressed
+	ns.Add(&Subscriber{CreatedAt: day(1, 1), ConfirmedAt: never, UnsubscribedAt: never}, true)
+	// subscribed before the range and unsubscribed within it
+	ns.Add(&Subscriber{CreatedAt: day(-10, 1), ConfirmedAt: day(-10, 2), UnsubscribedAt: day(2, 1)}, false)
+
+	for _, e := range []*SubscriberEvent{
+		{Email: "foo@bar.com", Event: EventSubscribe, CreatedAt: day(0, 1)},
+		{Email: "bar@foo.com", Event: EventSubscribe, CreatedAt: day(1, 1)},
+		{Email: "foo@bar.com", Event: EventConfirm, CreatedAt: day(1, 1)},
+		{Email: "baz@foo.com", Event: EventConfirm, CreatedAt: day(1, 2)},
+		{Email: "baz@foo.com", Event: EventUnsubscribe, CreatedAt: day(2, 1)},
+		// outside of the range
+		{Email: "baz@foo.com", Event: EventSubscribe, CreatedAt: day(3, 1)},
+	} {
+		ns.AddEvent(e)
+	}
+
+	if len(ns.Daily) != 3 {
+		t.Fatalf("Unexpected number of days %v", len(ns.Daily))
+	}
+
+	counts := []struct {
+		actual   int
+		expected int
+	}{
+		{ns.Total, 3},
+		{ns.Confirmed, 1},
+		{ns.Pending, 1},
+		{ns.Unsubscribed, 1},
+		{ns.Suppressed, 1},
+		{ns.Daily[0].Signups, 1},
+		{ns.Daily[1].Signups, 1},
+		{ns.Daily[1].Confirmations, 2},
+		{ns.Daily[2].Unsubscribes, 1},
+	}
+
+	for i, c := range counts {
+		if c.actual != c.expected {
+			t.Errorf("Unexpected count. index=%v expected=%v actual=%v", i, c.expected, c.actual)
+		}
+	}
+
+	if ns.Daily[2].Date != "2020-01-03" {
+		t.Errorf("Unexpected date %v", ns.Daily[2].Date)
+	}
+
+	if ns.ConversionRate != 0.5 {
+		t.Errorf("Unexpected conversion rate %v", ns.ConversionRate)
+	}
+}
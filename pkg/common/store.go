@@ -1,19 +1,57 @@
 package common
 
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSubscriberNotFound is returned by stores if there is no such subscriber
+	ErrSubscriberNotFound = errors.New("Subscriber does not exist")
+	// ErrSubscriberNotPending is returned by stores if the subscriber does
+	// not exist or is already confirmed
+	ErrSubscriberNotPending = errors.New("Subscriber is not pending confirmation")
+	// ErrNewsletterNotFound is returned by stores if there is no such newsletter
+	ErrNewsletterNotFound = errors.New("Newsletter does not exist")
+	// ErrNewsletterExists is returned by stores if the name is already taken
+	ErrNewsletterExists = errors.New("Newsletter already exists")
+	// ErrAPIKeyNotFound is returned by stores if there is no such API key
+	ErrAPIKeyNotFound = errors.New("API key does not exist")
+)
+
 // SubscribersStore is an interface used to manage subscribers DB from the main API
 type SubscribersStore interface {
-	AddSubscriber(newsletter, email, name string) error
+	AddSubscriber(newsletter, email, name, locale string, attributes map[string]string) error
 	RemoveSubscriber(newsletter, email string) error
 	Subscribers(newsletter string) (subscribers []*Subscriber, err error)
+	// QuerySubscribers returns one page of filtered subscribers sorted by email
+	QuerySubscribers(query *SubscribersQuery) (*SubscribersPage, error)
 	AddSubscribers(subscribers []*Subscriber) error
 	DeleteSubscribers(keys []*SubscriberKey) error
 	ConfirmSubscriber(newsletter, email string) error
+	// MarkReminded sets only the reminder time of the unconfirmed subscriber
+	// with exactly this key (the email is not normalized) and fails with
+	// ErrSubscriberNotPending otherwise
+	MarkReminded(newsletter, email string, remindedAt JSONTime) error
 	GetSubscriber(newsletter, email string) (*Subscriber, error)
+	// UpdateSubscriber changes only the fields set in the update and
+	// returns the updated subscriber
+	UpdateSubscriber(newsletter, email string, update *SubscriberUpdate) (*Subscriber, error)
 }
 
 // Mailer is an interface for sending confirmation emails for subscriptions
 type Mailer interface {
-	SendConfirmation(newsletter, email, name, confirmURL string) error
+	SendConfirmation(newsletter *NewsletterConfig, email, name, locale string) error
+}
+
+// NewslettersStore is an interface used to manage newsletter configs
+type NewslettersStore interface {
+	Newsletters() (newsletters []*NewsletterConfig, err error)
+	// AddNewsletter fails with ErrNewsletterExists if the name is taken
+	AddNewsletter(nc *NewsletterConfig) error
+	// UpdateNewsletter replaces the config of existing newsletter
+	UpdateNewsletter(nc *NewsletterConfig) error
+	DeleteNewsletter(name string) error
 }
 
 // NotificationsStore is an interface used to manage SES bounce and complaint
@@ -22,4 +60,48 @@ type NotificationsStore interface {
 	AddBounce(email, from string, isTransient bool) error
 	AddComplaint(email, from string) error
 	Notifications() (notifications []*SesNotification, err error)
+	EmailNotifications(email string) (notifications []*SesNotification, err error)
+	// LiftSuppression allows emails to the address after hard bounces or complaints
+	LiftSuppression(email string) error
+	DeleteNotifications(email string) error
+}
+
+// ErasuresStore is an interface used to keep hashes of erased addresses
+type ErasuresStore interface {
+	AddErasure(hash string) error
+	Erasures() (erasures []*Erasure, err error)
+}
+
+// EventsStore is an interface used to keep the history of subscriptions.
+// Events are never updated or removed.
+type EventsStore interface {
+	AddEvents(events []*SubscriberEvent) error
+	// Events returns history of the address sorted by time
+	Events(email string) (events []*SubscriberEvent, err error)
+	// NewsletterEvents returns events of the newsletter created within
+	// the range of time sorted by time
+	NewsletterEvents(newsletter string, from, to time.Time) (events []*SubscriberEvent, err error)
+}
+
+// APIKeysStore is an interface used to manage keys of the admin API
+type APIKeysStore interface {
+	AddAPIKey(k *APIKey) error
+	// GetAPIKey fails with ErrAPIKeyNotFound if there is no such key
+	GetAPIKey(id string) (*APIKey, error)
+	APIKeys() (keys []*APIKey, err error)
+	DeleteAPIKey(id string) error
+}
+
+// DeadLettersStore is an interface used to keep failed webhook deliveries
+type DeadLettersStore interface {
+	AddDeadLetter(d *DeadLetter) error
+	DeadLetters() (deadLetters []*DeadLetter, err error)
+}
+
+// RateLimitStore is an interface used to keep rate limiting counters
+// shared between API instances
+type RateLimitStore interface {
+	// Increment adds one to the counter of the key and returns new value.
+	// Counter can be dropped after expiresAt
+	Increment(key string, expiresAt time.Time) (int64, error)
 }
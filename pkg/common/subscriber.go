@@ -1,6 +1,10 @@
 package common
 
-import "github.com/rs/xid"
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
 
 // Subscriber incapsulates newsletter subscriber information
 // stored in the DynamoDB table
@@ -11,7 +15,11 @@ type Subscriber struct {
 	CreatedAt      JSONTime `json:"created_at"`
 	UnsubscribedAt JSONTime `json:"unsubscribed_at"`
 	ConfirmedAt    JSONTime `json:"confirmed_at"`
+	RemindedAt     JSONTime `json:"reminded_at"`
 	UserID         string   `json:"user_id,omitempty"`
+	Locale         string   `json:"locale,omitempty"`
+	// Attributes are arbitrary values like country or signup source
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 // Confirmed checks if subscriber has confirmed the email via link
@@ -24,6 +32,96 @@ func (s *Subscriber) Unsubscribed() bool {
 	return s.UnsubscribedAt.Time().After(s.CreatedAt.Time())
 }
 
+// Reminded checks if confirmation reminder was sent for the subscription
+func (s *Subscriber) Reminded() bool {
+	return s.RemindedAt.Time().After(s.CreatedAt.Time())
+}
+
+// pending checks if subscriber neither confirmed nor unsubscribed yet
+func (s *Subscriber) pending() bool {
+	return !s.Confirmed() && !s.Unsubscribed()
+}
+
+// active checks if subscriber confirmed and did not unsubscribe
+func (s *Subscriber) active() bool {
+	return s.Confirmed() && !s.Unsubscribed()
+}
+
+// changedAt returns time of the latest change of the subscription
+func (s *Subscriber) changedAt() time.Time {
+	t := s.CreatedAt.Time()
+
+	if s.Confirmed() && s.ConfirmedAt.Time().After(t) {
+		t = s.ConfirmedAt.Time()
+	}
+
+	if s.Unsubscribed() && s.UnsubscribedAt.Time().After(t) {
+		t = s.UnsubscribedAt.Time()
+	}
+
+	return t
+}
+
+// supersedes checks if the state of the subscription is more relevant
+// than the state of other subscription of the same person
+func (s *Subscriber) supersedes(other *Subscriber) bool {
+	// repeated signup does not cancel confirmed subscription
+	if s.pending() && other.active() {
+		return false
+	}
+
+	if other.pending() && s.active() {
+		return true
+	}
+
+	return s.changedAt().After(other.changedAt())
+}
+
+// MergeSubscribers combines duplicate subscriptions of the same person
+// to one. The state is taken from the latest change, except that pending
+// signup does not override confirmed subscription. Empty name, locale and
+// attributes are filled from other duplicates.
+func MergeSubscribers(subscribers []*Subscriber) *Subscriber {
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	primary := subscribers[0]
+	for _, s := range subscribers[1:] {
+		if s.supersedes(primary) {
+			primary = s
+		}
+	}
+
+	merged := *primary
+	merged.Attributes = make(map[string]string)
+	for k, v := range primary.Attributes {
+		merged.Attributes[k] = v
+	}
+
+	for _, s := range subscribers {
+		if merged.Name == "" {
+			merged.Name = s.Name
+		}
+
+		if merged.Locale == "" {
+			merged.Locale = s.Locale
+		}
+
+		for k, v := range s.Attributes {
+			if _, ok := merged.Attributes[k]; !ok {
+				merged.Attributes[k] = v
+			}
+		}
+	}
+
+	if len(merged.Attributes) == 0 {
+		merged.Attributes = nil
+	}
+
+	return &merged
+}
+
 func (s *Subscriber) Validate() {
 	if len(s.UserID) > 0 {
 		return
@@ -33,6 +131,83 @@ func (s *Subscriber) Validate() {
 	s.UserID = guid.String()
 }
 
+// unsetTime is stored instead of missing confirmation or unsubscription
+var unsetTime = JSONTime(time.Unix(1, 1))
+
+// SubscriberUpdate contains new values of subscriber fields for the
+// store, nil fields are not changed
+type SubscriberUpdate struct {
+	Name           *string
+	Attributes     map[string]string
+	ConfirmedAt    *JSONTime
+	UnsubscribedAt *JSONTime
+}
+
+// Empty checks if the update does not change anything
+func (u *SubscriberUpdate) Empty() bool {
+	return u.Name == nil && u.Attributes == nil && u.ConfirmedAt == nil && u.UnsubscribedAt == nil
+}
+
+// Apply changes the subscriber in place
+func (u *SubscriberUpdate) Apply(s *Subscriber) {
+	if u.Name != nil {
+		s.Name = *u.Name
+	}
+
+	if u.Attributes != nil {
+		s.Attributes = u.Attributes
+		if len(s.Attributes) == 0 {
+			s.Attributes = nil
+		}
+	}
+
+	if u.ConfirmedAt != nil {
+		s.ConfirmedAt = *u.ConfirmedAt
+	}
+
+	if u.UnsubscribedAt != nil {
+		s.UnsubscribedAt = *u.UnsubscribedAt
+	}
+}
+
+// SubscriberPatch is a partial update of the subscriber in the admin API.
+// Missing fields are not changed, attributes are replaced as a whole and
+// empty attributes remove all of them.
+type SubscriberPatch struct {
+	Name         *string           `json:"name,omitempty"`
+	Attributes   map[string]string `json:"attributes"`
+	Confirmed    *bool             `json:"confirmed,omitempty"`
+	Unsubscribed *bool             `json:"unsubscribed,omitempty"`
+}
+
+// Update returns the update of the subscriber required by the patch.
+// Times of confirmation and unsubscription are only changed together
+// with the state, so repeated patches keep the original times.
+func (p *SubscriberPatch) Update(s *Subscriber, now JSONTime) *SubscriberUpdate {
+	u := &SubscriberUpdate{
+		Name:       p.Name,
+		Attributes: p.Attributes,
+	}
+
+	if p.Confirmed != nil && *p.Confirmed != s.Confirmed() {
+		t := unsetTime
+		if *p.Confirmed {
+			t = now
+		}
+		u.ConfirmedAt = &t
+	}
+
+	if p.Unsubscribed != nil && *p.Unsubscribed != s.Unsubscribed() {
+		t := unsetTime
+		if *p.Unsubscribed {
+			t = now
+		}
+		u.UnsubscribedAt = &t
+	}
+
+	return u
+}
+
 // SubscriberKey is used for deletion of subscribers
 type SubscriberKey struct {
 	Newsletter string `json:"newsletter"`
@@ -40,23 +215,32 @@ type SubscriberKey struct {
 }
 
 type SubscriberEx struct {
-	Name         string `json:"name" yaml:"name"`
-	Newsletter   string `json:"newsletter" yaml:"newsletter"`
-	Email        string `json:"email" yaml:"email"`
-	Token        string `json:"token" yaml:"token"`
-	Confirmed    bool   `json:"confirmed" yaml:"confirmed"`
-	Unsubscribed bool   `json:"unsubscribed" yaml:"unsubscribed"`
-	UserID       string `json:"user_id" yaml:"user_id"`
+	Name             string `json:"name" yaml:"name"`
+	Newsletter       string `json:"newsletter" yaml:"newsletter"`
+	Email            string `json:"email" yaml:"email"`
+	Token            string `json:"token" yaml:"token"`
+	PreferencesToken string `json:"preferences_token" yaml:"preferences_token"`
+	Confirmed        bool   `json:"confirmed" yaml:"confirmed"`
+	Unsubscribed     bool   `json:"unsubscribed" yaml:"unsubscribed"`
+	UserID           string `json:"user_id" yaml:"user_id"`
+	Locale           string `json:"locale" yaml:"locale"`
+	// Attributes are available in listing-send templates as .Recepient.attributes
+	Attributes map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
 }
 
+// NewSubscriberEx adds the unsubscribe token of the newsletter and
+// the preferences token of the address to the subscriber
 func NewSubscriberEx(s *Subscriber, secret string) *SubscriberEx {
 	return &SubscriberEx{
-		Name:         s.Name,
-		Newsletter:   s.Newsletter,
-		Email:        s.Email,
-		Confirmed:    s.Confirmed(),
-		Unsubscribed: s.Unsubscribed(),
-		Token:        Sign(secret, s.Email),
-		UserID:       s.UserID,
+		Name:             s.Name,
+		Newsletter:       s.Newsletter,
+		Email:            s.Email,
+		Confirmed:        s.Confirmed(),
+		Unsubscribed:     s.Unsubscribed(),
+		Token:            SignToken(secret, NewToken(PurposeUnsubscribe, s.Newsletter, s.Email)),
+		PreferencesToken: SignToken(secret, NewToken(PurposePreferences, "", s.Email)),
+		UserID:           s.UserID,
+		Locale:           s.Locale,
+		Attributes:       s.Attributes,
 	}
 }
@@ -32,3 +32,94 @@ func TestSubscribed(t *testing.T) {
 		t.Errorf("Subscriber is not unsubscribed with correct time")
 	}
 }
+
+func TestReminded(t *testing.T) {
+	s := &Subscriber{
+		CreatedAt:  JsonTimeNow(),
+		RemindedAt: JSONTime(time.Unix(1, 1)),
+	}
+	if s.Reminded() {
+		t.Errorf("Subscriber is reminded with incorrect time")
+	}
+	s.RemindedAt = JSONTime(s.CreatedAt.Time().Add(1 * time.Second))
+	if !s.Reminded() {
+		t.Errorf("Subscriber is not reminded with correct time")
+	}
+}
+
+func TestMergeSubscribers(t *testing.T) {
+	created := time.Now().Add(-10 * 24 * time.Hour)
+	at := func(days int) JSONTime {
+		return JSONTime(created.Add(time.Duration(days) * 24 * time.Hour))
+	}
+	never := JSONTime(time.Unix(1, 1))
+
+	confirmed := &Subscriber{Email: "Foo@bar.com", Name: "Foo", CreatedAt: at(0), ConfirmedAt: at(1), UnsubscribedAt: never}
+	pending := &Subscriber{Email: "foo@bar.com", Locale: "uk", CreatedAt: at(2), ConfirmedAt: never, UnsubscribedAt: never,
+		Attributes: map[string]string{"country": "UA"}}
+	unsubscribed := &Subscriber{Email: "FOO@bar.com", CreatedAt: at(0), ConfirmedAt: at(1), UnsubscribedAt: at(3)}
+	resubscribed := &Subscriber{Email: "foo@Bar.com", CreatedAt: at(4), ConfirmedAt: never, UnsubscribedAt: never}
+
+	tests := []struct {
+		name       string
+		duplicates []*Subscriber
+		expected   *Subscriber
+	}{
+		{"pending signup keeps confirmed subscription", []*Subscriber{pending, confirmed}, confirmed},
+		{"later unsubscribe wins", []*Subscriber{confirmed, pending, unsubscribed}, unsubscribed},
+		{"signup after unsubscribe wins", []*Subscriber{unsubscribed, resubscribed}, resubscribed},
+	}
+
+	for _, tt := range tests {
+		m := MergeSubscribers(tt.duplicates)
+		if m.Email != tt.expected.Email || m.Confirmed() != tt.expected.Confirmed() || m.Unsubscribed() != tt.expected.Unsubscribed() {
+			t.Errorf("Unexpected merge. test=%v merged=%+v", tt.name, m)
+		}
+	}
+
+	m := MergeSubscribers([]*Subscriber{pending, confirmed})
+	if m.Name != "Foo" || m.Locale != "uk" || m.Attributes["country"] != "UA" {
+		t.Errorf("Empty fields are not filled from duplicates. merged=%+v", m)
+	}
+
+	if confirmed.Locale != "" || confirmed.Attributes != nil {
+		t.Errorf("Merge changed the duplicate")
+	}
+}
+
+func TestSubscriberPatch(t *testing.T) {
+	never := JSONTime(time.Unix(1, 1))
+	created := time.Now().Add(-24 * time.Hour)
+	confirmedAt := JSONTime(created.Add(time.Hour))
+	s := &Subscriber{
+		Name:           "Foo",
+		CreatedAt:      JSONTime(created),
+		ConfirmedAt:    confirmedAt,
+		UnsubscribedAt: never,
+		Attributes:     map[string]string{"country": "UA"},
+	}
+
+	yes, no, name := true, false, "Bar"
+	now := JsonTimeNow()
+
+	// confirmed subscriber keeps the original confirmation time
+	u := (&SubscriberPatch{Name: &name, Confirmed: &yes, Unsubscribed: &yes}).Update(s, now)
+	if u.ConfirmedAt != nil || u.UnsubscribedAt == nil || *u.UnsubscribedAt != now {
+		t.Errorf("Unexpected update %+v", u)
+	}
+
+	u.Apply(s)
+	if s.Name != name || !s.Unsubscribed() || s.ConfirmedAt != confirmedAt {
+		t.Errorf("Update is not applied %+v", s)
+	}
+
+	u = (&SubscriberPatch{Confirmed: &no, Attributes: map[string]string{}}).Update(s, now)
+	u.Apply(s)
+	if s.Confirmed() || s.Attributes != nil {
+		t.Errorf("Update is not applied %+v", s)
+	}
+
+	if u = (&SubscriberPatch{Confirmed: &no}).Update(s, now); !u.Empty() {
+		t.Errorf("Update of the same state is not empty %+v", u)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"strings"
+	"time"
 )
 
 // helpers.
@@ -15,9 +18,14 @@ var (
 
 // Sign a value.
 func Sign(secret, value string) string {
+	return Signature(secret, value) + "." + encode([]byte(value))
+}
+
+// Signature returns encoded HMAC of the value without the value itself.
+func Signature(secret, value string) string {
 	m := hmac.New(sha256.New, []byte(secret))
 	m.Write([]byte(value))
-	return encode(m.Sum(nil)) + "." + encode([]byte(value))
+	return encode(m.Sum(nil))
 }
 
 // Unsign a value.
@@ -47,3 +55,120 @@ func Unsign(secret, msg string) (string, bool) {
 
 	return string(payload), true
 }
+
+// purposes of the versioned tokens
+const (
+	PurposeConfirm     = "confirm"
+	PurposeUnsubscribe = "unsubscribe"
+	PurposePreferences = "preferences"
+)
+
+const (
+	tokenVersion = "v1"
+	day          = 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken    = errors.New("Invalid token")
+	ErrTokenExpired    = errors.New("Token has expired")
+	ErrTokenPurpose    = errors.New("Token was issued for another purpose")
+	ErrTokenNewsletter = errors.New("Token was issued for another newsletter")
+	ErrLegacyToken     = errors.New("Legacy tokens are not accepted anymore")
+)
+
+// DefaultTokenMaxAge is used for purposes missing in TokenPolicy.MaxAge
+var DefaultTokenMaxAge = map[string]time.Duration{
+	PurposeConfirm:     7 * day,
+	PurposeUnsubscribe: 365 * day,
+	PurposePreferences: 365 * day,
+}
+
+// Token is the signed payload of the versioned token
+type Token struct {
+	Purpose    string `json:"p"`
+	Newsletter string `json:"n"`
+	Email      string `json:"e"`
+	IssuedAt   int64  `json:"t"`
+}
+
+// NewToken creates token issued right now
+func NewToken(purpose, newsletter, email string) *Token {
+	return &Token{
+		Purpose:    purpose,
+		Newsletter: newsletter,
+		Email:      email,
+		IssuedAt:   time.Now().UTC().Unix(),
+	}
+}
+
+// Issued returns the time when token was created
+func (t *Token) Issued() time.Time {
+	return time.Unix(t.IssuedAt, 0).UTC()
+}
+
+// SignToken creates versioned token in format "v1.signature.payload"
+func SignToken(secret string, t *Token) string {
+	data, _ := json.Marshal(t)
+	return tokenVersion + "." + Sign(secret, string(data))
+}
+
+// TokenPolicy defines which tokens are accepted
+type TokenPolicy struct {
+	// MaxAge of tokens per purpose, zero duration disables expiration
+	MaxAge map[string]time.Duration
+	// LegacyUntil is the end of grace period for unversioned tokens
+	LegacyUntil time.Time
+}
+
+func (p *TokenPolicy) maxAge(purpose string) time.Duration {
+	if d, ok := p.MaxAge[purpose]; ok {
+		return d
+	}
+
+	return DefaultTokenMaxAge[purpose]
+}
+
+// Unsign checks the token and returns the email it was issued for
+func (p *TokenPolicy) Unsign(secret, msg, purpose, newsletter string) (string, error) {
+	parts := strings.SplitN(msg, ".", 2)
+	if len(parts) == 2 && parts[0] == tokenVersion {
+		return p.unsignVersioned(secret, parts[1], purpose, newsletter)
+	}
+
+	email, ok := Unsign(secret, msg)
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	if !time.Now().Before(p.LegacyUntil) {
+		return "", ErrLegacyToken
+	}
+
+	return email, nil
+}
+
+func (p *TokenPolicy) unsignVersioned(secret, msg, purpose, newsletter string) (string, error) {
+	payload, ok := Unsign(secret, msg)
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	t := &Token{}
+	if err := json.Unmarshal([]byte(payload), t); err != nil {
+		return "", ErrInvalidToken
+	}
+
+	if t.Purpose != purpose {
+		return "", ErrTokenPurpose
+	}
+
+	if t.Newsletter != newsletter {
+		return "", ErrTokenNewsletter
+	}
+
+	if maxAge := p.maxAge(purpose); maxAge > 0 && time.Since(t.Issued()) > maxAge {
+		return "", ErrTokenExpired
+	}
+
+	return t.Email, nil
+}
@@ -1,6 +1,16 @@
 package common
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret     = "abcd"
+	testNewsletter = "newsletter"
+	testEmail      = "email@domain.com"
+)
 
 func TestTokenSignUnsign(t *testing.T) {
 	secret := "abcd"
@@ -17,3 +27,85 @@ func TestTokenSignUnsign(t *testing.T) {
 		t.Errorf("Values do not match. unsigned=%v value=%v", unsigned, value)
 	}
 }
+
+func TestVersionedTokenUnsign(t *testing.T) {
+	p := &TokenPolicy{}
+	signed := SignToken(testSecret, NewToken(PurposeConfirm, testNewsletter, testEmail))
+
+	email, err := p.Unsign(testSecret, signed, PurposeConfirm, testNewsletter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if email != testEmail {
+		t.Errorf("Values do not match. unsigned=%v value=%v", email, testEmail)
+	}
+}
+
+func TestVersionedTokenErrors(t *testing.T) {
+	p := &TokenPolicy{
+		MaxAge: map[string]time.Duration{PurposeConfirm: time.Hour},
+	}
+	expired := NewToken(PurposeConfirm, testNewsletter, testEmail)
+	expired.IssuedAt = time.Now().Add(-2 * time.Hour).Unix()
+
+	tests := []struct {
+		token      string
+		secret     string
+		purpose    string
+		newsletter string
+		err        error
+	}{
+		{SignToken(testSecret, NewToken(PurposeConfirm, testNewsletter, testEmail)), "wrong", PurposeConfirm, testNewsletter, ErrInvalidToken},
+		{SignToken(testSecret, NewToken(PurposeConfirm, testNewsletter, testEmail)), testSecret, PurposeUnsubscribe, testNewsletter, ErrTokenPurpose},
+		{SignToken(testSecret, NewToken(PurposeConfirm, testNewsletter, testEmail)), testSecret, PurposeConfirm, "other", ErrTokenNewsletter},
+		{SignToken(testSecret, expired), testSecret, PurposeConfirm, testNewsletter, ErrTokenExpired},
+		{"v1.abcd", testSecret, PurposeConfirm, testNewsletter, ErrInvalidToken},
+		{"abcde", testSecret, PurposeConfirm, testNewsletter, ErrInvalidToken},
+	}
+
+	for i, tt := range tests {
+		if _, err := p.Unsign(tt.secret, tt.token, tt.purpose, tt.newsletter); err != tt.err {
+			t.Errorf("Unexpected error. test=%v err=%v expected=%v", i, err, tt.err)
+		}
+	}
+}
+
+func TestTokenWithoutExpiration(t *testing.T) {
+	p := &TokenPolicy{
+		MaxAge: map[string]time.Duration{PurposeUnsubscribe: 0},
+	}
+	token := NewToken(PurposeUnsubscribe, testNewsletter, testEmail)
+	token.IssuedAt = time.Now().Add(-10 * 365 * day).Unix()
+
+	if _, err := p.Unsign(testSecret, SignToken(testSecret, token), PurposeUnsubscribe, testNewsletter); err != nil {
+		t.Errorf("Failed to unsign token without expiration. err=%v", err)
+	}
+}
+
+func TestLegacyTokenGracePeriod(t *testing.T) {
+	legacy := Sign(testSecret, testEmail)
+
+	p := &TokenPolicy{LegacyUntil: time.Now().Add(time.Hour)}
+	email, err := p.Unsign(testSecret, legacy, PurposeUnsubscribe, testNewsletter)
+	if err != nil || email != testEmail {
+		t.Errorf("Legacy token is not accepted during grace period. err=%v", err)
+	}
+
+	p.LegacyUntil = time.Now().Add(-time.Hour)
+	if _, err := p.Unsign(testSecret, legacy, PurposeUnsubscribe, testNewsletter); err != ErrLegacyToken {
+		t.Errorf("Legacy token is accepted after grace period. err=%v", err)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	signed := Sign("secret", "value")
+
+	if !strings.HasPrefix(signed, Signature("secret", "value")+".") {
+		t.Errorf("Signature does not match signed value. signed=%v", signed)
+	}
+
+	if Signature("secret", "value") == Signature("other", "value") {
+		t.Errorf("Signature does not depend on secret")
+	}
+}
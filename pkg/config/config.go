@@ -0,0 +1,255 @@
+// Package config creates parts of the APIs from environment variables
+// shared by lambdas and listing-server
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/ribtoks/listing/pkg/api"
+	"github.com/ribtoks/listing/pkg/common"
+	"github.com/ribtoks/listing/pkg/db"
+)
+
+// EnvOr returns the value of the variable or def if it is not set
+func EnvOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// DurationEnv parses Go duration from the variable if it is set
+func DurationEnv(name string) (time.Duration, bool) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Failed to parse duration. name=%v value=%v err=%v", name, v, err)
+	}
+
+	return d, true
+}
+
+// IntEnv parses number from the variable, zero if it is not set
+func IntEnv(name string) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Failed to parse number. name=%v value=%v err=%v", name, v, err)
+	}
+
+	return i
+}
+
+// TokenPolicy creates policy of accepted tokens from token max ages
+// and LEGACY_TOKENS_UNTIL
+func TokenPolicy() common.TokenPolicy {
+	policy := common.TokenPolicy{
+		MaxAge: make(map[string]time.Duration),
+	}
+
+	if d, ok := DurationEnv("CONFIRM_TOKEN_MAX_AGE"); ok {
+		policy.MaxAge[common.PurposeConfirm] = d
+	}
+
+	if d, ok := DurationEnv("UNSUBSCRIBE_TOKEN_MAX_AGE"); ok {
+		policy.MaxAge[common.PurposeUnsubscribe] = d
+	}
+
+	// old unversioned tokens are accepted until this date. There is no
+	// default, links in already sent emails would stop working on deploy.
+	v := os.Getenv("LEGACY_TOKENS_UNTIL")
+	if v == "" {
+		log.Fatal("LEGACY_TOKENS_UNTIL is required, use a past date if no emails were sent with old tokens")
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		log.Fatalf("Failed to parse legacy tokens deadline. value=%v err=%v", v, err)
+	}
+	policy.LegacyUntil = t
+
+	return policy
+}
+
+// ErasureSalt returns ERASURE_SALT. There is no default, erased
+// addresses are remembered as hashes and unsalted ones can be reversed
+// by hashing known addresses.
+func ErasureSalt() string {
+	v := os.Getenv("ERASURE_SALT")
+	if v == "" {
+		log.Fatal("ERASURE_SALT is required to remember erased addresses")
+	}
+
+	return v
+}
+
+// RateLimiter creates limiter backed by the store if it is configured
+// or in-memory one otherwise. Zero limit disables rate limiting.
+func RateLimiter(limit int, window time.Duration, store common.RateLimitStore) api.RateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	if store != nil {
+		return &api.StoreRateLimiter{
+			Limit:  limit,
+			Window: window,
+			Store:  store,
+		}
+	}
+
+	return api.NewMemoryRateLimiter(limit, window)
+}
+
+// Verifiers creates human verification checks for subscribe form
+func Verifiers(secret string) []api.SubmissionVerifier {
+	var vs []api.SubmissionVerifier
+
+	if field := os.Getenv("HONEYPOT_FIELD"); field != "" {
+		vs = append(vs, &api.HoneypotVerifier{Field: field})
+	}
+
+	if field := os.Getenv("FORM_STAMP_FIELD"); field != "" {
+		minDelay, _ := DurationEnv("FORM_MIN_DELAY")
+		maxAge, _ := DurationEnv("FORM_MAX_AGE")
+		vs = append(vs, &api.FormTimestampVerifier{
+			Secret:   secret,
+			Field:    field,
+			MinDelay: minDelay,
+			MaxAge:   maxAge,
+		})
+	}
+
+	if captchaURL := os.Getenv("CAPTCHA_URL"); captchaURL != "" {
+		vs = append(vs, &api.CaptchaVerifier{
+			URL:    captchaURL,
+			Secret: os.Getenv("CAPTCHA_SECRET"),
+			Field:  os.Getenv("CAPTCHA_FIELD"),
+		})
+	}
+
+	return vs
+}
+
+// NewsletterConfigs loads newsletter configs from NEWSLETTERS_CONFIG
+// file if it is set
+func NewsletterConfigs() ([]*common.NewsletterConfig, error) {
+	if path := os.Getenv("NEWSLETTERS_CONFIG"); path != "" {
+		return common.LoadNewsletterConfigs(path)
+	}
+
+	return nil, nil
+}
+
+// NewsletterRegistry creates registry of newsletters kept in NEWSLETTERS_TABLE
+// or in memory if it is not set
+func NewsletterRegistry(sess *session.Session, logger *common.Logger) *api.NewsletterRegistry {
+	var store common.NewslettersStore = db.NewNewslettersMapStore()
+	if table := os.Getenv("NEWSLETTERS_TABLE"); table != "" {
+		s := db.NewNewslettersStore(table, sess)
+		s.Logger = logger
+		store = s
+	}
+
+	registry := api.NewNewsletterRegistry(store)
+	registry.Logger = logger
+
+	return registry
+}
+
+// SeedNewsletters adds newsletters from the names and NEWSLETTERS_CONFIG
+// to the registry if it is empty. Stores in DynamoDB are seeded only if
+// SEED_NEWSLETTERS is true, in-memory stores are always seeded.
+func SeedNewsletters(registry *api.NewsletterRegistry, names []string) {
+	_, inMemory := registry.Store.(*db.NewslettersMapStore)
+	if !inMemory && os.Getenv("SEED_NEWSLETTERS") != "true" {
+		return
+	}
+
+	configs, err := NewsletterConfigs()
+	if err != nil {
+		log.Fatalf("Failed to load newsletter configs. err=%v", err)
+	}
+
+	for _, name := range names {
+		if name != "" {
+			configs = append(configs, &common.NewsletterConfig{Name: name})
+		}
+	}
+
+	seeded, err := registry.Seed(configs)
+	if err != nil {
+		registry.Logger.Error("Failed to seed newsletters", "err", err)
+		return
+	}
+
+	if !seeded {
+		registry.Logger.Info("Skipped seeding newsletters because the store is not empty")
+	}
+}
+
+// EmailNormalizer enables provider rules of email normalization
+func EmailNormalizer() *common.EmailNormalizer {
+	return &common.EmailNormalizer{
+		LowercaseLocal: os.Getenv("NORMALIZE_LOWERCASE_LOCAL") == "true",
+		GmailDots:      os.Getenv("NORMALIZE_GMAIL_DOTS") == "true",
+		PlusTags:       os.Getenv("NORMALIZE_PLUS_TAGS") == "true",
+	}
+}
+
+// NewLogger creates logger with email mode from LOG_EMAILS and
+// LOG_EMAIL_SALT
+func NewLogger() *common.Logger {
+	logger := common.NewLogger(os.Stderr)
+	logger.Salt = os.Getenv("LOG_EMAIL_SALT")
+
+	switch mode := os.Getenv("LOG_EMAILS"); mode {
+	case "":
+	case common.EmailsPlain, common.EmailsRedact, common.EmailsHash:
+		logger.Emails = mode
+	default:
+		log.Fatalf("Unknown mode of logging emails. value=%v", mode)
+	}
+
+	return logger
+}
+
+// WebhookPublisher creates publisher of lifecycle events if WEBHOOK_URLS
+// are set. Failed deliveries are kept in deadLetters. It exits if
+// WEBHOOK_SECRET is empty.
+func WebhookPublisher(deadLetters common.DeadLettersStore, logger *common.Logger) api.EventPublisher {
+	urls := os.Getenv("WEBHOOK_URLS")
+	if urls == "" {
+		return nil
+	}
+
+	// receivers cannot verify payloads signed with an empty key
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		log.Fatal("WEBHOOK_SECRET is required when WEBHOOK_URLS is set")
+	}
+
+	return &api.WebhookPublisher{
+		URLs:        strings.Split(urls, ";"),
+		Secret:      secret,
+		MaxAttempts: IntEnv("WEBHOOK_MAX_ATTEMPTS"),
+		MinDelay:    200 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		DeadLetters: deadLetters,
+		Logger:      logger,
+	}
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// APIKeysDynamoDB is an implementation of APIKeysStore interface
+// that keeps hashed admin API keys in AWS DynamoDB table
+type APIKeysDynamoDB struct {
+	TableName string
+	Client    dynamodbiface.DynamoDBAPI
+	Logger    *common.Logger
+}
+
+var _ common.APIKeysStore = (*APIKeysDynamoDB)(nil)
+
+// NewAPIKeysStore returns new instance of APIKeysDynamoDB
+func NewAPIKeysStore(table string, sess *session.Session) *APIKeysDynamoDB {
+	return &APIKeysDynamoDB{
+		Client:    dynamodb.New(sess),
+		TableName: table,
+	}
+}
+
+func (s *APIKeysDynamoDB) AddAPIKey(k *common.APIKey) error {
+	i, err := dynamodbattribute.MarshalMap(k)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.PutItem(&dynamodb.PutItemInput{
+		TableName: &s.TableName,
+		Item:      i,
+	})
+
+	return err
+}
+
+func (s *APIKeysDynamoDB) GetAPIKey(id string) (*common.APIKey, error) {
+	result, err := s.Client.GetItem(&dynamodb.GetItemInput{
+		TableName: &s.TableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, common.ErrAPIKeyNotFound
+	}
+
+	k := new(common.APIKey)
+	err = dynamodbattribute.UnmarshalMap(result.Item, k)
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (s *APIKeysDynamoDB) APIKeys() (keys []*common.APIKey, err error) {
+	input := &dynamodb.ScanInput{
+		TableName: &s.TableName,
+	}
+
+	err = s.Client.ScanPages(input, func(page *dynamodb.ScanOutput, more bool) bool {
+		var items []*common.APIKey
+		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			// print the error and continue receiving pages
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
+			return true
+		}
+
+		keys = append(keys, items...)
+		return true
+	})
+
+	// IDs are sortable by time unlike the order of the scan
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return
+}
+
+func (s *APIKeysDynamoDB) DeleteAPIKey(id string) error {
+	_, err := s.Client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: &s.TableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if isConditionalCheckFailed(err) {
+		return common.ErrAPIKeyNotFound
+	}
+
+	return err
+}
+
+type APIKeysMapStore struct {
+	items map[string]*common.APIKey
+}
+
+var _ common.APIKeysStore = (*APIKeysMapStore)(nil)
+
+func NewAPIKeysMapStore() *APIKeysMapStore {
+	return &APIKeysMapStore{
+		items: make(map[string]*common.APIKey),
+	}
+}
+
+func (s *APIKeysMapStore) AddAPIKey(k *common.APIKey) error {
+	c := *k
+	s.items[k.ID] = &c
+	return nil
+}
+
+func (s *APIKeysMapStore) GetAPIKey(id string) (*common.APIKey, error) {
+	k, ok := s.items[id]
+	if !ok {
+		return nil, common.ErrAPIKeyNotFound
+	}
+
+	c := *k
+	return &c, nil
+}
+
+func (s *APIKeysMapStore) APIKeys() (keys []*common.APIKey, err error) {
+	for _, k := range s.items {
+		c := *k
+		keys = append(keys, &c)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+func (s *APIKeysMapStore) DeleteAPIKey(id string) error {
+	if _, ok := s.items[id]; !ok {
+		return common.ErrAPIKeyNotFound
+	}
+
+	delete(s.items, id)
+	return nil
+}
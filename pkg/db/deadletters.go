@@ -0,0 +1,98 @@
+package db
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// DeadLettersDynamoDB is an implementation of DeadLettersStore interface
+// that keeps failed webhook deliveries in AWS DynamoDB table
+type DeadLettersDynamoDB struct {
+	TableName string
+	Client    dynamodbiface.DynamoDBAPI
+	Logger    *common.Logger
+}
+
+var _ common.DeadLettersStore = (*DeadLettersDynamoDB)(nil)
+
+// NewDeadLettersStore returns new instance of DeadLettersDynamoDB
+func NewDeadLettersStore(table string, sess *session.Session) *DeadLettersDynamoDB {
+	return &DeadLettersDynamoDB{
+		Client:    dynamodb.New(sess),
+		TableName: table,
+	}
+}
+
+func (s *DeadLettersDynamoDB) AddDeadLetter(d *common.DeadLetter) error {
+	i, err := dynamodbattribute.MarshalMap(d)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.PutItem(&dynamodb.PutItemInput{
+		TableName: &s.TableName,
+		Item:      i,
+	})
+
+	return err
+}
+
+func (s *DeadLettersDynamoDB) DeadLetters() (deadLetters []*common.DeadLetter, err error) {
+	input := &dynamodb.ScanInput{
+		TableName: &s.TableName,
+	}
+
+	err = s.Client.ScanPages(input, func(page *dynamodb.ScanOutput, more bool) bool {
+		var items []*common.DeadLetter
+		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			// print the error and continue receiving pages
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
+			return true
+		}
+
+		deadLetters = append(deadLetters, items...)
+		return true
+	})
+
+	// IDs are sortable by time unlike the order of the scan
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].ID < deadLetters[j].ID
+	})
+
+	return
+}
+
+type DeadLettersMapStore struct {
+	mu    sync.Mutex
+	items []*common.DeadLetter
+}
+
+var _ common.DeadLettersStore = (*DeadLettersMapStore)(nil)
+
+func NewDeadLettersMapStore() *DeadLettersMapStore {
+	return &DeadLettersMapStore{
+		items: make([]*common.DeadLetter, 0),
+	}
+}
+
+func (s *DeadLettersMapStore) AddDeadLetter(d *common.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = append(s.items, d)
+	return nil
+}
+
+func (s *DeadLettersMapStore) DeadLetters() (deadLetters []*common.DeadLetter, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*common.DeadLetter{}, s.items...), nil
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// ErasuresDynamoDB is an implementation of ErasuresStore interface
+// that keeps hashes of erased addresses in AWS DynamoDB table
+type ErasuresDynamoDB struct {
+	TableName string
+	Client    dynamodbiface.DynamoDBAPI
+	Logger    *common.Logger
+}
+
+var _ common.ErasuresStore = (*ErasuresDynamoDB)(nil)
+
+// NewErasuresStore returns new instance of ErasuresDynamoDB
+func NewErasuresStore(table string, sess *session.Session) *ErasuresDynamoDB {
+	return &ErasuresDynamoDB{
+		Client:    dynamodb.New(sess),
+		TableName: table,
+	}
+}
+
+func (s *ErasuresDynamoDB) AddErasure(hash string) error {
+	i, err := dynamodbattribute.MarshalMap(common.Erasure{
+		Hash:     hash,
+		ErasedAt: common.JsonTimeNow(),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.PutItem(&dynamodb.PutItemInput{
+		TableName: &s.TableName,
+		Item:      i,
+	})
+
+	return err
+}
+
+func (s *ErasuresDynamoDB) Erasures() (erasures []*common.Erasure, err error) {
+	input := &dynamodb.ScanInput{
+		TableName: &s.TableName,
+	}
+
+	err = s.Client.ScanPages(input, func(page *dynamodb.ScanOutput, more bool) bool {
+		var items []*common.Erasure
+		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			// print the error and continue receiving pages
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
+			return true
+		}
+
+		erasures = append(erasures, items...)
+		return true
+	})
+
+	return
+}
+
+type ErasuresMapStore struct {
+	items map[string]*common.Erasure
+}
+
+var _ common.ErasuresStore = (*ErasuresMapStore)(nil)
+
+func NewErasuresMapStore() *ErasuresMapStore {
+	return &ErasuresMapStore{
+		items: make(map[string]*common.Erasure),
+	}
+}
+
+func (s *ErasuresMapStore) AddErasure(hash string) error {
+	s.items[hash] = &common.Erasure{
+		Hash:     hash,
+		ErasedAt: common.JsonTimeNow(),
+	}
+	return nil
+}
+
+func (s *ErasuresMapStore) Erasures() (erasures []*common.Erasure, err error) {
+	for _, e := range s.items {
+		erasures = append(erasures, e)
+	}
+	return erasures, nil
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/ribtoks/backoff"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// eventsNewsletterIndex is a global secondary index of events table
+// with newsletter as a hash key and id as a range key
+const eventsNewsletterIndex = "newsletter-index"
+
+// EventsDynamoDB is an implementation of EventsStore interface
+// that keeps subscription history in AWS DynamoDB table
+type EventsDynamoDB struct {
+	TableName string
+	Client    dynamodbiface.DynamoDBAPI
+	Logger    *common.Logger
+}
+
+var _ common.EventsStore = (*EventsDynamoDB)(nil)
+
+// NewEventsStore returns new instance of EventsDynamoDB
+func NewEventsStore(table string, sess *session.Session) *EventsDynamoDB {
+	return &EventsDynamoDB{
+		Client:    dynamodb.New(sess),
+		TableName: table,
+	}
+}
+
+func (s *EventsDynamoDB) AddEventsChunk(events []*common.SubscriberEvent) error {
+	// AWS DynamoDB restriction
+	if len(events) > dynamoDBChunkSize {
+		return errChunkTooBig
+	}
+
+	requests := make([]*dynamodb.WriteRequest, 0, len(events))
+	for _, e := range events {
+		attr, err := dynamodbattribute.MarshalMap(e)
+		if err != nil {
+			return err
+		}
+
+		requests = append(requests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{
+				Item: attr,
+			},
+		})
+	}
+
+	b := &backoff.Backoff{
+		Min:    100 * time.Millisecond,
+		Max:    1 * time.Second,
+		Factor: 2,
+		Jitter: false,
+	}
+
+	for len(requests) > 0 {
+		input := &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{
+				s.TableName: requests,
+			},
+		}
+		res, err := s.Client.BatchWriteItem(input)
+		if err != nil {
+			return err
+		}
+		if unprocessed, ok := res.UnprocessedItems[s.TableName]; ok {
+			s.Logger.Warn("Found unprocessed items", "count", len(unprocessed))
+			requests = unprocessed
+		} else {
+			break
+		}
+		time.Sleep(b.Duration())
+	}
+	return nil
+}
+
+// AddEvents stores events. IDs of the events are unique
+// so existing records are never overwritten.
+func (s *EventsDynamoDB) AddEvents(events []*common.SubscriberEvent) error {
+	for i := 0; i < len(events); i += dynamoDBChunkSize {
+		end := i + dynamoDBChunkSize
+
+		if end > len(events) {
+			end = len(events)
+		}
+
+		err := s.AddEventsChunk(events[i:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *EventsDynamoDB) Events(email string) (events []*common.SubscriberEvent, err error) {
+	query := &dynamodb.QueryInput{
+		TableName:              &s.TableName,
+		KeyConditionExpression: aws.String("email = :email"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":email": {
+				S: aws.String(email),
+			},
+		},
+		ScanIndexForward: aws.Bool(true),
+	}
+
+	err = s.Client.QueryPages(query, func(page *dynamodb.QueryOutput, more bool) bool {
+		var items []*common.SubscriberEvent
+		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
+			return true
+		}
+
+		events = append(events, items...)
+		return true
+	})
+
+	return
+}
+
+// NewsletterEvents queries the newsletter index within the range of IDs
+// that were created within the range of time
+func (s *EventsDynamoDB) NewsletterEvents(newsletter string, from, to time.Time) (events []*common.SubscriberEvent, err error) {
+	query := &dynamodb.QueryInput{
+		TableName:              &s.TableName,
+		IndexName:              aws.String(eventsNewsletterIndex),
+		KeyConditionExpression: aws.String("newsletter = :newsletter AND id BETWEEN :from AND :to"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":newsletter": {
+				S: aws.String(newsletter),
+			},
+			":from": {
+				S: aws.String(common.EventIDAt(from)),
+			},
+			":to": {
+				S: aws.String(common.EventIDAt(to)),
+			},
+		},
+		ScanIndexForward: aws.Bool(true),
+	}
+
+	err = s.Client.QueryPages(query, func(page *dynamodb.QueryOutput, more bool) bool {
+		var items []*common.SubscriberEvent
+		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
+			return true
+		}
+
+		events = append(events, items...)
+		return true
+	})
+
+	return
+}
+
+type EventsMapStore struct {
+	items map[string][]*common.SubscriberEvent
+}
+
+var _ common.EventsStore = (*EventsMapStore)(nil)
+
+func NewEventsMapStore() *EventsMapStore {
+	return &EventsMapStore{
+		items: make(map[string][]*common.SubscriberEvent),
+	}
+}
+
+func (s *EventsMapStore) AddEvents(events []*common.SubscriberEvent) error {
+	for _, e := range events {
+		s.items[e.Email] = append(s.items[e.Email], e)
+	}
+	return nil
+}
+
+func (s *EventsMapStore) Events(email string) (events []*common.SubscriberEvent, err error) {
+	events = append(events, s.items[email]...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
+
+func (s *EventsMapStore) NewsletterEvents(newsletter string, from, to time.Time) (events []*common.SubscriberEvent, err error) {
+	first, last := common.EventIDAt(from), common.EventIDAt(to)
+	for _, items := range s.items {
+		for _, e := range items {
+			if e.Newsletter == newsletter && e.ID >= first && e.ID <= last {
+				events = append(events, e)
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// NewslettersDynamoDB is an implementation of NewslettersStore interface
+// that keeps newsletter configs in AWS DynamoDB table
+type NewslettersDynamoDB struct {
+	TableName string
+	Client    dynamodbiface.DynamoDBAPI
+	Logger    *common.Logger
+}
+
+var _ common.NewslettersStore = (*NewslettersDynamoDB)(nil)
+
+// NewNewslettersStore returns new instance of NewslettersDynamoDB
+func NewNewslettersStore(table string, sess *session.Session) *NewslettersDynamoDB {
+	return &NewslettersDynamoDB{
+		Client:    dynamodb.New(sess),
+		TableName: table,
+	}
+}
+
+func (s *NewslettersDynamoDB) Newsletters() (newsletters []*common.NewsletterConfig, err error) {
+	input := &dynamodb.ScanInput{
+		TableName: &s.TableName,
+	}
+
+	err = s.Client.ScanPages(input, func(page *dynamodb.ScanOutput, more bool) bool {
+		var items []*common.NewsletterConfig
+		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			// print the error and continue receiving pages
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
+			return true
+		}
+
+		newsletters = append(newsletters, items...)
+		return true
+	})
+
+	return
+}
+
+// putNewsletter writes the config if the condition on the name holds
+func (s *NewslettersDynamoDB) putNewsletter(nc *common.NewsletterConfig, condition string) error {
+	item, err := dynamodbattribute.MarshalMap(nc)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                item,
+		TableName:           &s.TableName,
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]*string{
+			"#name": aws.String("name"),
+		},
+	}
+
+	_, err = s.Client.PutItem(input)
+	return err
+}
+
+func isConditionalCheckFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+func (s *NewslettersDynamoDB) AddNewsletter(nc *common.NewsletterConfig) error {
+	err := s.putNewsletter(nc, "attribute_not_exists(#name)")
+	if isConditionalCheckFailed(err) {
+		return common.ErrNewsletterExists
+	}
+
+	return err
+}
+
+func (s *NewslettersDynamoDB) UpdateNewsletter(nc *common.NewsletterConfig) error {
+	err := s.putNewsletter(nc, "attribute_exists(#name)")
+	if isConditionalCheckFailed(err) {
+		return common.ErrNewsletterNotFound
+	}
+
+	return err
+}
+
+func (s *NewslettersDynamoDB) DeleteNewsletter(name string) error {
+	input := &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {
+				S: aws.String(name),
+			},
+		},
+		TableName:           &s.TableName,
+		ConditionExpression: aws.String("attribute_exists(#name)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#name": aws.String("name"),
+		},
+	}
+
+	_, err := s.Client.DeleteItem(input)
+	if isConditionalCheckFailed(err) {
+		return common.ErrNewsletterNotFound
+	}
+
+	return err
+}
+
+type NewslettersMapStore struct {
+	items map[string]*common.NewsletterConfig
+}
+
+var _ common.NewslettersStore = (*NewslettersMapStore)(nil)
+
+func NewNewslettersMapStore() *NewslettersMapStore {
+	return &NewslettersMapStore{
+		items: make(map[string]*common.NewsletterConfig),
+	}
+}
+
+func (s *NewslettersMapStore) AddNewsletter(nc *common.NewsletterConfig) error {
+	if _, ok := s.items[nc.Name]; ok {
+		return common.ErrNewsletterExists
+	}
+
+	c := *nc
+	s.items[nc.Name] = &c
+	return nil
+}
+
+func (s *NewslettersMapStore) UpdateNewsletter(nc *common.NewsletterConfig) error {
+	if _, ok := s.items[nc.Name]; !ok {
+		return common.ErrNewsletterNotFound
+	}
+
+	c := *nc
+	s.items[nc.Name] = &c
+	return nil
+}
+
+func (s *NewslettersMapStore) DeleteNewsletter(name string) error {
+	if _, ok := s.items[name]; !ok {
+		return common.ErrNewsletterNotFound
+	}
+
+	delete(s.items, name)
+	return nil
+}
+
+// Newsletters returns copies of the configs so that callers cannot change
+// the stored ones
+func (s *NewslettersMapStore) Newsletters() (newsletters []*common.NewsletterConfig, err error) {
+	for _, nc := range s.items {
+		c := *nc
+		newsletters = append(newsletters, &c)
+	}
+	return newsletters, nil
+}
@@ -1,12 +1,14 @@
 package db
 
 import (
-	"log"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/ribtoks/backoff"
 	"github.com/ribtoks/listing/pkg/common"
 )
 
@@ -15,6 +17,7 @@ import (
 type NotificationsDynamoDB struct {
 	TableName string
 	Client    dynamodbiface.DynamoDBAPI
+	Logger    *common.Logger
 }
 
 var _ common.NotificationsStore = (*NotificationsDynamoDB)(nil)
@@ -48,7 +51,7 @@ func (s *NotificationsDynamoDB) StoreNotification(email, from string, t string)
 		return err
 	}
 
-	log.Printf("Stored notification email=%v type=%v", email, t)
+	s.Logger.Info("Stored notification", "email", email, "type", t)
 	return nil
 }
 
@@ -64,17 +67,36 @@ func (s *NotificationsDynamoDB) AddComplaint(email, from string) error {
 	return s.StoreNotification(email, from, common.ComplaintType)
 }
 
+func (s *NotificationsDynamoDB) LiftSuppression(email string) error {
+	return s.StoreNotification(email, "", common.SuppressionLiftedType)
+}
+
+// WithLogger returns a copy of the store that uses the logger
+func (s *NotificationsDynamoDB) WithLogger(l *common.Logger) common.NotificationsStore {
+	c := *s
+	c.Logger = l
+
+	return &c
+}
+
+// Ping checks that the table exists and is reachable
+func (s *NotificationsDynamoDB) Ping() error {
+	return describeTable(s.Client, s.TableName)
+}
+
+// Notifications reads the whole table, prefer EmailNotifications
+// when only some addresses are needed
 func (s *NotificationsDynamoDB) Notifications() (notifications []*common.SesNotification, err error) {
-	query := &dynamodb.QueryInput{
+	input := &dynamodb.ScanInput{
 		TableName: &s.TableName,
 	}
 
-	err = s.Client.QueryPages(query, func(page *dynamodb.QueryOutput, more bool) bool {
+	err = s.Client.ScanPages(input, func(page *dynamodb.ScanOutput, more bool) bool {
 		var items []*common.SesNotification
 		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
 		if err != nil {
 			// print the error and continue receiving pages
-			log.Printf("Could not unmarshal AWS data. err=%v", err)
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
 			return true
 		}
 
@@ -86,6 +108,91 @@ func (s *NotificationsDynamoDB) Notifications() (notifications []*common.SesNoti
 	return
 }
 
+func (s *NotificationsDynamoDB) EmailNotifications(email string) (notifications []*common.SesNotification, err error) {
+	query := &dynamodb.QueryInput{
+		TableName:              &s.TableName,
+		KeyConditionExpression: aws.String("email = :email"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":email": {
+				S: aws.String(email),
+			},
+		},
+	}
+
+	err = s.Client.QueryPages(query, func(page *dynamodb.QueryOutput, more bool) bool {
+		var items []*common.SesNotification
+		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
+		if err != nil {
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
+			return true
+		}
+
+		notifications = append(notifications, items...)
+		return true
+	})
+
+	return
+}
+
+func (s *NotificationsDynamoDB) DeleteNotifications(email string) error {
+	notifications, err := s.EmailNotifications(email)
+	if err != nil {
+		return err
+	}
+
+	requests := make([]*dynamodb.WriteRequest, 0, len(notifications))
+	for _, n := range notifications {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"email": {
+						S: aws.String(n.Email),
+					},
+					"notification": {
+						S: aws.String(n.Notification),
+					},
+				},
+			},
+		})
+	}
+
+	for i := 0; i < len(requests); i += dynamoDBChunkSize {
+		end := i + dynamoDBChunkSize
+
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		b := &backoff.Backoff{
+			Min:    100 * time.Millisecond,
+			Max:    1 * time.Second,
+			Factor: 2,
+			Jitter: false,
+		}
+
+		chunk := requests[i:end]
+		for len(chunk) > 0 {
+			res, err := s.Client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]*dynamodb.WriteRequest{
+					s.TableName: chunk,
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			chunk = res.UnprocessedItems[s.TableName]
+			if len(chunk) > 0 {
+				s.Logger.Warn("Found unprocessed items", "count", len(chunk))
+				time.Sleep(b.Duration())
+			}
+		}
+	}
+
+	s.Logger.Info("Deleted notifications", "email", email, "count", len(notifications))
+	return nil
+}
+
 type NotificationsMapStore struct {
 	items []*common.SesNotification
 }
@@ -116,10 +223,39 @@ func (s *NotificationsMapStore) AddComplaint(email, from string) error {
 	return nil
 }
 
+func (s *NotificationsMapStore) LiftSuppression(email string) error {
+	s.items = append(s.items, &common.SesNotification{
+		Email:        email,
+		ReceivedAt:   common.JsonTimeNow(),
+		Notification: common.SuppressionLiftedType,
+	})
+	return nil
+}
+
 func (s *NotificationsMapStore) Notifications() (notifications []*common.SesNotification, err error) {
 	return s.items, nil
 }
 
+func (s *NotificationsMapStore) EmailNotifications(email string) (notifications []*common.SesNotification, err error) {
+	for _, n := range s.items {
+		if n.Email == email {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+func (s *NotificationsMapStore) DeleteNotifications(email string) error {
+	items := make([]*common.SesNotification, 0, len(s.items))
+	for _, n := range s.items {
+		if n.Email != email {
+			items = append(items, n)
+		}
+	}
+	s.items = items
+	return nil
+}
+
 func NewSubscribersMapStore() *SubscribersMapStore {
 	return &SubscribersMapStore{
 		items: make(map[string]*common.Subscriber),
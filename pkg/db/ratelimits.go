@@ -0,0 +1,126 @@
+package db
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+// RateLimitsDynamoDB is an implementation of RateLimitStore interface
+// that keeps counters in AWS DynamoDB table with TTL on expires_at
+type RateLimitsDynamoDB struct {
+	TableName string
+	Client    dynamodbiface.DynamoDBAPI
+}
+
+var _ common.RateLimitStore = (*RateLimitsDynamoDB)(nil)
+
+// NewRateLimitsStore returns new instance of RateLimitsDynamoDB
+func NewRateLimitsStore(table string, sess *session.Session) *RateLimitsDynamoDB {
+	return &RateLimitsDynamoDB{
+		Client:    dynamodb.New(sess),
+		TableName: table,
+	}
+}
+
+func (s *RateLimitsDynamoDB) Increment(key string, expiresAt time.Time) (int64, error) {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	input := &dynamodb.UpdateItemInput{
+		TableName: &s.TableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": &dynamodb.AttributeValue{
+				S: &key,
+			},
+		},
+		UpdateExpression: aws.String("ADD hits :one SET expires_at = if_not_exists(expires_at, :expires_at)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one": &dynamodb.AttributeValue{
+				N: aws.String("1"),
+			},
+			":expires_at": &dynamodb.AttributeValue{
+				N: &expires,
+			},
+		},
+		ReturnValues: aws.String("UPDATED_NEW"),
+	}
+
+	result, err := s.Client.UpdateItem(input)
+	if err != nil {
+		return 0, err
+	}
+
+	hits, ok := result.Attributes["hits"]
+	if !ok || hits.N == nil {
+		return 0, errResultIsNil
+	}
+
+	return strconv.ParseInt(*hits.N, 10, 64)
+}
+
+// rateLimitsSweepInterval is how often expired counters are removed
+const rateLimitsSweepInterval = 1 * time.Minute
+
+type rateLimitItem struct {
+	count     int64
+	expiresAt time.Time
+}
+
+type RateLimitsMapStore struct {
+	mu        sync.Mutex
+	items     map[string]*rateLimitItem
+	nextSweep time.Time
+}
+
+var _ common.RateLimitStore = (*RateLimitsMapStore)(nil)
+
+func NewRateLimitsMapStore() *RateLimitsMapStore {
+	return &RateLimitsMapStore{
+		items: make(map[string]*rateLimitItem),
+	}
+}
+
+func (s *RateLimitsMapStore) Increment(key string, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	i, ok := s.items[key]
+	if !ok || !now.Before(i.expiresAt) {
+		i = &rateLimitItem{expiresAt: expiresAt}
+		s.items[key] = i
+	}
+
+	i.count++
+	return i.count, nil
+}
+
+// sweep removes expired counters like TTL of the DynamoDB table does
+func (s *RateLimitsMapStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+
+	for key, i := range s.items {
+		if !now.Before(i.expiresAt) {
+			delete(s.items, key)
+		}
+	}
+
+	s.nextSweep = now.Add(rateLimitsSweepInterval)
+}
+
+// Count returns the number of stored counters
+func (s *RateLimitsMapStore) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.items)
+}
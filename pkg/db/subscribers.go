@@ -2,7 +2,7 @@ package db
 
 import (
 	"errors"
-	"log"
+	"fmt"
 	"strings"
 	"time"
 
@@ -19,7 +19,7 @@ var (
 	incorrectTime             = common.JSONTime(time.Unix(1, 1))
 	errChunkTooBig            = errors.New("Chunk of data contains more than allowed 25 items")
 	errResultIsNil            = errors.New("Result is nil")
-	errSubscriberDoesNotExist = errors.New("Subscriber does not exist")
+	errSubscriberDoesNotExist = common.ErrSubscriberNotFound
 )
 
 const (
@@ -37,12 +37,31 @@ func NewSubscribersStore(table string, sess *session.Session) *SubscribersDynamo
 type SubscribersDynamoDB struct {
 	TableName string
 	Client    dynamodbiface.DynamoDBAPI
+	Logger    *common.Logger
 }
 
 // make sure SubscribersDynamoDB implements interface
 var _ common.SubscribersStore = (*SubscribersDynamoDB)(nil)
 
+// WithLogger returns a copy of the store that uses the logger
+func (s *SubscribersDynamoDB) WithLogger(l *common.Logger) common.SubscribersStore {
+	c := *s
+	c.Logger = l
+
+	return &c
+}
+
+// Ping checks that the table exists and is reachable
+func (s *SubscribersDynamoDB) Ping() error {
+	return describeTable(s.Client, s.TableName)
+}
+
 func (s *SubscribersDynamoDB) GetSubscriber(newsletter, email string) (*common.Subscriber, error) {
+	email, err := common.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(s.TableName),
 		Key: map[string]*dynamodb.AttributeValue{
@@ -61,7 +80,7 @@ func (s *SubscribersDynamoDB) GetSubscriber(newsletter, email string) (*common.S
 	}
 
 	if result.Item == nil {
-		return nil, errResultIsNil
+		return nil, errSubscriberDoesNotExist
 	}
 
 	cs := new(common.Subscriber)
@@ -73,7 +92,12 @@ func (s *SubscribersDynamoDB) GetSubscriber(newsletter, email string) (*common.S
 	return cs, nil
 }
 
-func (s *SubscribersDynamoDB) AddSubscriber(newsletter, email, name string) error {
+func (s *SubscribersDynamoDB) AddSubscriber(newsletter, email, name, locale string, attributes map[string]string) error {
+	email, err := common.NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
 	sr := &common.Subscriber{
 		Name:           name,
 		Newsletter:     newsletter,
@@ -81,6 +105,9 @@ func (s *SubscribersDynamoDB) AddSubscriber(newsletter, email, name string) erro
 		CreatedAt:      common.JsonTimeNow(),
 		UnsubscribedAt: incorrectTime,
 		ConfirmedAt:    incorrectTime,
+		RemindedAt:     incorrectTime,
+		Locale:         locale,
+		Attributes:     attributes,
 	}
 	sr.Validate()
 
@@ -101,7 +128,14 @@ func (s *SubscribersDynamoDB) AddSubscriber(newsletter, email, name string) erro
 	return nil
 }
 
+// RemoveSubscriber marks existing subscriber as unsubscribed. Subscribers
+// stored before the normalization are found by the email as given.
 func (s *SubscribersDynamoDB) RemoveSubscriber(newsletter, email string) error {
+	normalized, err := common.NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
 	updateVal := struct {
 		UnsubscribedAt common.JSONTime `json:":unsubscribed_at"`
 	}{
@@ -112,22 +146,32 @@ func (s *SubscribersDynamoDB) RemoveSubscriber(newsletter, email string) error {
 	if err != nil {
 		return err
 	}
-	input := &dynamodb.UpdateItemInput{
-		ExpressionAttributeValues: update,
-		UpdateExpression:          aws.String("set unsubscribed_at = :unsubscribed_at"),
-		TableName:                 &s.TableName,
-		Key: map[string]*dynamodb.AttributeValue{
-			"newsletter": &dynamodb.AttributeValue{
-				S: &newsletter,
-			},
-			"email": &dynamodb.AttributeValue{
-				S: &email,
+
+	for _, e := range storedEmails(email, normalized) {
+		input := &dynamodb.UpdateItemInput{
+			ExpressionAttributeValues: update,
+			UpdateExpression:          aws.String("set unsubscribed_at = :unsubscribed_at"),
+			// do not create the item for unknown email
+			ConditionExpression: aws.String("attribute_exists(email)"),
+			TableName:           &s.TableName,
+			Key: map[string]*dynamodb.AttributeValue{
+				"newsletter": &dynamodb.AttributeValue{
+					S: &newsletter,
+				},
+				"email": &dynamodb.AttributeValue{
+					S: aws.String(e),
+				},
 			},
-		},
-		ReturnValues: aws.String("UPDATED_NEW"),
+			ReturnValues: aws.String("UPDATED_NEW"),
+		}
+
+		_, err = s.Client.UpdateItem(input)
+		if !isConditionalCheckFailed(err) {
+			return err
+		}
 	}
-	_, err = s.Client.UpdateItem(input)
-	return err
+
+	return errSubscriberDoesNotExist
 }
 
 func (s *SubscribersDynamoDB) Subscribers(newsletter string) (subscribers []*common.Subscriber, err error) {
@@ -146,7 +190,7 @@ func (s *SubscribersDynamoDB) Subscribers(newsletter string) (subscribers []*com
 		err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &items)
 		if err != nil {
 			// print the error and continue receiving pages
-			log.Printf("Could not unmarshal AWS data. err=%v", err)
+			s.Logger.Error("Could not unmarshal AWS data", "err", err)
 			return true
 		}
 
@@ -158,6 +202,94 @@ func (s *SubscribersDynamoDB) Subscribers(newsletter string) (subscribers []*com
 	return
 }
 
+// QuerySubscribers returns one page of subscribers. Filters are applied to
+// fetched items, so the table is queried until the page is full, all
+// subscribers of the newsletter or MaxEvaluated items are evaluated.
+func (s *SubscribersDynamoDB) QuerySubscribers(q *common.SubscribersQuery) (*common.SubscribersPage, error) {
+	start, err := q.StartKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastKey map[string]*dynamodb.AttributeValue
+	if start != nil {
+		lastKey = map[string]*dynamodb.AttributeValue{
+			"newsletter": &dynamodb.AttributeValue{
+				S: aws.String(start.Newsletter),
+			},
+			"email": &dynamodb.AttributeValue{
+				S: aws.String(start.Email),
+			},
+		}
+	}
+
+	page := &common.SubscribersPage{Subscribers: make([]*common.Subscriber, 0)}
+	evaluated := 0
+
+	for {
+		query := &dynamodb.QueryInput{
+			TableName:              &s.TableName,
+			KeyConditionExpression: aws.String(`newsletter = :newsletter`),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":newsletter": &dynamodb.AttributeValue{
+					S: aws.String(q.Newsletter),
+				},
+			},
+			ExclusiveStartKey: lastKey,
+		}
+
+		// evaluate no more items than fit into the page so that
+		// LastEvaluatedKey is the key of the last returned subscriber
+		if q.Limit > 0 {
+			limit := q.Limit - len(page.Subscribers)
+			if q.MaxEvaluated > 0 && q.MaxEvaluated-evaluated < limit {
+				limit = q.MaxEvaluated - evaluated
+			}
+			query.Limit = aws.Int64(int64(limit))
+		}
+
+		result, err := s.Client.Query(query)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []*common.Subscriber
+		err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &items)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range items {
+			if q.Match(i) {
+				page.Subscribers = append(page.Subscribers, i)
+			}
+		}
+
+		evaluated += len(items)
+
+		lastKey = result.LastEvaluatedKey
+		if len(lastKey) == 0 {
+			break
+		}
+
+		if q.Limit > 0 && (len(page.Subscribers) >= q.Limit || (q.MaxEvaluated > 0 && evaluated >= q.MaxEvaluated)) {
+			break
+		}
+	}
+
+	if len(lastKey) > 0 {
+		key := &common.SubscriberKey{}
+		err = dynamodbattribute.UnmarshalMap(lastKey, key)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Cursor = common.EncodeCursor(key)
+	}
+
+	return page, nil
+}
+
 func (s *SubscribersDynamoDB) AddSubscribersChunk(subscribers []*common.Subscriber) error {
 	// AWS DynamoDB restriction
 	if len(subscribers) > dynamoDBChunkSize {
@@ -166,6 +298,9 @@ func (s *SubscribersDynamoDB) AddSubscribersChunk(subscribers []*common.Subscrib
 
 	requests := make([]*dynamodb.WriteRequest, 0, len(subscribers))
 	for _, i := range subscribers {
+		if err := normalizeSubscriber(i); err != nil {
+			return err
+		}
 		i.Validate()
 
 		attr, err := dynamodbattribute.MarshalMap(i)
@@ -198,7 +333,7 @@ func (s *SubscribersDynamoDB) AddSubscribersChunk(subscribers []*common.Subscrib
 			return err
 		}
 		if unprocessed, ok := res.UnprocessedItems[s.TableName]; ok {
-			log.Printf("Found unprocessed items. count=%v", len(unprocessed))
+			s.Logger.Warn("Found unprocessed items", "count", len(unprocessed))
 			requests = unprocessed
 		} else {
 			break
@@ -225,6 +360,11 @@ func (s *SubscribersDynamoDB) AddSubscribers(subscribers []*common.Subscriber) e
 }
 
 func (s *SubscribersDynamoDB) ConfirmSubscriber(newsletter, email string) error {
+	email, err := common.NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
 	updateVal := struct {
 		ConfirmedAt common.JSONTime `json:":confirmed_at"`
 	}{
@@ -254,6 +394,145 @@ func (s *SubscribersDynamoDB) ConfirmSubscriber(newsletter, email string) error
 	return err
 }
 
+func (s *SubscribersDynamoDB) MarkReminded(newsletter, email string, remindedAt common.JSONTime) error {
+	updateVal := struct {
+		RemindedAt common.JSONTime `json:":reminded_at"`
+	}{
+		RemindedAt: remindedAt,
+	}
+
+	update, err := dynamodbattribute.MarshalMap(updateVal)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: update,
+		UpdateExpression:          aws.String("set reminded_at = :reminded_at"),
+		// do not create the item and do not touch confirmed subscribers,
+		// times are stored as RFC 3339 strings in UTC
+		ConditionExpression: aws.String("attribute_exists(email) AND (attribute_not_exists(confirmed_at) OR confirmed_at < created_at)"),
+		TableName:           &s.TableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"newsletter": &dynamodb.AttributeValue{
+				S: &newsletter,
+			},
+			"email": &dynamodb.AttributeValue{
+				S: &email,
+			},
+		},
+	}
+
+	_, err = s.Client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return common.ErrSubscriberNotPending
+	}
+
+	return err
+}
+
+// UpdateSubscriber sets only the fields of the update. Empty attributes
+// are removed from the item.
+func (s *SubscribersDynamoDB) UpdateSubscriber(newsletter, email string, update *common.SubscriberUpdate) (*common.Subscriber, error) {
+	email, err := common.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Empty() {
+		return s.GetSubscriber(newsletter, email)
+	}
+
+	sets := make([]string, 0)
+	removes := make([]string, 0)
+	names := make(map[string]*string)
+	values := make(map[string]*dynamodb.AttributeValue)
+
+	// "name" is a reserved word, so all fields are set via names
+	set := func(field string, value interface{}) error {
+		v, err := dynamodbattribute.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		names["#"+field] = aws.String(field)
+		values[":"+field] = v
+		sets = append(sets, fmt.Sprintf("#%v = :%v", field, field))
+		return nil
+	}
+
+	if update.Name != nil {
+		if err := set("name", *update.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(update.Attributes) > 0 {
+		if err := set("attributes", update.Attributes); err != nil {
+			return nil, err
+		}
+	} else if update.Attributes != nil {
+		names["#attributes"] = aws.String("attributes")
+		removes = append(removes, "#attributes")
+	}
+
+	if update.ConfirmedAt != nil {
+		if err := set("confirmed_at", *update.ConfirmedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if update.UnsubscribedAt != nil {
+		if err := set("unsubscribed_at", *update.UnsubscribedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	expression := ""
+	if len(sets) > 0 {
+		expression = "set " + strings.Join(sets, ", ")
+	}
+	if len(removes) > 0 {
+		expression += " remove " + strings.Join(removes, ", ")
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: names,
+		UpdateExpression:         aws.String(strings.TrimSpace(expression)),
+		// do not create the item if it does not exist
+		ConditionExpression: aws.String("attribute_exists(newsletter)"),
+		TableName:           &s.TableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"newsletter": &dynamodb.AttributeValue{
+				S: &newsletter,
+			},
+			"email": &dynamodb.AttributeValue{
+				S: &email,
+			},
+		},
+		ReturnValues: aws.String("ALL_NEW"),
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	result, err := s.Client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return nil, errSubscriberDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cs := new(common.Subscriber)
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, cs)
+	if err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
 func (s *SubscribersDynamoDB) DeleteSubscribersChunk(keys []*common.SubscriberKey) error {
 	// AWS DynamoDB restriction
 	if len(keys) > dynamoDBChunkSize {
@@ -292,7 +571,7 @@ func (s *SubscribersDynamoDB) DeleteSubscribersChunk(keys []*common.SubscriberKe
 			return err
 		}
 		if unprocessed, ok := res.UnprocessedItems[s.TableName]; ok {
-			log.Printf("Found unprocessed items. count=%v", len(unprocessed))
+			s.Logger.Warn("Found unprocessed items", "count", len(unprocessed))
 			requests = unprocessed
 		} else {
 			break
@@ -303,6 +582,8 @@ func (s *SubscribersDynamoDB) DeleteSubscribersChunk(keys []*common.SubscriberKe
 	return nil
 }
 
+// DeleteSubscribers removes subscribers with exactly the same keys, emails
+// are not normalized so that duplicates with legacy keys can be removed
 func (s *SubscribersDynamoDB) DeleteSubscribers(keys []*common.SubscriberKey) error {
 	for i := 0; i < len(keys); i += dynamoDBChunkSize {
 		end := i + dynamoDBChunkSize
@@ -319,8 +600,39 @@ func (s *SubscribersDynamoDB) DeleteSubscribers(keys []*common.SubscriberKey) er
 	return nil
 }
 
+// normalizeSubscriber replaces the email of the subscriber with its canonical form
+// storedEmails returns keys the subscriber can be stored with: the canonical
+// email and the email as given if it was stored before the normalization
+func storedEmails(email, normalized string) []string {
+	if email == normalized {
+		return []string{normalized}
+	}
+
+	return []string{normalized, email}
+}
+
+func normalizeSubscriber(sr *common.Subscriber) error {
+	email, err := common.NormalizeEmail(sr.Email)
+	if err != nil {
+		return err
+	}
+
+	sr.Email = email
+
+	return nil
+}
+
+func describeTable(client dynamodbiface.DynamoDBAPI, table string) error {
+	_, err := client.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(table),
+	})
+
+	return err
+}
+
 type SubscribersMapStore struct {
-	items map[string]*common.Subscriber
+	items  map[string]*common.Subscriber
+	Logger *common.Logger
 }
 
 var _ common.SubscribersStore = (*SubscribersMapStore)(nil)
@@ -339,6 +651,11 @@ func (s *SubscribersMapStore) Count() int {
 }
 
 func (s *SubscribersMapStore) GetSubscriber(newsletter, email string) (*common.Subscriber, error) {
+	email, err := common.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
 	key := s.key(newsletter, email)
 	sr, ok := s.items[key]
 	if !ok {
@@ -347,18 +664,27 @@ func (s *SubscribersMapStore) GetSubscriber(newsletter, email string) (*common.S
 	return sr, nil
 }
 
-func (s *SubscribersMapStore) AddSubscriber(newsletter, email, name string) error {
+func (s *SubscribersMapStore) AddSubscriber(newsletter, email, name, locale string, attributes map[string]string) error {
+	email, err := common.NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
 	key := s.key(newsletter, email)
 	if _, ok := s.items[key]; ok {
-		log.Printf("Subscriber already exists. email=%v newsletter=%v", email, newsletter)
+		s.Logger.Warn("Subscriber already exists", "email", email, "newsletter", newsletter)
 	}
 
 	sr := &common.Subscriber{
+		Name:           name,
 		Newsletter:     newsletter,
 		Email:          email,
 		CreatedAt:      common.JsonTimeNow(),
 		ConfirmedAt:    incorrectTime,
 		UnsubscribedAt: incorrectTime,
+		RemindedAt:     incorrectTime,
+		Locale:         locale,
+		Attributes:     attributes,
 	}
 	sr.Validate()
 
@@ -367,11 +693,18 @@ func (s *SubscribersMapStore) AddSubscriber(newsletter, email, name string) erro
 }
 
 func (s *SubscribersMapStore) RemoveSubscriber(newsletter, email string) error {
-	key := s.key(newsletter, email)
-	if i, ok := s.items[key]; ok {
-		i.UnsubscribedAt = common.JsonTimeNow()
-		return nil
+	normalized, err := common.NormalizeEmail(email)
+	if err != nil {
+		return err
 	}
+
+	for _, e := range storedEmails(email, normalized) {
+		if i, ok := s.items[s.key(newsletter, e)]; ok {
+			i.UnsubscribedAt = common.JsonTimeNow()
+			return nil
+		}
+	}
+
 	return errSubscriberDoesNotExist
 }
 
@@ -392,14 +725,41 @@ func (s *SubscribersMapStore) Subscribers(newsletter string) (subscribers []*com
 	return subscribers, nil
 }
 
+func (s *SubscribersMapStore) QuerySubscribers(q *common.SubscribersQuery) (*common.SubscribersPage, error) {
+	subscribers, err := s.Subscribers(q.Newsletter)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Page(subscribers)
+}
+
 func (s *SubscribersMapStore) AddSubscribers(subscribers []*common.Subscriber) error {
 	for _, i := range subscribers {
+		if err := normalizeSubscriber(i); err != nil {
+			return err
+		}
 		s.items[s.key(i.Newsletter, i.Email)] = i
 	}
 	return nil
 }
 
+func (s *SubscribersMapStore) UpdateSubscriber(newsletter, email string, update *common.SubscriberUpdate) (*common.Subscriber, error) {
+	sr, err := s.GetSubscriber(newsletter, email)
+	if err != nil {
+		return nil, err
+	}
+
+	update.Apply(sr)
+	return sr, nil
+}
+
 func (s *SubscribersMapStore) ConfirmSubscriber(newsletter, email string) error {
+	email, err := common.NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
 	key := s.key(newsletter, email)
 	if i, ok := s.items[key]; ok {
 		i.ConfirmedAt = common.JsonTimeNow()
@@ -407,3 +767,13 @@ func (s *SubscribersMapStore) ConfirmSubscriber(newsletter, email string) error
 	}
 	return errSubscriberDoesNotExist
 }
+
+func (s *SubscribersMapStore) MarkReminded(newsletter, email string, remindedAt common.JSONTime) error {
+	i, ok := s.items[s.key(newsletter, email)]
+	if !ok || i.Confirmed() {
+		return common.ErrSubscriberNotPending
+	}
+
+	i.RemindedAt = remindedAt
+	return nil
+}
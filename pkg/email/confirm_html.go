@@ -372,6 +372,7 @@ const (
                           </tbody>
                         </table>
                         <p>You are receiveing this email because somebody, hopefully you, subscribed to {{.Newsletter}} newsletter. If it was not you, you can safely ignore this email.</p>
+                        {{if .PreferencesURL}}<p><a href="{{.PreferencesURL}}" target="_blank">Manage your subscriptions</a></p>{{end}}
                       </td>
                     </tr>
                   </table>
@@ -2,8 +2,11 @@ package email
 
 import (
 	"bytes"
-	"log"
+	"io/ioutil"
+	"net/mail"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
 
@@ -33,40 +36,239 @@ Thank you for subscribing to {{.Newsletter}} newsletter! Please confirm your ema
 {{.ConfirmURL}}
 
 You are receiveing this email because somebody, hopefully you, subscribed to {{.Newsletter}} newsletter. If it was not you, you can safely ignore this email.
-
+{{if .PreferencesURL}}
+Manage your subscriptions: {{.PreferencesURL}}
+{{end}}
 {{.Newsletter}} team
 `
 )
 
+// files of the template set directory
+const (
+	subjectFile = "subject.txt"
+	htmlFile    = "confirm.html"
+	textFile    = "confirm.txt"
+)
+
+// DefaultTemplateSet is used for newsletters without own template set
+const DefaultTemplateSet = "default"
+
 var (
 	HtmlTemplate *template.Template
 	TextTemplate *template.Template
+	// DefaultTemplates are used for newsletters without own template set
+	DefaultTemplates *Templates
 )
 
+// Templates is a set of templates of the confirmation email
+type Templates struct {
+	Subject *template.Template
+	HTML    *template.Template
+	Text    *template.Template
+}
+
+// TemplateSet keeps translations of the confirmation email by locale.
+// Empty locale holds templates from the root of the set directory.
+type TemplateSet map[string]*Templates
+
 // SESMailer is an implementation of Mailer interface that works with AWS SES
 type SESMailer struct {
 	Sender string
 	Secret string
 	Svc    *ses.SES
+	// Templates are confirmation template sets by name
+	Templates map[string]TemplateSet
+	// DefaultLocale is used when translation for subscriber is missing
+	DefaultLocale string
+	Logger        *common.Logger
 }
 
 var _ common.Mailer = (*SESMailer)(nil)
 
-func (sm *SESMailer) confirmURL(newsletter, email string, confirmBaseURL string) (string, error) {
-	token := common.Sign(sm.Secret, email)
+func (sm *SESMailer) confirmURL(newsletter, email, locale string, confirmBaseURL string) (string, error) {
+	return confirmURL(sm.Secret, newsletter, email, locale, confirmBaseURL)
+}
+
+func confirmURL(secret, newsletter, email, locale string, confirmBaseURL string) (string, error) {
+	token := common.SignToken(secret, common.NewToken(common.PurposeConfirm, newsletter, email))
 	baseUrl, err := url.Parse(confirmBaseURL)
 	if err != nil {
-		log.Println("Malformed URL: ", err.Error())
 		return "", err
 	}
 	params := url.Values{}
 	params.Add(common.ParamNewsletter, newsletter)
 	params.Add(common.ParamToken, token)
+	if locale != "" {
+		params.Add(common.ParamLocale, locale)
+	}
 	baseUrl.RawQuery = params.Encode()
 	return baseUrl.String(), nil
 }
 
-func (sm *SESMailer) sendEmail(email, htmlBody, textBody string) error {
+// preferencesURL returns link to the preference center of the address
+// or empty string if the preference center is not configured
+func preferencesURL(secret, email, preferencesBaseURL string) (string, error) {
+	if preferencesBaseURL == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(preferencesBaseURL)
+	if err != nil {
+		return "", err
+	}
+
+	params := u.Query()
+	params.Set(common.ParamToken, common.SignToken(secret, common.NewToken(common.PurposePreferences, "", email)))
+	u.RawQuery = params.Encode()
+
+	return u.String(), nil
+}
+
+// LoadTemplates reads template sets from subdirectories of dir. Each set
+// has confirm.html, confirm.txt and optional subject.txt files and/or
+// subdirectories with the same files per locale (e.g. "weekly/uk").
+func LoadTemplates(dir string) (map[string]TemplateSet, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make(map[string]TemplateSet)
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		set, err := loadTemplateSet(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(set) == 0 {
+			common.DefaultLogger.Info("Template set is empty", "set", e.Name())
+			continue
+		}
+
+		common.DefaultLogger.Info("Loaded email templates", "set", e.Name(), "locales", len(set))
+		sets[e.Name()] = set
+	}
+
+	return sets, nil
+}
+
+func loadTemplateSet(dir string) (TemplateSet, error) {
+	set := make(TemplateSet)
+
+	if _, err := os.Stat(filepath.Join(dir, htmlFile)); err == nil {
+		t, err := loadTemplates(dir)
+		if err != nil {
+			return nil, err
+		}
+		set[""] = t
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		locale := common.NormalizeLocale(e.Name())
+		if locale == "" {
+			common.DefaultLogger.Warn("Skipping directory with invalid locale", "dir", e.Name())
+			continue
+		}
+
+		t, err := loadTemplates(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		set[locale] = t
+	}
+
+	return set, nil
+}
+
+func loadTemplates(dir string) (*Templates, error) {
+	t := &Templates{
+		Subject: DefaultTemplates.Subject,
+	}
+
+	subject, err := ioutil.ReadFile(filepath.Join(dir, subjectFile))
+	if err == nil {
+		t.Subject, err = template.New("Subject").Parse(strings.TrimSpace(string(subject)))
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if t.HTML, err = template.ParseFiles(filepath.Join(dir, htmlFile)); err != nil {
+		return nil, err
+	}
+
+	if t.Text, err = template.ParseFiles(filepath.Join(dir, textFile)); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// templates chooses translation of the newsletter template set falling
+// back to the language, the default locale and the root of the set
+func (sm *SESMailer) templates(nc *common.NewsletterConfig, locale string) *Templates {
+	name := nc.Templates
+	if name == "" {
+		name = DefaultTemplateSet
+	}
+
+	set, ok := sm.Templates[name]
+	if !ok {
+		if nc.Templates != "" {
+			sm.Logger.Warn("Template set is not found", "set", nc.Templates, "newsletter", nc.Name)
+		}
+
+		return DefaultTemplates
+	}
+
+	defaultLocale := sm.DefaultLocale
+	if defaultLocale == "" {
+		defaultLocale = common.DefaultLocale
+	}
+
+	for _, l := range common.LocaleFallbacks(locale, defaultLocale) {
+		if t, ok := set[l]; ok {
+			return t
+		}
+	}
+
+	if t, ok := set[""]; ok {
+		return t
+	}
+
+	return DefaultTemplates
+}
+
+// source returns sender of the newsletter or the default one
+func (sm *SESMailer) source(nc *common.NewsletterConfig) string {
+	if nc.SenderEmail == "" {
+		return sm.Sender
+	}
+
+	if nc.SenderName == "" {
+		return nc.SenderEmail
+	}
+
+	a := &mail.Address{Name: nc.SenderName, Address: nc.SenderEmail}
+
+	return a.String()
+}
+
+func (sm *SESMailer) sendEmail(nc *common.NewsletterConfig, email, subject, htmlBody, textBody string) error {
 	// Assemble the email.
 	input := &ses.SendEmailInput{
 		Destination: &ses.Destination{
@@ -88,46 +290,57 @@ func (sm *SESMailer) sendEmail(email, htmlBody, textBody string) error {
 			},
 			Subject: &ses.Content{
 				Charset: aws.String(CharSet),
-				Data:    aws.String(Subject),
+				Data:    aws.String(subject),
 			},
 		},
-		Source: aws.String(sm.Sender),
+		Source: aws.String(sm.source(nc)),
 		// Uncomment to use a configuration set
 		//ConfigurationSetName: aws.String(ConfigurationSet),
 	}
 
+	if nc.ReplyTo != "" {
+		input.ReplyToAddresses = []*string{aws.String(nc.ReplyTo)}
+	}
+
 	// Attempt to send the email.
 	result, err := sm.Svc.SendEmail(input)
-	log.Printf("Email send result=%v", result)
-
-	// Display error messages if they occur.
 	if err != nil {
+		code := ""
 		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case ses.ErrCodeMessageRejected:
-				log.Println(ses.ErrCodeMessageRejected, aerr.Error())
-			case ses.ErrCodeMailFromDomainNotVerifiedException:
-				log.Println(ses.ErrCodeMailFromDomainNotVerifiedException, aerr.Error())
-			case ses.ErrCodeConfigurationSetDoesNotExistException:
-				log.Println(ses.ErrCodeConfigurationSetDoesNotExistException, aerr.Error())
-			default:
-				log.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			log.Println(err.Error())
+			code = aerr.Code()
 		}
 
+		sm.Logger.Error("Failed to send email", "email", email, "newsletter", nc.Name, "code", code, "err", err)
+
 		return err
 	}
 
+	sm.Logger.Info("Sent email", "email", email, "newsletter", nc.Name, "message_id", aws.StringValue(result.MessageId))
+
 	return nil
+}
 
+// WithLogger returns a copy of the mailer that uses the logger
+func (sm *SESMailer) WithLogger(l *common.Logger) common.Mailer {
+	c := *sm
+	c.Logger = l
+
+	return &c
+}
+
+// Ping checks that SES is reachable with current credentials
+func (sm *SESMailer) Ping() error {
+	_, err := sm.Svc.GetSendQuota(&ses.GetSendQuotaInput{})
+	return err
 }
 
-func (sm *SESMailer) SendConfirmation(newsletter, email, name, confirmBaseURL string) error {
-	confirmURL, err := sm.confirmURL(newsletter, email, confirmBaseURL)
+func (sm *SESMailer) SendConfirmation(nc *common.NewsletterConfig, email, name, locale string) error {
+	confirmURL, err := sm.confirmURL(nc.Name, email, locale, nc.ConfirmURL)
+	if err != nil {
+		return err
+	}
+
+	prefsURL, err := preferencesURL(sm.Secret, email, nc.PreferencesURL)
 	if err != nil {
 		return err
 	}
@@ -135,29 +348,43 @@ func (sm *SESMailer) SendConfirmation(newsletter, email, name, confirmBaseURL st
 	nameParts := strings.Split(strings.TrimSpace(name), " ")
 
 	data := struct {
-		Newsletter string
-		ConfirmURL string
-		FirstName  string
+		Newsletter     string
+		ConfirmURL     string
+		PreferencesURL string
+		FirstName      string
 	}{
-		Newsletter: newsletter,
-		ConfirmURL: confirmURL,
-		FirstName:  strings.TrimSpace(nameParts[0]),
+		Newsletter:     nc.Title(),
+		ConfirmURL:     confirmURL,
+		PreferencesURL: prefsURL,
+		FirstName:      strings.TrimSpace(nameParts[0]),
+	}
+
+	t := sm.templates(nc, locale)
+
+	var subjectTpl bytes.Buffer
+	if err := t.Subject.Execute(&subjectTpl, data); err != nil {
+		return err
 	}
 
 	var htmlBodyTpl bytes.Buffer
-	if err := HtmlTemplate.Execute(&htmlBodyTpl, data); err != nil {
+	if err := t.HTML.Execute(&htmlBodyTpl, data); err != nil {
 		return err
 	}
 
 	var textBodyTpl bytes.Buffer
-	if err := TextTemplate.Execute(&textBodyTpl, data); err != nil {
+	if err := t.Text.Execute(&textBodyTpl, data); err != nil {
 		return err
 	}
 
-	return sm.sendEmail(email, htmlBodyTpl.String(), textBodyTpl.String())
+	return sm.sendEmail(nc, email, subjectTpl.String(), htmlBodyTpl.String(), textBodyTpl.String())
 }
 
 func init() {
 	HtmlTemplate = template.Must(template.New("HtmlBody").Parse(HTMLBody))
 	TextTemplate = template.Must(template.New("TextBody").Parse(TextBody))
+	DefaultTemplates = &Templates{
+		Subject: template.Must(template.New("Subject").Parse(Subject)),
+		HTML:    HtmlTemplate,
+		Text:    TextTemplate,
+	}
 }
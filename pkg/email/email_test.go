@@ -0,0 +1,184 @@
+package email
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ribtoks/listing/pkg/common"
+)
+
+func TestSource(t *testing.T) {
+	sm := &SESMailer{Sender: "no-reply@example.com"}
+
+	tests := []struct {
+		nc       *common.NewsletterConfig
+		expected string
+	}{
+		{&common.NewsletterConfig{Name: "news"}, "no-reply@example.com"},
+		{&common.NewsletterConfig{Name: "news", SenderEmail: "news@example.com"}, "news@example.com"},
+		{&common.NewsletterConfig{Name: "news", SenderEmail: "news@example.com", SenderName: "News"}, `"News" <news@example.com>`},
+	}
+
+	for _, tt := range tests {
+		if s := sm.source(tt.nc); s != tt.expected {
+			t.Errorf("Unexpected source. actual=%v expected=%v", s, tt.expected)
+		}
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	set := filepath.Join(dir, "weekly")
+	if err := os.Mkdir(set, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		htmlFile: "<a href=\"{{.ConfirmURL}}\">{{.Newsletter}}</a>",
+		textFile: "{{.ConfirmURL}}",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(set, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sets, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm := &SESMailer{Templates: sets}
+	templates := sm.templates(&common.NewsletterConfig{Name: "weekly", Templates: "weekly"}, "")
+	if templates == DefaultTemplates {
+		t.Fatal("Template set is not loaded")
+	}
+
+	var b bytes.Buffer
+	if err := templates.Subject.Execute(&b, struct{ Newsletter string }{"Weekly"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != Subject {
+		t.Errorf("Unexpected default subject: %v", b.String())
+	}
+
+	if sm.templates(&common.NewsletterConfig{Name: "news", Templates: "missing"}, "") != DefaultTemplates {
+		t.Errorf("Default templates are not used for unknown set")
+	}
+}
+
+func TestLocalizedTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	subjects := map[string]string{
+		"weekly":          "Confirm",
+		"weekly/uk":       "Підтвердіть",
+		"weekly/de":       "Bestätigen",
+		"weekly/invalid!": "Invalid",
+	}
+	for path, subject := range subjects {
+		files := map[string]string{
+			subjectFile: subject,
+			htmlFile:    "{{.ConfirmURL}}",
+			textFile:    "{{.ConfirmURL}}",
+		}
+		if err := os.MkdirAll(filepath.Join(dir, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, path, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	sets, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sets["weekly"]) != 3 {
+		t.Fatalf("Unexpected number of locales: %v", len(sets["weekly"]))
+	}
+
+	tests := []struct {
+		defaultLocale string
+		locale        string
+		expected      string
+	}{
+		{"", "uk", "Підтвердіть"},
+		{"", "de-AT", "Bestätigen"},
+		{"", "fr", "Confirm"},
+		{"", "", "Confirm"},
+		{"de", "fr", "Bestätigen"},
+		{"de", "uk-ua", "Підтвердіть"},
+	}
+
+	nc := &common.NewsletterConfig{Name: "weekly", Templates: "weekly"}
+	for _, tt := range tests {
+		sm := &SESMailer{Templates: sets, DefaultLocale: tt.defaultLocale}
+
+		var b bytes.Buffer
+		if err := sm.templates(nc, tt.locale).Subject.Execute(&b, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if b.String() != tt.expected {
+			t.Errorf("Unexpected subject. locale=%v actual=%v expected=%v", tt.locale, b.String(), tt.expected)
+		}
+	}
+}
+
+func TestConfirmURLLocale(t *testing.T) {
+	sm := &SESMailer{Secret: "secret"}
+
+	u, err := sm.confirmURL("weekly", "foo@bar.com", "uk", "https://example.com/confirm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := parsed.Query().Get(common.ParamLocale); l != "uk" {
+		t.Errorf("Unexpected locale in confirm url: %v", l)
+	}
+}
+
+func TestPreferencesURL(t *testing.T) {
+	u, err := preferencesURL("secret", "foo@bar.com", "https://example.com/preferences")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &common.TokenPolicy{}
+	email, err := policy.Unsign("secret", parsed.Query().Get(common.ParamToken), common.PurposePreferences, "")
+	if err != nil || email != "foo@bar.com" {
+		t.Errorf("Unexpected preferences token. email=%v err=%v", email, err)
+	}
+
+	if u, _ := preferencesURL("secret", "foo@bar.com", ""); u != "" {
+		t.Errorf("Preferences url is not empty: %v", u)
+	}
+}
@@ -0,0 +1,28 @@
+package email
+
+import "github.com/ribtoks/listing/pkg/common"
+
+// LogMailer is an implementation of Mailer interface that only logs
+// confirmation links. It is used to run listing locally without SES.
+type LogMailer struct {
+	Secret string
+	Logger *common.Logger
+}
+
+var _ common.Mailer = (*LogMailer)(nil)
+
+func (lm *LogMailer) SendConfirmation(nc *common.NewsletterConfig, email, name, locale string) error {
+	u, err := confirmURL(lm.Secret, nc.Name, email, locale, nc.ConfirmURL)
+	if err != nil {
+		return err
+	}
+
+	lm.Logger.Info("Skipped sending confirmation email", "email", email, "newsletter", nc.Name, "url", u)
+
+	return nil
+}
+
+// WithLogger returns a copy of the mailer that uses the logger
+func (lm *LogMailer) WithLogger(l *common.Logger) common.Mailer {
+	return &LogMailer{Secret: lm.Secret, Logger: l}
+}